@@ -1,8 +1,129 @@
 package constants
 
+import "time"
+
 const (
 	AwsRegion           = "ap-southeast-1"
 	AwsProfile          = "admin"
-	TableName           = "shortened-urls"
+	TableName           = "shortened-urls-v2"
+	LegacyTableName     = "shortened-urls"
 	TableSecondaryIndex = "ShortUrl-index"
+	DestinationIndex    = "Destination-index"
+)
+
+// BasePath is the path the API is served under, shortened URLs redirecting from BasePath/{shortUrl}
+const BasePath = "/api/v1"
+
+const (
+	DynamoDbBackend = "dynamodb"
+	MemoryBackend   = "memory"
+	SqliteBackend   = "sqlite"
+	SqlitePath      = "url-shortener.db"
+	PostgresBackend = "postgres"
+)
+
+const (
+	PostgresMaxOpenConns    = 25
+	PostgresMaxIdleConns    = 5
+	PostgresConnMaxLifetime = 30 * time.Minute
+)
+
+const (
+	CacheSize                    = 10000
+	CacheTtl                     = 5 * time.Minute
+	NegativeCacheSize            = 10000
+	NegativeCacheTtl             = 30 * time.Second
+	BloomFilterFalsePositiveRate = 0.01
+	// BloomFilterRefresh is how often the Bloom filter is rebuilt so it learns the shortened URLs other instances add
+	BloomFilterRefresh = time.Minute
+)
+
+const (
+	// LongUrlMaxLength is the longest long URL that browsers and proxies reliably follow
+	LongUrlMaxLength = 2048
+	// UrlSchemes are the comma-separated schemes long URLs may have unless others are configured
+	UrlSchemes = "http,https"
+	// DomainPolicyReload is how often the domain policy file is checked for changes
+	DomainPolicyReload = 30 * time.Second
+)
+
+const (
+	// ShortUrlHosts are the comma-separated hosts the service is reached at unless others are configured
+	ShortUrlHosts = "localhost"
+	// RedirectHopsHeader counts the redirects between shortened URLs made before a request
+	RedirectHopsHeader = "X-Redirect-Hops"
+	RedirectMaxHops    = 5
+	// RejectSelfReferences and ResolveSelfReferences are what happens to long URLs pointing at a shortened URL of the
+	// service when they are shortened
+	RejectSelfReferences  = "reject"
+	ResolveSelfReferences = "resolve"
+)
+
+const (
+	ShortenUrlAttempts = 3
+	AliasMinLength     = 3
+	AliasMaxLength     = 32
+)
+
+const (
+	// PasswordMaxLength is the longest password bcrypt hashes without truncating it
+	PasswordMaxLength     = 72
+	PasswordHeader        = "X-Link-Password"
+	PasswordAttempts      = 5
+	PasswordAttemptWindow = 15 * time.Minute
+)
+
+const (
+	ListUrlsLimit    = 50
+	ListUrlsMaxLimit = 100
+	// OwnerContextKey is the key of the gin context holding the owner of the request, once authenticated
+	OwnerContextKey = "owner"
+)
+
+const (
+	TitleMaxLength       = 200
+	DescriptionMaxLength = 1000
+	NotesMaxLength       = 4000
+	TagMaxLength         = 50
+	TagsMaxCount         = 20
+)
+
+const (
+	ShortenBatchMaxUrls = 1000
+	// DynamoDbTransactWriteSize is the most items DynamoDB's TransactWriteItems takes in a transaction
+	DynamoDbTransactWriteSize = 100
+	BatchWriteAttempts        = 5
+	BatchWriteBackoff         = 50 * time.Millisecond
+)
+
+const (
+	ApiKeyHeader        = "X-Api-Key"
+	ApiKeyNameMaxLength = 100
+	// ApiKeyIdBytes and ApiKeySecretBytes are the random bytes of the public and secret parts of an API key
+	ApiKeyIdBytes     = 8
+	ApiKeySecretBytes = 32
+	ApiKeyTableName   = "api-keys"
+	// ScopesContextKey is the key of the gin context holding the scopes granted to the request, once authenticated
+	ScopesContextKey = "scopes"
+)
+
+const (
+	// JwksRefreshInterval is how often at most the JWKS is read again for a key it does not hold
+	JwksRefreshInterval = 5 * time.Minute
+	JwksFetchTimeout    = 10 * time.Second
+	JwksMaxBytes        = 1 << 20
+	// JwtLeeway is the clock skew allowed when checking the expiry and not-before times of a bearer token
+	JwtLeeway     = time.Minute
+	JwtUserClaim  = "sub"
+	JwtRolesClaim = "roles"
+	JwtAdminRole  = "admin"
+)
+
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	IdempotencyKeyMaxLength  = 255
+	IdempotencyWindow        = 24 * time.Hour
+	// IdempotencyCacheSize is the most idempotency keys whose responses each instance keeps
+	IdempotencyCacheSize = 10000
 )
@@ -24,11 +24,567 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/admin/api-keys": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "list the api keys of an owner, or of every owner, revoked ones included",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "list api keys",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Owner of the api keys",
+                        "name": "owner",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.ApiKey"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "create an api key for an owner with the given scopes. The key is only returned in this response,\nthe service keeps a hash of its secret",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "create api keys",
+                "parameters": [
+                    {
+                        "description": "Owner, scopes and name of the api key",
+                        "name": "key",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.NewApiKey"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/models.CreatedApiKey"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/api-keys/{id}": {
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "revoke an api key, which stops authenticating requests right away",
+                "tags": [
+                    "admin"
+                ],
+                "summary": "revoke api keys",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Id of the api key",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/export": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "stream every shortened url, deleted ones included, with all of its fields as JSON Lines or CSV.\nAn export cut short by an error ends with the X-Export-Error trailer",
+                "produces": [
+                    "application/json",
+                    "text/csv"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "export shortened urls",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "default": "jsonl",
+                        "description": "jsonl or csv",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/import": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "import shortened urls exported as JSON Lines or CSV, keeping their ids and short urls.\nUrls already taken are skipped, overwritten or fail the import, which can be rerun as it is idempotent",
+                "consumes": [
+                    "application/json",
+                    "text/csv"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "import shortened urls",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "default": "jsonl",
+                        "description": "jsonl or csv",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "default": "skip",
+                        "description": "skip, overwrite or fail",
+                        "name": "conflict",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.ImportResult"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/data/cache/stats": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "hit and miss counters of the redirect cache, negative cache and Bloom filter",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "cache"
+                ],
+                "summary": "redirect cache statistics",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.CacheStats"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/data/links": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "list shortened urls a page at a time, pass the nextCursor of a page as cursor to get the next one",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "manage"
+                ],
+                "summary": "list shortened urls",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Only urls created at or after this time, RFC 3339",
+                        "name": "createdAfter",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only urls created before this time, RFC 3339",
+                        "name": "createdBefore",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only urls whose destination has this host",
+                        "name": "domain",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only urls of this owner, always the caller unless they are an admin",
+                        "name": "owner",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only urls whose destination or short url contains this",
+                        "name": "q",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only urls with this tag",
+                        "name": "tag",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Cursor of the page to get",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 50,
+                        "description": "Number of urls per page, at most 100",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.UrlPage"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
         "/data/shorten": {
             "post": {
-                "description": "generate shortened urls",
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "generate shortened urls\nwith reuse set, the shortened url the owner already has for the same destination is returned instead.\nRepeats of a request with the same Idempotency-Key are answered with the original response",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "example"
+                ],
+                "summary": "generate shortened urls",
+                "parameters": [
+                    {
+                        "description": "Add URL for shortening",
+                        "name": "longUrl",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.LongUrl"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Unique key of the request, to retry it safely",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Url"
+                        }
+                    },
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/models.Url"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/data/shorten/batch": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "shorten many urls at once, sent as a JSON array or as CSV with a header row naming the fields of each\nurl, either as the request body or as the file of a multipart form. CSV tags are separated by ';'.\nEach url is validated and shortened independently, the results are in the order of the batch",
                 "consumes": [
-                    "application/json"
+                    "application/json",
+                    "text/csv",
+                    "multipart/form-data"
                 ],
                 "produces": [
                     "application/json"
@@ -36,27 +592,337 @@ const docTemplate = `{
                 "tags": [
                     "example"
                 ],
-                "summary": "generate shortened urls",
+                "summary": "generate shortened urls in bulk",
+                "parameters": [
+                    {
+                        "description": "URLs to shorten",
+                        "name": "longUrls",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.LongUrl"
+                            }
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Unique key of the request, to retry it safely",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.ShortenBatchResult"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/data/tags/merge": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "replace the tags in from with the tag in to on every shortened url, renaming a tag when from holds only it.\nOnly the shortened urls of the caller are changed, unless they are an admin",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "manage"
+                ],
+                "summary": "merge or rename tags",
+                "parameters": [
+                    {
+                        "description": "Tags to merge",
+                        "name": "merge",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.TagMerge"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.TagMergeResult"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/data/{shortUrl}": {
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "delete a shortened url, which then answers 410 Gone and is never reissued.\nOnly admins may delete the shortened urls of other owners",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "manage"
+                ],
+                "summary": "delete shortened urls",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Short URL",
+                        "name": "shortUrl",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "change the destination, expiry, click limit, password, title, description, notes or tags of a shortened url.\nOnly admins may change the shortened urls of other owners",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "manage"
+                ],
+                "summary": "update shortened urls",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Short URL",
+                        "name": "shortUrl",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Changes to the shortened URL",
+                        "name": "changes",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.UrlUpdate"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Url"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/health": {
+            "get": {
+                "description": "Check API health",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "API healthcheck",
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    }
+                }
+            }
+        },
+        "/{shortUrl}": {
+            "get": {
+                "description": "redirect shortened urls to the actual urls\npassword-protected urls take their password from the X-Link-Password header, basic auth,\nor a form posting it as password, and answer with that form when it is missing.\nChains of short urls of this service are followed to their destination, loops answer 508",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "text/html"
+                ],
+                "tags": [
+                    "redirect"
+                ],
+                "summary": "redirect shortened urls to the actual urls",
                 "parameters": [
                     {
-                        "description": "Add URL for shortening",
-                        "name": "longUrl",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/models.LongUrl"
-                        }
+                        "type": "string",
+                        "description": "Short URL",
+                        "name": "shortUrl",
+                        "in": "path"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Password of a password-protected short URL",
+                        "name": "X-Link-Password",
+                        "in": "header"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Redirects between short URLs made before this one",
+                        "name": "X-Redirect-Hops",
+                        "in": "header"
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Created",
+                    "303": {
+                        "description": "See Other"
+                    },
+                    "307": {
+                        "description": "Temporary Redirect"
+                    },
+                    "401": {
+                        "description": "Unauthorized",
                         "schema": {
-                            "$ref": "#/definitions/models.Url"
+                            "$ref": "#/definitions/utils.HTTPError"
                         }
                     },
-                    "400": {
-                        "description": "Bad Request",
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "410": {
+                        "description": "Gone",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
                         "schema": {
                             "$ref": "#/definitions/utils.HTTPError"
                         }
@@ -66,32 +932,23 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/utils.HTTPError"
                         }
+                    },
+                    "508": {
+                        "description": "Loop Detected",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
                     }
                 }
-            }
-        },
-        "/health": {
-            "get": {
-                "description": "Check API health",
-                "produces": [
-                    "application/json"
-                ],
-                "summary": "API healthcheck",
-                "responses": {
-                    "200": {
-                        "description": "OK"
-                    }
-                }
-            }
-        },
-        "/{shortUrl}": {
-            "get": {
-                "description": "redirect shortened urls to the actual urls",
+            },
+            "post": {
+                "description": "redirect shortened urls to the actual urls\npassword-protected urls take their password from the X-Link-Password header, basic auth,\nor a form posting it as password, and answer with that form when it is missing.\nChains of short urls of this service are followed to their destination, loops answer 508",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
-                    "application/json"
+                    "application/json",
+                    "text/html"
                 ],
                 "tags": [
                     "redirect"
@@ -103,48 +960,414 @@ const docTemplate = `{
                         "description": "Short URL",
                         "name": "shortUrl",
                         "in": "path"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Password of a password-protected short URL",
+                        "name": "X-Link-Password",
+                        "in": "header"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Redirects between short URLs made before this one",
+                        "name": "X-Redirect-Hops",
+                        "in": "header"
                     }
                 ],
                 "responses": {
+                    "303": {
+                        "description": "See Other"
+                    },
                     "307": {
                         "description": "Temporary Redirect"
                     },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
                     "404": {
                         "description": "Not Found",
                         "schema": {
                             "$ref": "#/definitions/utils.HTTPError"
                         }
                     },
+                    "410": {
+                        "description": "Gone",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
                             "$ref": "#/definitions/utils.HTTPError"
                         }
+                    },
+                    "508": {
+                        "description": "Loop Detected",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
                     }
                 }
             }
         }
     },
     "definitions": {
+        "models.ApiKey": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "description": "Id is the public part of the key, under which it is stored",
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "owner": {
+                    "type": "string"
+                },
+                "revokedAt": {
+                    "description": "RevokedAt is set once the key is revoked, after which it authenticates nothing",
+                    "type": "string"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "models.CacheStats": {
+            "type": "object",
+            "properties": {
+                "bloomFalsePositives": {
+                    "type": "integer"
+                },
+                "bloomFilterEnabled": {
+                    "type": "boolean"
+                },
+                "bloomRejections": {
+                    "type": "integer"
+                },
+                "hits": {
+                    "type": "integer"
+                },
+                "misses": {
+                    "type": "integer"
+                },
+                "negativeHits": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.CreatedApiKey": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "description": "Id is the public part of the key, under which it is stored",
+                    "type": "string"
+                },
+                "key": {
+                    "type": "string",
+                    "example": "3f9c1a7e5b2d4c60.q8VwXj2N0m1Ck4s9Lr7TzYb5HdGa3EuPfKoWi6Sn"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "owner": {
+                    "type": "string"
+                },
+                "revokedAt": {
+                    "description": "RevokedAt is set once the key is revoked, after which it authenticates nothing",
+                    "type": "string"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "models.ImportResult": {
+            "type": "object",
+            "properties": {
+                "imported": {
+                    "type": "integer"
+                },
+                "skipped": {
+                    "type": "integer"
+                }
+            }
+        },
         "models.LongUrl": {
             "type": "object",
             "properties": {
+                "alias": {
+                    "description": "Alias is an optional custom shortened URL, e.g. spring-sale",
+                    "type": "string",
+                    "example": "spring-sale"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "expiresAt": {
+                    "description": "ExpiresAt and TtlSeconds optionally limit the lifetime of the shortened URL, at most one of them may be set",
+                    "type": "string",
+                    "example": "2030-01-01T00:00:00Z"
+                },
                 "longUrl": {
                     "type": "string"
+                },
+                "maxClicks": {
+                    "description": "MaxClicks optionally limits the number of redirects served by the shortened URL, 1 for a one-time link",
+                    "type": "integer",
+                    "example": 1
+                },
+                "notes": {
+                    "type": "string"
+                },
+                "password": {
+                    "description": "Password optionally has to be given before the shortened URL redirects",
+                    "type": "string"
+                },
+                "reuse": {
+                    "description": "Reuse returns the shortened URL already created by the owner for the same destination, if there is one,\ninstead of creating another one",
+                    "type": "boolean"
+                },
+                "tags": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "marketing",
+                        "spring"
+                    ]
+                },
+                "title": {
+                    "type": "string",
+                    "example": "Spring sale"
+                },
+                "ttlSeconds": {
+                    "type": "integer",
+                    "example": 86400
+                }
+            }
+        },
+        "models.NewApiKey": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string",
+                    "example": "campaign tooling"
+                },
+                "owner": {
+                    "type": "string",
+                    "example": "marketing"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "create",
+                        "read"
+                    ]
+                }
+            }
+        },
+        "models.ShortenBatchResult": {
+            "type": "object",
+            "properties": {
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ShortenResult"
+                    }
+                }
+            }
+        },
+        "models.ShortenResult": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "errorCode": {
+                    "description": "ErrorCode tells apart the errors with the same status, for the errors that have one",
+                    "type": "string",
+                    "example": "url_scheme_not_allowed"
+                },
+                "status": {
+                    "type": "integer",
+                    "example": 201
+                },
+                "url": {
+                    "$ref": "#/definitions/models.Url"
+                }
+            }
+        },
+        "models.TagMerge": {
+            "type": "object",
+            "properties": {
+                "from": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "golang"
+                    ]
+                },
+                "to": {
+                    "type": "string",
+                    "example": "go"
+                }
+            }
+        },
+        "models.TagMergeResult": {
+            "type": "object",
+            "properties": {
+                "links": {
+                    "type": "integer"
                 }
             }
         },
         "models.Url": {
             "type": "object",
             "properties": {
+                "clicks": {
+                    "type": "integer"
+                },
+                "createdAt": {
+                    "description": "CreatedAt is unset for shortened URLs created before it was recorded",
+                    "type": "string"
+                },
+                "deletedAt": {
+                    "description": "DeletedAt marks the entry as the tombstone of a deleted shortened URL, kept so the shortened URL is never reissued",
+                    "type": "string"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "expiresAt": {
+                    "description": "ExpiresAt is when the shortened URL stops redirecting, stored as epoch seconds so DynamoDB's TTL can reap it",
+                    "type": "string"
+                },
                 "id": {
                     "type": "integer"
                 },
                 "longUrl": {
                     "type": "string"
                 },
+                "maxClicks": {
+                    "description": "MaxClicks is the number of redirects the shortened URL serves, 0 for unlimited. Clicks counts the ones\nserved so far, and is only kept for shortened URLs with a MaxClicks",
+                    "type": "integer"
+                },
+                "notes": {
+                    "type": "string"
+                },
+                "owner": {
+                    "type": "string"
+                },
                 "shortUrl": {
                     "type": "string"
+                },
+                "tags": {
+                    "description": "Tags are normalized by NormalizeTags, and stored as a string set so DynamoDB can filter on them",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "title": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.UrlPage": {
+            "type": "object",
+            "properties": {
+                "links": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Url"
+                    }
+                },
+                "nextCursor": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.UrlUpdate": {
+            "type": "object",
+            "properties": {
+                "description": {
+                    "type": "string"
+                },
+                "expiresAt": {
+                    "type": "string",
+                    "example": "2030-01-01T00:00:00Z"
+                },
+                "longUrl": {
+                    "type": "string",
+                    "example": "https://example.com"
+                },
+                "maxClicks": {
+                    "description": "MaxClicks of 0 removes the click limit",
+                    "type": "integer",
+                    "example": 10
+                },
+                "notes": {
+                    "type": "string"
+                },
+                "password": {
+                    "description": "Password of \"\" removes the password",
+                    "type": "string"
+                },
+                "removeExpiry": {
+                    "description": "RemoveExpiry removes the expiry, so the shortened URL never expires",
+                    "type": "boolean"
+                },
+                "tags": {
+                    "description": "Tags replace the tags of the shortened URL, an empty list removes them",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "marketing",
+                        "spring"
+                    ]
+                },
+                "title": {
+                    "description": "Title, Description and Notes of \"\" remove them",
+                    "type": "string",
+                    "example": "Spring sale"
                 }
             }
         },
@@ -155,12 +1378,31 @@ const docTemplate = `{
                     "type": "integer",
                     "example": 400
                 },
+                "errorCode": {
+                    "description": "ErrorCode tells apart the errors answered with the same status, for the errors that have one",
+                    "type": "string",
+                    "example": "url_scheme_not_allowed"
+                },
                 "message": {
                     "type": "string",
                     "example": "status bad request"
                 }
             }
         }
+    },
+    "securityDefinitions": {
+        "ApiKeyAuth": {
+            "description": "API key created with the create-api-key command or POST /admin/api-keys",
+            "type": "apiKey",
+            "name": "X-Api-Key",
+            "in": "header"
+        },
+        "BearerAuth": {
+            "description": "\"Bearer \" followed by an API key, or by a JWT of the OIDC provider when JWT_JWKS is set",
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
+        }
     }
 }`
 
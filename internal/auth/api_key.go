@@ -0,0 +1,81 @@
+// Package auth creates the API keys authenticating requests and checks the keys sent with them
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/kjj1998/url-shortener-go/constants"
+	"github.com/kjj1998/url-shortener-go/internal/models"
+	"github.com/kjj1998/url-shortener-go/internal/repository"
+)
+
+var (
+	ErrApiKeyInvalid = errors.New("api key is invalid")
+	ErrApiKeyRevoked = errors.New("api key has been revoked")
+)
+
+// NewApiKey creates an API key for a validated request made at now. The key handed out is the hex-encoded Id of the
+// key and its secret joined by a dot, of which only the hash of the secret is kept
+func NewApiKey(request models.NewApiKey, now time.Time) (models.CreatedApiKey, error) {
+	id := make([]byte, constants.ApiKeyIdBytes)
+	secret := make([]byte, constants.ApiKeySecretBytes)
+	if _, err := rand.Read(id); err != nil {
+		return models.CreatedApiKey{}, err
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return models.CreatedApiKey{}, err
+	}
+
+	createdAt := now.UTC().Truncate(time.Second)
+	key := models.ApiKey{
+		Id:        hex.EncodeToString(id),
+		Owner:     strings.TrimSpace(request.Owner),
+		Scopes:    request.Scopes,
+		Name:      request.Name,
+		CreatedAt: &createdAt,
+	}
+	encodedSecret := base64.RawURLEncoding.EncodeToString(secret)
+	key.SecretHash = HashSecret(encodedSecret)
+
+	return models.CreatedApiKey{ApiKey: key, Key: key.Id + "." + encodedSecret}, nil
+}
+
+// HashSecret returns the hex-encoded SHA-256 hash of the secret part of an API key. The secret is random and long,
+// so it needs no salt nor a slow hash
+func HashSecret(secret string) string {
+	hash := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(hash[:])
+}
+
+// AuthenticateApiKey returns the API key stored in keys for a key sent with a request
+// Returns ErrApiKeyInvalid when the key is malformed, unknown or its secret does not match, and ErrApiKeyRevoked
+// when it has been revoked
+func AuthenticateApiKey(ctx context.Context, keys repository.ApiKeyRepository, key string) (models.ApiKey, error) {
+	id, secret, ok := strings.Cut(key, ".")
+	if !ok || id == "" || secret == "" {
+		return models.ApiKey{}, ErrApiKeyInvalid
+	}
+
+	stored, err := keys.RetrieveApiKey(ctx, id)
+	if err != nil {
+		return models.ApiKey{}, err
+	}
+	// The hash is compared in constant time even when there is no key, so timing does not tell which ids exist
+	matches := subtle.ConstantTimeCompare([]byte(HashSecret(secret)), []byte(stored.SecretHash)) == 1
+	if stored.Id == "" || !matches {
+		return models.ApiKey{}, ErrApiKeyInvalid
+	}
+	if stored.Revoked() {
+		return models.ApiKey{}, ErrApiKeyRevoked
+	}
+
+	return stored, nil
+}
@@ -0,0 +1,53 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kjj1998/url-shortener-go/internal/models"
+	"github.com/kjj1998/url-shortener-go/internal/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewApiKey(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 500, time.UTC)
+	created, err := NewApiKey(models.NewApiKey{Owner: " alice ", Scopes: []string{models.ScopeCreate}, Name: "cli"}, now)
+	assert.NoError(t, err)
+
+	id, secret, ok := strings.Cut(created.Key, ".")
+	assert.True(t, ok)
+	assert.Equal(t, created.Id, id)
+	assert.Len(t, id, 16)
+	assert.Len(t, secret, 43)
+	assert.Equal(t, HashSecret(secret), created.SecretHash)
+	assert.Equal(t, "alice", created.Owner)
+	assert.Equal(t, now.Truncate(time.Second), *created.CreatedAt)
+
+	other, err := NewApiKey(models.NewApiKey{Owner: "alice", Scopes: []string{models.ScopeCreate}}, now)
+	assert.NoError(t, err)
+	assert.NotEqual(t, created.Key, other.Key)
+}
+
+func TestAuthenticateApiKey(t *testing.T) {
+	ctx := context.Background()
+	keys := repository.NewMemoryClient()
+
+	created, err := NewApiKey(models.NewApiKey{Owner: "alice", Scopes: []string{models.ScopeRead}}, time.Now())
+	assert.NoError(t, err)
+	assert.NoError(t, keys.AddApiKey(ctx, created.ApiKey))
+
+	key, err := AuthenticateApiKey(ctx, keys, created.Key)
+	assert.NoError(t, err)
+	assert.Equal(t, created.ApiKey, key)
+
+	for _, invalid := range []string{"", "nodot", ".secret", created.Id + ".", created.Id + ".wrong", "unknown." + strings.Split(created.Key, ".")[1]} {
+		_, err = AuthenticateApiKey(ctx, keys, invalid)
+		assert.ErrorIs(t, err, ErrApiKeyInvalid, invalid)
+	}
+
+	assert.NoError(t, keys.RevokeApiKey(ctx, created.Id, time.Now()))
+	_, err = AuthenticateApiKey(ctx, keys, created.Key)
+	assert.ErrorIs(t, err, ErrApiKeyRevoked)
+}
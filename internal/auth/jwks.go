@@ -0,0 +1,243 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kjj1998/url-shortener-go/constants"
+	"golang.org/x/sync/singleflight"
+)
+
+// jsonWebKey is a public key of a JWKS, as used to verify the signature of a token
+type jsonWebKey struct {
+	kid string
+	// alg is the algorithm the key is restricted to, or empty when it is not
+	alg string
+	key crypto.PublicKey
+}
+
+// parseJWKS parses the signing keys of a JSON Web Key Set. RSA, EC (P-256, P-384 and P-521) and Ed25519 keys are
+// read, keys of other types or only meant for encryption are left out
+func parseJWKS(data []byte) ([]jsonWebKey, error) {
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			Alg string `json:"alg"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("couldn't parse jwks, %w", err)
+	}
+
+	keys := make([]jsonWebKey, 0, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		var key crypto.PublicKey
+		var err error
+		switch k.Kty {
+		case "RSA":
+			key, err = rsaPublicKey(k.N, k.E)
+		case "EC":
+			key, err = ecPublicKey(k.Crv, k.X, k.Y)
+		case "OKP":
+			key, err = edPublicKey(k.Crv, k.X)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("couldn't parse jwks key %q, %w", k.Kid, err)
+		}
+		keys = append(keys, jsonWebKey{kid: k.Kid, alg: k.Alg, key: key})
+	}
+	return keys, nil
+}
+
+func rsaPublicKey(n string, e string) (*rsa.PublicKey, error) {
+	modulus, err := base64.RawURLEncoding.DecodeString(n)
+	if err != nil {
+		return nil, err
+	}
+	exponent, err := base64.RawURLEncoding.DecodeString(e)
+	if err != nil {
+		return nil, err
+	}
+	if len(modulus) == 0 || len(exponent) == 0 || len(exponent) > 4 {
+		return nil, errors.New("invalid rsa key")
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(modulus), E: int(new(big.Int).SetBytes(exponent).Int64())}, nil
+}
+
+func ecPublicKey(crv string, x string, y string) (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	switch crv {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	case "P-521":
+		curve = elliptic.P521()
+	default:
+		return nil, fmt.Errorf("unsupported curve %q", crv)
+	}
+
+	xBytes, err := base64.RawURLEncoding.DecodeString(x)
+	if err != nil {
+		return nil, err
+	}
+	yBytes, err := base64.RawURLEncoding.DecodeString(y)
+	if err != nil {
+		return nil, err
+	}
+	key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(xBytes), Y: new(big.Int).SetBytes(yBytes)}
+	// Checks the point is on the curve
+	if _, err := key.ECDH(); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func edPublicKey(crv string, x string) (ed25519.PublicKey, error) {
+	if crv != "Ed25519" {
+		return nil, fmt.Errorf("unsupported curve %q", crv)
+	}
+	key, err := base64.RawURLEncoding.DecodeString(x)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != ed25519.PublicKeySize {
+		return nil, errors.New("invalid ed25519 key")
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// JWKS is the JSON Web Key Set of an OIDC provider, read from a file or fetched from a URL the first time a token is
+// verified. It is read again when a token is signed by a key it does not hold, as providers rotate their keys, but
+// at most once per refresh interval so tokens with made-up key ids do not hammer the provider
+type JWKS struct {
+	location string
+	refresh  time.Duration
+	client   *http.Client
+	// loads makes the requests missing a key share a single read of the JWKS
+	loads singleflight.Group
+
+	mu       sync.Mutex
+	keys     []jsonWebKey
+	loadedAt time.Time
+}
+
+// NewJWKS creates the JWKS read from location, an http(s) URL or a file path, at most once per refresh
+func NewJWKS(location string, refresh time.Duration) *JWKS {
+	return &JWKS{
+		location: location,
+		refresh:  refresh,
+		client:   &http.Client{Timeout: constants.JwksFetchTimeout},
+	}
+}
+
+// keysFor returns the keys that may have signed a token with the key id kid, every key when kid is empty.
+// The JWKS is read without holding the lock, so tokens signed by the keys already held are verified meanwhile
+func (s *JWKS) keysFor(ctx context.Context, kid string) ([]jsonWebKey, error) {
+	s.mu.Lock()
+	matching := matchingKeys(s.keys, kid)
+	stale := s.stale()
+	s.mu.Unlock()
+	if len(matching) > 0 || !stale {
+		return matching, nil
+	}
+
+	keys, err, _ := s.loads.Do(s.location, func() (any, error) {
+		s.mu.Lock()
+		keys, stale := s.keys, s.stale()
+		s.mu.Unlock()
+		// Another request read the JWKS since this one found it stale
+		if !stale {
+			return keys, nil
+		}
+
+		// The read is shared, so it is not cancelled along with the request that happened to start it
+		keys, err := s.load(context.WithoutCancel(ctx))
+		if err != nil {
+			return nil, err
+		}
+		s.mu.Lock()
+		s.keys, s.loadedAt = keys, time.Now()
+		s.mu.Unlock()
+		return keys, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return matchingKeys(keys.([]jsonWebKey), kid), nil
+}
+
+// stale reports whether the JWKS may be read again, the caller must hold s.mu
+func (s *JWKS) stale() bool {
+	return s.keys == nil || time.Since(s.loadedAt) >= s.refresh
+}
+
+func matchingKeys(keys []jsonWebKey, kid string) []jsonWebKey {
+	if kid == "" {
+		return keys
+	}
+	var matching []jsonWebKey
+	for _, key := range keys {
+		if key.kid == kid {
+			matching = append(matching, key)
+		}
+	}
+	return matching
+}
+
+func (s *JWKS) load(ctx context.Context) ([]jsonWebKey, error) {
+	if !strings.HasPrefix(s.location, "https://") && !strings.HasPrefix(s.location, "http://") {
+		data, err := os.ReadFile(s.location)
+		if err != nil {
+			return nil, err
+		}
+		return parseJWKS(data)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, s.location, nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := s.client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't fetch jwks, %w", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("couldn't fetch jwks, %v answered %v", s.location, response.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(response.Body, constants.JwksMaxBytes))
+	if err != nil {
+		return nil, fmt.Errorf("couldn't fetch jwks, %w", err)
+	}
+	return parseJWKS(data)
+}
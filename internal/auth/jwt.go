@@ -0,0 +1,225 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	ErrTokenInvalid = errors.New("bearer token is invalid")
+	ErrTokenExpired = errors.New("bearer token has expired")
+)
+
+// Identity is the user a bearer token was issued to, with the roles the OIDC provider granted them
+type Identity struct {
+	Subject string
+	Roles   []string
+}
+
+// TokenVerifier verifies the JWTs issued by an OIDC provider, signed by one of the keys of its JWKS, and maps their
+// claims to the Identity of the user they were issued to
+type TokenVerifier struct {
+	Keys *JWKS
+	// Issuer and Audience are the iss and aud claims tokens must have, either is not checked when it is empty
+	Issuer   string
+	Audience string
+	// UserClaim and RolesClaim name the claims holding the user and their roles. A name that is not a claim of the
+	// token is read as a dotted path into nested claims, such as realm_access.roles
+	UserClaim  string
+	RolesClaim string
+	// Leeway is the clock skew allowed when checking the exp and nbf claims
+	Leeway time.Duration
+}
+
+// signatureAlgorithms maps the algorithms accepted in the header of a token to the hash they sign
+var signatureAlgorithms = map[string]crypto.Hash{
+	"RS256": crypto.SHA256, "RS384": crypto.SHA384, "RS512": crypto.SHA512,
+	"PS256": crypto.SHA256, "PS384": crypto.SHA384, "PS512": crypto.SHA512,
+	"ES256": crypto.SHA256, "ES384": crypto.SHA384, "ES512": crypto.SHA512,
+	"EdDSA": 0,
+}
+
+// ecdsaCurveBits is the size of the curve each ECDSA algorithm signs with
+var ecdsaCurveBits = map[string]int{"ES256": 256, "ES384": 384, "ES512": 521}
+
+// Verify checks the signature and claims of a JWT, returning the identity it was issued to
+// Returns an error wrapping ErrTokenInvalid or ErrTokenExpired when the token is refused, and any other error when
+// the JWKS could not be read
+func (v *TokenVerifier) Verify(ctx context.Context, token string) (Identity, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Identity{}, fmt.Errorf("%w, malformed token", ErrTokenInvalid)
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return Identity{}, fmt.Errorf("%w, malformed header", ErrTokenInvalid)
+	}
+	// Only asymmetric algorithms are accepted, which keeps out "none" and HMAC keyed with a public key
+	if _, ok := signatureAlgorithms[header.Alg]; !ok {
+		return Identity{}, fmt.Errorf("%w, algorithm %q is not accepted", ErrTokenInvalid, header.Alg)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Identity{}, fmt.Errorf("%w, malformed signature", ErrTokenInvalid)
+	}
+
+	keys, err := v.Keys.keysFor(ctx, header.Kid)
+	if err != nil {
+		return Identity{}, err
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+	if !slices.ContainsFunc(keys, func(key jsonWebKey) bool { return verifySignature(key, header.Alg, signed, signature) }) {
+		return Identity{}, fmt.Errorf("%w, signature does not match", ErrTokenInvalid)
+	}
+
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return Identity{}, fmt.Errorf("%w, malformed claims", ErrTokenInvalid)
+	}
+	if err := v.checkClaims(claims, time.Now()); err != nil {
+		return Identity{}, err
+	}
+
+	subject, _ := claim(claims, v.UserClaim).(string)
+	if subject == "" {
+		return Identity{}, fmt.Errorf("%w, %v claim is missing", ErrTokenInvalid, v.UserClaim)
+	}
+	return Identity{Subject: subject, Roles: stringList(claim(claims, v.RolesClaim))}, nil
+}
+
+// decodeSegment decodes a base64url-encoded JSON segment of a token, keeping numbers as json.Number
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+// verifySignature reports whether key signed the signing input of a token with alg
+func verifySignature(key jsonWebKey, alg string, signed []byte, signature []byte) bool {
+	if key.alg != "" && key.alg != alg {
+		return false
+	}
+	if alg == "EdDSA" {
+		pub, ok := key.key.(ed25519.PublicKey)
+		return ok && ed25519.Verify(pub, signed, signature)
+	}
+
+	hash := signatureAlgorithms[alg]
+	hasher := hash.New()
+	hasher.Write(signed)
+	digest := hasher.Sum(nil)
+
+	switch pub := key.key.(type) {
+	case *rsa.PublicKey:
+		if strings.HasPrefix(alg, "RS") {
+			return rsa.VerifyPKCS1v15(pub, hash, digest, signature) == nil
+		}
+		if strings.HasPrefix(alg, "PS") {
+			return rsa.VerifyPSS(pub, hash, digest, signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}) == nil
+		}
+	case *ecdsa.PublicKey:
+		// The signature is r and s, each padded to the size of the curve, which is 66 bytes for P-521
+		bits := pub.Curve.Params().BitSize
+		size := (bits + 7) / 8
+		if ecdsaCurveBits[alg] != bits || len(signature) != 2*size {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		return ecdsa.Verify(pub, digest, r, s)
+	}
+	return false
+}
+
+// checkClaims checks the token has not expired at now, is already valid and was issued by and for the service
+func (v *TokenVerifier) checkClaims(claims map[string]any, now time.Time) error {
+	exp, ok := numericDate(claims["exp"])
+	if !ok {
+		return fmt.Errorf("%w, exp claim is missing", ErrTokenInvalid)
+	}
+	if now.After(exp.Add(v.Leeway)) {
+		return ErrTokenExpired
+	}
+	if nbf, ok := numericDate(claims["nbf"]); ok && now.Add(v.Leeway).Before(nbf) {
+		return fmt.Errorf("%w, token is not valid yet", ErrTokenInvalid)
+	}
+
+	if v.Issuer != "" && claims["iss"] != v.Issuer {
+		return fmt.Errorf("%w, issuer does not match", ErrTokenInvalid)
+	}
+	if v.Audience != "" && !slices.Contains(stringList(claims["aud"]), v.Audience) {
+		return fmt.Errorf("%w, audience does not match", ErrTokenInvalid)
+	}
+	return nil
+}
+
+// numericDate reads a claim holding seconds since the epoch
+func numericDate(value any) (time.Time, bool) {
+	number, ok := value.(json.Number)
+	if !ok {
+		return time.Time{}, false
+	}
+	seconds, err := number.Float64()
+	if err != nil {
+		return time.Time{}, false
+	}
+	whole, fraction := math.Modf(seconds)
+	return time.Unix(int64(whole), int64(fraction*float64(time.Second))), true
+}
+
+// claim returns the claim called name, or else the nested claim name is a dotted path to
+func claim(claims map[string]any, name string) any {
+	if value, ok := claims[name]; ok {
+		return value
+	}
+
+	var value any = claims
+	for _, key := range strings.Split(name, ".") {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		value = object[key]
+	}
+	return value
+}
+
+// stringList reads a claim holding an array of strings, or a single string of them separated by spaces as the scope
+// claim does
+func stringList(value any) []string {
+	switch value := value.(type) {
+	case string:
+		return strings.Fields(value)
+	case []any:
+		list := make([]string, 0, len(value))
+		for _, item := range value {
+			if item, ok := item.(string); ok {
+				list = append(list, item)
+			}
+		}
+		return list
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,305 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testJWKS holds the public keys of testdata/rsa.pem, as test-rsa, and testdata/ec.pem, as test-ec
+const testJWKS = "testdata/jwks.json"
+
+func loadTestKey(t *testing.T, path string) crypto.Signer {
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	block, _ := pem.Decode(data)
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	assert.NoError(t, err)
+	return key.(crypto.Signer)
+}
+
+// signToken signs claims with key into a JWT, with alg and kid in its header
+func signToken(t *testing.T, key crypto.Signer, alg string, kid string, claims map[string]any) string {
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	digest := crypto.SHA256.New()
+	digest.Write([]byte(signed))
+	var signature []byte
+	var err error
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		signature, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest.Sum(nil))
+	case *ecdsa.PrivateKey:
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, key, digest.Sum(nil))
+		if err == nil {
+			signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+		}
+	}
+	assert.NoError(t, err)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func testClaims(changes map[string]any) map[string]any {
+	claims := map[string]any{
+		"iss":   "https://idp.example.com",
+		"aud":   []string{"url-shortener", "other"},
+		"sub":   "alice",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"roles": []string{"user"},
+	}
+	for name, value := range changes {
+		if value == nil {
+			delete(claims, name)
+		} else {
+			claims[name] = value
+		}
+	}
+	return claims
+}
+
+func testVerifier(location string) *TokenVerifier {
+	return &TokenVerifier{
+		Keys:       NewJWKS(location, time.Minute),
+		Issuer:     "https://idp.example.com",
+		Audience:   "url-shortener",
+		UserClaim:  "sub",
+		RolesClaim: "roles",
+		Leeway:     time.Minute,
+	}
+}
+
+func TestTokenVerifier_Verify(t *testing.T) {
+	rsaKey := loadTestKey(t, "testdata/rsa.pem")
+	ecKey := loadTestKey(t, "testdata/ec.pem")
+	otherKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	valid := signToken(t, rsaKey, "RS256", "test-rsa", testClaims(nil))
+	parts := strings.Split(valid, ".")
+	tampered, _ := json.Marshal(testClaims(map[string]any{"sub": "mallory"}))
+
+	tests := []struct {
+		name             string
+		token            string
+		expectedIdentity Identity
+		expectedErr      error
+	}{
+		{
+			name:             "RS256",
+			token:            valid,
+			expectedIdentity: Identity{Subject: "alice", Roles: []string{"user"}},
+		},
+		{
+			name:             "ES256 with roles as a string",
+			token:            signToken(t, ecKey, "ES256", "test-ec", testClaims(map[string]any{"roles": "user admin", "aud": "url-shortener"})),
+			expectedIdentity: Identity{Subject: "alice", Roles: []string{"user", "admin"}},
+		},
+		{
+			name:             "No key id",
+			token:            signToken(t, rsaKey, "RS256", "", testClaims(map[string]any{"roles": nil})),
+			expectedIdentity: Identity{Subject: "alice"},
+		},
+		{
+			name:             "Expired within leeway",
+			token:            signToken(t, rsaKey, "RS256", "test-rsa", testClaims(map[string]any{"exp": time.Now().Add(-30 * time.Second).Unix()})),
+			expectedIdentity: Identity{Subject: "alice", Roles: []string{"user"}},
+		},
+		{
+			name:        "Expired",
+			token:       signToken(t, rsaKey, "RS256", "test-rsa", testClaims(map[string]any{"exp": time.Now().Add(-time.Hour).Unix()})),
+			expectedErr: ErrTokenExpired,
+		},
+		{
+			name:        "No expiry",
+			token:       signToken(t, rsaKey, "RS256", "test-rsa", testClaims(map[string]any{"exp": nil})),
+			expectedErr: ErrTokenInvalid,
+		},
+		{
+			name:        "Not valid yet",
+			token:       signToken(t, rsaKey, "RS256", "test-rsa", testClaims(map[string]any{"nbf": time.Now().Add(time.Hour).Unix()})),
+			expectedErr: ErrTokenInvalid,
+		},
+		{
+			name:        "Other issuer",
+			token:       signToken(t, rsaKey, "RS256", "test-rsa", testClaims(map[string]any{"iss": "https://evil.example.com"})),
+			expectedErr: ErrTokenInvalid,
+		},
+		{
+			name:        "Other audience",
+			token:       signToken(t, rsaKey, "RS256", "test-rsa", testClaims(map[string]any{"aud": "other"})),
+			expectedErr: ErrTokenInvalid,
+		},
+		{
+			name:        "No subject",
+			token:       signToken(t, rsaKey, "RS256", "test-rsa", testClaims(map[string]any{"sub": nil})),
+			expectedErr: ErrTokenInvalid,
+		},
+		{
+			name:        "Unknown key",
+			token:       signToken(t, otherKey, "RS256", "test-rsa", testClaims(nil)),
+			expectedErr: ErrTokenInvalid,
+		},
+		{
+			name:        "Algorithm of another key",
+			token:       signToken(t, rsaKey, "RS256", "test-ec", testClaims(nil)),
+			expectedErr: ErrTokenInvalid,
+		},
+		{
+			name:        "Tampered claims",
+			token:       parts[0] + "." + base64.RawURLEncoding.EncodeToString(tampered) + "." + parts[2],
+			expectedErr: ErrTokenInvalid,
+		},
+		{
+			name:        "No signature",
+			token:       base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." + parts[1] + ".",
+			expectedErr: ErrTokenInvalid,
+		},
+		{
+			name:        "HMAC",
+			token:       base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","kid":"test-rsa"}`)) + "." + parts[1] + "." + parts[2],
+			expectedErr: ErrTokenInvalid,
+		},
+		{
+			name:        "Malformed",
+			token:       "not.a-token",
+			expectedErr: ErrTokenInvalid,
+		},
+	}
+
+	verifier := testVerifier(testJWKS)
+	for _, tt := range tests {
+		identity, err := verifier.Verify(context.Background(), tt.token)
+		if tt.expectedErr != nil {
+			assert.ErrorIs(t, err, tt.expectedErr, tt.name)
+			continue
+		}
+		assert.NoError(t, err, tt.name)
+		assert.Equal(t, tt.expectedIdentity, identity, tt.name)
+	}
+}
+
+func TestTokenVerifier_NestedClaims(t *testing.T) {
+	verifier := testVerifier(testJWKS)
+	verifier.UserClaim = "email"
+	verifier.RolesClaim = "realm_access.roles"
+
+	token := signToken(t, loadTestKey(t, "testdata/rsa.pem"), "RS256", "test-rsa", testClaims(map[string]any{
+		"email":        "alice@example.com",
+		"realm_access": map[string]any{"roles": []string{"admin"}},
+	}))
+	identity, err := verifier.Verify(context.Background(), token)
+
+	assert.NoError(t, err)
+	assert.Equal(t, Identity{Subject: "alice@example.com", Roles: []string{"admin"}}, identity)
+}
+
+func TestJWKS_Refresh(t *testing.T) {
+	jwks, err := os.ReadFile(testJWKS)
+	assert.NoError(t, err)
+	var fetches atomic.Int32
+	// Serves the keys with test-rsa renamed until the provider rotates to it
+	rotated := atomic.Bool{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		if rotated.Load() {
+			w.Write(jwks)
+			return
+		}
+		w.Write([]byte(strings.ReplaceAll(string(jwks), "test-rsa", "old-rsa")))
+	}))
+	defer server.Close()
+
+	verifier := testVerifier(server.URL)
+	token := signToken(t, loadTestKey(t, "testdata/rsa.pem"), "RS256", "test-rsa", testClaims(nil))
+
+	_, err = verifier.Verify(context.Background(), token)
+	assert.ErrorIs(t, err, ErrTokenInvalid)
+	_, err = verifier.Verify(context.Background(), token)
+	assert.ErrorIs(t, err, ErrTokenInvalid)
+	assert.Equal(t, int32(1), fetches.Load(), "unknown keys are refetched at most once per refresh interval")
+
+	rotated.Store(true)
+	verifier.Keys.loadedAt = time.Now().Add(-time.Hour)
+	_, err = verifier.Verify(context.Background(), token)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), fetches.Load())
+
+	_, err = verifier.Verify(context.Background(), token)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), fetches.Load(), "known keys are not refetched")
+}
+
+func TestJWKS_ConcurrentRefresh(t *testing.T) {
+	jwks, err := os.ReadFile(testJWKS)
+	assert.NoError(t, err)
+	var fetches atomic.Int32
+	fetching := make(chan struct{})
+	release := make(chan struct{})
+	// Serves the keys at once the first time, and holds the later fetches until released
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fetches.Add(1) > 1 {
+			close(fetching)
+			<-release
+		}
+		w.Write(jwks)
+	}))
+	defer server.Close()
+
+	verifier := testVerifier(server.URL)
+	known := signToken(t, loadTestKey(t, "testdata/rsa.pem"), "RS256", "test-rsa", testClaims(nil))
+	unknown := signToken(t, loadTestKey(t, "testdata/rsa.pem"), "RS256", "unknown-rsa", testClaims(nil))
+	_, err = verifier.Verify(context.Background(), known)
+	assert.NoError(t, err)
+
+	verifier.Keys.mu.Lock()
+	verifier.Keys.loadedAt = time.Now().Add(-time.Hour)
+	verifier.Keys.mu.Unlock()
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := verifier.Verify(context.Background(), unknown)
+			assert.ErrorIs(t, err, ErrTokenInvalid)
+		}()
+	}
+
+	<-fetching
+	_, err = verifier.Verify(context.Background(), known)
+	assert.NoError(t, err, "known keys are verified while the jwks is fetched")
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(2), fetches.Load(), "concurrent requests share a single fetch")
+}
+
+func TestJWKS_Unavailable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	token := signToken(t, loadTestKey(t, "testdata/rsa.pem"), "RS256", "test-rsa", testClaims(nil))
+	_, err := testVerifier(server.URL).Verify(context.Background(), token)
+
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrTokenInvalid)
+}
@@ -0,0 +1,61 @@
+package commands
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/kjj1998/url-shortener-go/internal/auth"
+	"github.com/kjj1998/url-shortener-go/internal/models"
+	"github.com/kjj1998/url-shortener-go/internal/repository"
+)
+
+// CreateApiKey creates an API key in the configured storage backend, printing the key to authenticate with
+func CreateApiKey(args []string) error {
+	var owner, scopes, name string
+	flags := flag.NewFlagSet("create-api-key", flag.ContinueOnError)
+	flags.StringVar(&owner, "owner", "", "owner stamped on the shortened urls created with the key")
+	flags.StringVar(&scopes, "scopes", models.ScopeCreate, "comma-separated scopes: "+strings.Join(models.Scopes, ", "))
+	flags.StringVar(&name, "name", "", "name telling the key apart from the other keys of its owner")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	request := models.NewApiKey{Owner: owner, Scopes: strings.Split(scopes, ","), Name: name}
+	if err := request.Validation(); err != nil {
+		return err
+	}
+
+	created, err := auth.NewApiKey(request, time.Now())
+	if err != nil {
+		return err
+	}
+	if err := repository.ApiKeys.AddApiKey(context.Background(), created.ApiKey); err != nil {
+		return err
+	}
+	log.Printf("Created api key %v for %v with scopes %v\n", created.Id, created.Owner, strings.Join(created.Scopes, ","))
+	// The key goes to stdout on its own so it can be piped, as it can not be retrieved again
+	fmt.Println(created.Key)
+
+	return nil
+}
+
+// RevokeApiKey revokes an API key in the configured storage backend
+func RevokeApiKey(args []string) error {
+	var id string
+	flags := flag.NewFlagSet("revoke-api-key", flag.ContinueOnError)
+	flags.StringVar(&id, "id", "", "id of the key, the part of the key before the dot")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if err := repository.ApiKeys.RevokeApiKey(context.Background(), id, time.Now()); err != nil {
+		return fmt.Errorf("couldn't revoke api key %v, %w", id, err)
+	}
+	log.Printf("Revoked api key %v\n", id)
+
+	return nil
+}
@@ -0,0 +1,31 @@
+package commands
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// commands maps the name of each subcommand of the binary to its implementation
+var commands = map[string]func(args []string) error{
+	"migrate-dynamodb": MigrateDynamoDb,
+	"export":           Export,
+	"import":           Import,
+	"create-api-key":   CreateApiKey,
+	"revoke-api-key":   RevokeApiKey,
+}
+
+// Run runs the subcommand named by args[0], passing it the remaining arguments as its flags
+func Run(args []string) error {
+	command, ok := commands[args[0]]
+	if !ok {
+		names := make([]string, 0, len(commands))
+		for name := range commands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("%v command is not recognised, available commands: %v", args[0], strings.Join(names, ", "))
+	}
+
+	return command(args[1:])
+}
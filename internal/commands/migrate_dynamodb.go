@@ -0,0 +1,41 @@
+package commands
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/kjj1998/url-shortener-go/constants"
+	"github.com/kjj1998/url-shortener-go/internal/repository"
+)
+
+// MigrateDynamoDb copies the entries of the legacy DynamoDB table, partitioned by Id, into the table
+// partitioned by ShortUrl. The service keeps serving the legacy entries while they are copied
+func MigrateDynamoDb(args []string) error {
+	client, err := repository.NewTableClient(os.Getenv("ENVIRONMENT"))
+	if err != nil {
+		return err
+	}
+
+	legacyTableName := client.LegacyTableName
+	if legacyTableName == "" {
+		legacyTableName = constants.LegacyTableName
+	}
+
+	flags := flag.NewFlagSet("migrate-dynamodb", flag.ContinueOnError)
+	flags.StringVar(&client.TableName, "table", client.TableName, "table partitioned by ShortUrl to copy entries into")
+	flags.StringVar(&client.LegacyTableName, "legacy-table", legacyTableName, "table partitioned by Id to copy entries from")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	log.Printf("Migrating entries from %v to %v\n", client.LegacyTableName, client.TableName)
+	copied, skipped, err := client.MigrateLegacyTable(context.Background())
+	log.Printf("Copied %d entries, skipped %d entries already in %v\n", copied, skipped, client.TableName)
+	if err == nil && copied == 0 {
+		log.Printf("Every entry of %v is in %v, DYNAMODB_LEGACY_TABLE can be unset\n", client.LegacyTableName, client.TableName)
+	}
+
+	return err
+}
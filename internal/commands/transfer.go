@@ -0,0 +1,76 @@
+package commands
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"io"
+	"log"
+	"os"
+
+	"github.com/kjj1998/url-shortener-go/internal/repository"
+	"github.com/kjj1998/url-shortener-go/internal/transfer"
+)
+
+// Export writes every shortened URL of the configured storage backend to a file, or to stdout
+func Export(args []string) (err error) {
+	var format, out string
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	flags.StringVar(&format, "format", string(transfer.JsonLines), "format of the export, jsonl or csv")
+	flags.StringVar(&out, "out", "", "file to export to, stdout when empty")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if out != "" {
+		file, err := os.Create(out)
+		if err != nil {
+			return err
+		}
+		// Closing the file can report a failed write, which makes the export incomplete
+		defer func() {
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+		}()
+		w = file
+	}
+
+	buffered := bufio.NewWriter(w)
+	exported, err := transfer.Export(context.Background(), repository.Client, buffered, transfer.Format(format))
+	if err == nil {
+		err = buffered.Flush()
+	}
+	log.Printf("Exported %d shortened urls\n", exported)
+
+	return err
+}
+
+// Import adds the shortened URLs of an export to the configured storage backend, from a file or from stdin
+func Import(args []string) error {
+	var format, conflict, in string
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	flags.StringVar(&format, "format", string(transfer.JsonLines), "format of the export, jsonl or csv")
+	flags.StringVar(&conflict, "conflict", string(transfer.Skip), "what to do with shortened urls already taken: skip, overwrite or fail")
+	flags.StringVar(&in, "in", "", "file to import from, stdin when empty")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var r io.Reader = os.Stdin
+	if in != "" {
+		file, err := os.Open(in)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		r = file
+	}
+
+	result, err := transfer.Import(context.Background(), repository.Client, bufio.NewReader(r), transfer.Format(format),
+		transfer.ConflictPolicy(conflict))
+	log.Printf("Imported %d shortened urls, skipped %d already taken\n", result.Imported, result.Skipped)
+
+	return err
+}
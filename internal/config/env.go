@@ -0,0 +1,71 @@
+package config
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// String returns the value of the environment variable key, or fallback when it is not set
+func String(key string, fallback string) string {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback
+	}
+	return value
+}
+
+// List returns the comma-separated values of the environment variable key, or nil when it is not set
+func List(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// Int returns the integer value of the environment variable key, or fallback when it is not set
+func Int(key string, fallback int) int {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback
+	}
+
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		log.Fatalf("%v is not a valid integer for environment variable %v", value, key)
+	}
+	return i
+}
+
+// Bool returns the boolean value (e.g. true, false, 1, 0) of the environment variable key, or fallback when it is not set
+func Bool(key string, fallback bool) bool {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Fatalf("%v is not a valid boolean for environment variable %v", value, key)
+	}
+	return b
+}
+
+// Duration returns the duration value (e.g. 5m, 30s) of the environment variable key, or fallback when it is not set
+func Duration(key string, fallback time.Duration) time.Duration {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Fatalf("%v is not a valid duration for environment variable %v", value, key)
+	}
+	return d
+}
@@ -0,0 +1,92 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/kjj1998/url-shortener-go/constants"
+)
+
+// Scopes of an API key, each granting the requests of the /data group of one kind. ScopeAdmin grants every other
+// scope, along with the /admin group
+const (
+	ScopeCreate = "create"
+	ScopeRead   = "read"
+	ScopeUpdate = "update"
+	ScopeDelete = "delete"
+	ScopeAdmin  = "admin"
+)
+
+// Scopes are the scopes an API key may be granted
+var Scopes = []string{ScopeCreate, ScopeRead, ScopeUpdate, ScopeDelete, ScopeAdmin}
+
+var (
+	ErrApiKeyOwner  = errors.New("owner must be set")
+	ErrApiKeyScopes = fmt.Errorf("scopes must hold at least one of %v", strings.Join(Scopes, ", "))
+	ErrApiKeyName   = fmt.Errorf("name must be at most %d characters long", constants.ApiKeyNameMaxLength)
+)
+
+// ApiKey authenticates the requests of an owner. Only the hash of its secret is stored, the key itself is handed out
+// once when it is created
+type ApiKey struct {
+	// Id is the public part of the key, under which it is stored
+	Id     string   `json:"id"`
+	Owner  string   `json:"owner"`
+	Scopes []string `json:"scopes" dynamodbav:",stringset"`
+	Name   string   `json:"name,omitempty" dynamodbav:",omitempty"`
+	// SecretHash is the hex-encoded SHA-256 hash of the secret part of the key, it is never sent to clients
+	SecretHash string     `json:"-"`
+	CreatedAt  *time.Time `json:"createdAt,omitempty" dynamodbav:",omitempty,unixtime"`
+	// RevokedAt is set once the key is revoked, after which it authenticates nothing
+	RevokedAt *time.Time `json:"revokedAt,omitempty" dynamodbav:",omitempty,unixtime"`
+}
+
+// Revoked reports whether the key has been revoked
+func (k ApiKey) Revoked() bool {
+	return k.RevokedAt != nil
+}
+
+// HasScope reports whether the key grants scope
+func (k ApiKey) HasScope(scope string) bool {
+	return GrantsScope(k.Scopes, scope)
+}
+
+// GrantsScope reports whether scopes grant scope, which ScopeAdmin does for every scope
+func GrantsScope(scopes []string, scope string) bool {
+	return slices.Contains(scopes, scope) || slices.Contains(scopes, ScopeAdmin)
+}
+
+// NewApiKey is a request to create an API key
+type NewApiKey struct {
+	Owner  string   `json:"owner" example:"marketing"`
+	Scopes []string `json:"scopes" example:"create,read"`
+	Name   string   `json:"name,omitempty" example:"campaign tooling"`
+}
+
+func (k NewApiKey) Validation() error {
+	if strings.TrimSpace(k.Owner) == "" {
+		return ErrApiKeyOwner
+	}
+	if len(k.Scopes) == 0 {
+		return ErrApiKeyScopes
+	}
+	for _, scope := range k.Scopes {
+		if !slices.Contains(Scopes, scope) {
+			return fmt.Errorf("unknown scope %q, %w", scope, ErrApiKeyScopes)
+		}
+	}
+	if utf8.RuneCountInString(k.Name) > constants.ApiKeyNameMaxLength {
+		return ErrApiKeyName
+	}
+	return nil
+}
+
+// CreatedApiKey is a newly created API key along with the key to authenticate with, which can not be retrieved later
+type CreatedApiKey struct {
+	ApiKey
+	Key string `json:"key" example:"3f9c1a7e5b2d4c60.q8VwXj2N0m1Ck4s9Lr7TzYb5HdGa3EuPfKoWi6Sn"`
+}
@@ -0,0 +1,10 @@
+package models
+
+type CacheStats struct {
+	Hits                uint64 `json:"hits"`
+	Misses              uint64 `json:"misses"`
+	NegativeHits        uint64 `json:"negativeHits"`
+	BloomFilterEnabled  bool   `json:"bloomFilterEnabled"`
+	BloomRejections     uint64 `json:"bloomRejections"`
+	BloomFalsePositives uint64 `json:"bloomFalsePositives"`
+}
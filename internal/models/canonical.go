@@ -0,0 +1,157 @@
+package models
+
+import (
+	"net"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+)
+
+// defaultPorts are the ports dropped from the long URLs of each scheme
+var defaultPorts = map[string]string{"http": "80", "https": "443"}
+
+// DefaultTrackingParams are the query parameters stripped by CanonicalUrl when tracking parameters are stripped and no
+// others are configured. A trailing '*' matches every parameter starting with what comes before it
+var DefaultTrackingParams = []string{
+	"utm_*", "fbclid", "gclid", "dclid", "gbraid", "wbraid", "msclkid", "yclid", "mc_cid", "mc_eid", "igshid",
+	"_hsenc", "_hsmkt",
+}
+
+// CanonicalUrl returns the canonical form of a long URL, so that spellings of the same destination are stored alike.
+// The scheme and host are lowercased, internationalized host names are converted to punycode, the default port of
+// the scheme is dropped, and the "." and ".." segments of the path are resolved. Query parameters matching one of
+// trackingParams are removed, the others keep their order and encoding. Long URLs without a host are only parsed
+// Returns ErrLongUrlInvalid when the long URL does not parse or its host is not a valid domain name
+func CanonicalUrl(longUrl string, trackingParams []string) (string, error) {
+	parsed, err := url.Parse(longUrl)
+	if err != nil {
+		return "", ErrLongUrlInvalid
+	}
+	if parsed.Host == "" {
+		return longUrl, nil
+	}
+
+	parsed.Scheme = strings.ToLower(parsed.Scheme)
+	if parsed.Host, err = canonicalHost(parsed.Scheme, parsed.Hostname(), parsed.Port()); err != nil {
+		return "", err
+	}
+
+	if escapedPath := removeDotSegments(parsed.EscapedPath()); escapedPath != parsed.EscapedPath() {
+		if parsed.Path, err = url.PathUnescape(escapedPath); err != nil {
+			return "", ErrLongUrlInvalid
+		}
+		parsed.RawPath = escapedPath
+	}
+
+	if len(trackingParams) > 0 && parsed.RawQuery != "" {
+		parsed.RawQuery = stripQueryParams(parsed.RawQuery, trackingParams)
+	}
+	return parsed.String(), nil
+}
+
+// DestinationOf returns the canonical form of a long URL without stripping any query parameter, or the long URL as it
+// is when it has none
+func DestinationOf(longUrl string) string {
+	canonical, err := CanonicalUrl(longUrl, nil)
+	if err != nil {
+		return longUrl
+	}
+	return canonical
+}
+
+// canonicalHost lowercases hostname, converting it to punycode when it is internationalized, and joins it with port
+// unless it is the default port of scheme
+func canonicalHost(scheme string, hostname string, port string) (string, error) {
+	hostname, err := asciiHostname(hostname)
+	if err != nil {
+		return "", err
+	}
+
+	if port == "" || port == defaultPorts[scheme] {
+		if strings.Contains(hostname, ":") {
+			return "[" + hostname + "]", nil
+		}
+		return hostname, nil
+	}
+	return net.JoinHostPort(hostname, port), nil
+}
+
+// asciiHostname lowercases hostname, converting it to punycode when it is internationalized
+func asciiHostname(hostname string) (string, error) {
+	hostname = strings.ToLower(hostname)
+	if isAscii(hostname) {
+		return hostname, nil
+	}
+	hostname, err := idna.Lookup.ToASCII(hostname)
+	if err != nil {
+		return "", ErrLongUrlInvalid
+	}
+	return hostname, nil
+}
+
+func isAscii(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// removeDotSegments resolves the "." and ".." segments of an absolute path as described in RFC 3986 section 5.2.4.
+// A ".." segment never climbs above the root, and a path ending in a dot segment keeps its trailing slash
+func removeDotSegments(path string) string {
+	if !strings.Contains(path, ".") {
+		return path
+	}
+
+	segments := strings.Split(path, "/")
+	resolved := make([]string, 0, len(segments))
+	for i, segment := range segments {
+		last := i == len(segments)-1
+		switch segment {
+		case ".":
+		case "..":
+			if len(resolved) > 1 {
+				resolved = resolved[:len(resolved)-1]
+			}
+		default:
+			resolved = append(resolved, segment)
+			continue
+		}
+		if last {
+			resolved = append(resolved, "")
+		}
+	}
+	return strings.Join(resolved, "/")
+}
+
+// stripQueryParams removes the parameters of rawQuery whose name matches one of params
+func stripQueryParams(rawQuery string, params []string) string {
+	var kept []string
+	for _, param := range strings.Split(rawQuery, "&") {
+		name, _, _ := strings.Cut(param, "=")
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+		if !matchesParam(name, params) {
+			kept = append(kept, param)
+		}
+	}
+	return strings.Join(kept, "&")
+}
+
+// matchesParam reports whether the parameter name matches one of params, ignoring case
+func matchesParam(name string, params []string) bool {
+	name = strings.ToLower(name)
+	for _, param := range params {
+		param = strings.ToLower(param)
+		prefix, wildcard := strings.CutSuffix(param, "*")
+		if (wildcard && strings.HasPrefix(name, prefix)) || name == param {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	ErrLongUrlInvalid     = &UrlError{"url_invalid", "longUrl is not a valid url"}
+	ErrLongUrlControl     = &UrlError{"url_control_character", "longUrl may not contain whitespace or control characters"}
+	ErrLongUrlHostMissing = &UrlError{"url_host_missing", "longUrl must have a host"}
+	ErrLongUrlPrivate     = &UrlError{"url_private_address", "longUrl may not point to a private, loopback or link-local address"}
+	ErrLongUrlUnresolved  = &UrlError{"url_host_unresolved", "the host of longUrl does not resolve"}
+	ErrLongUrlSelf        = &UrlError{"url_self_reference", "longUrl may not point to this service"}
+	ErrLongUrlSelfTarget  = &UrlError{"url_self_reference_unresolved", "longUrl points to a shortened url of this service that is missing, deleted or restricted"}
+	ErrLongUrlLoop        = &UrlError{"url_redirect_loop", "longUrl leads to a redirect loop"}
+)
+
+// UrlError is a reason for refusing a long URL, with a code that tells it apart from the other reasons
+type UrlError struct {
+	code    string
+	message string
+}
+
+func (e *UrlError) Error() string {
+	return e.message
+}
+
+// ErrorCode returns the code of the reason, e.g. url_scheme_not_allowed
+func (e *UrlError) ErrorCode() string {
+	return e.code
+}
+
+// DestinationPolicy holds the rules long URLs have to follow to be shortened
+type DestinationPolicy struct {
+	// Schemes are the lowercased schemes long URLs may have
+	Schemes []string
+	// MaxLength is the most bytes a long URL may have, 0 for no limit
+	MaxLength int
+	// BlockPrivate refuses long URLs whose host is or resolves to a private, loopback, link-local or unspecified address
+	BlockPrivate bool
+	// LookupIPAddr resolves the host names of long URLs when BlockPrivate is set
+	LookupIPAddr func(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// Validate checks a long URL against the policy, returning the UrlError of the first rule it breaks.
+// When BlockPrivate is set, host names are resolved and refused if any of their addresses is private
+func (p DestinationPolicy) Validate(ctx context.Context, longUrl string) error {
+	if strings.IndexFunc(longUrl, func(r rune) bool { return unicode.IsControl(r) || unicode.IsSpace(r) }) >= 0 {
+		return ErrLongUrlControl
+	}
+	if !utf8.ValidString(longUrl) {
+		return ErrLongUrlInvalid
+	}
+	if p.MaxLength > 0 && len(longUrl) > p.MaxLength {
+		return &UrlError{"url_too_long", fmt.Sprintf("longUrl must be at most %d characters long", p.MaxLength)}
+	}
+
+	parsed, err := url.Parse(longUrl)
+	if err != nil {
+		return ErrLongUrlInvalid
+	}
+	if !slices.Contains(p.Schemes, strings.ToLower(parsed.Scheme)) {
+		return &UrlError{"url_scheme_not_allowed", "longUrl scheme must be one of " + strings.Join(p.Schemes, ", ")}
+	}
+	if parsed.Hostname() == "" {
+		return ErrLongUrlHostMissing
+	}
+	if !p.BlockPrivate {
+		return nil
+	}
+
+	hostname, err := asciiHostname(parsed.Hostname())
+	if err != nil {
+		return err
+	}
+	return p.checkAddresses(ctx, hostname)
+}
+
+// checkAddresses refuses hostname when it is, or resolves to, an address that is not public
+func (p DestinationPolicy) checkAddresses(ctx context.Context, hostname string) error {
+	if ip := net.ParseIP(hostname); ip != nil {
+		if isPrivateIP(ip) {
+			return ErrLongUrlPrivate
+		}
+		return nil
+	}
+
+	addrs, err := p.LookupIPAddr(ctx, hostname)
+	if err != nil || len(addrs) == 0 {
+		return ErrLongUrlUnresolved
+	}
+	for _, addr := range addrs {
+		if isPrivateIP(addr.IP) {
+			return ErrLongUrlPrivate
+		}
+	}
+	return nil
+}
+
+// isPrivateIP reports whether ip is not reachable on the internet, IPv4-mapped IPv6 addresses included
+func isPrivateIP(ip net.IP) bool {
+	return ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsUnspecified()
+}
@@ -0,0 +1,90 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDestinationPolicy_Validate(t *testing.T) {
+	hosts := map[string][]string{
+		"example.com":           {"93.184.215.14", "2606:2800:21f:cb07:6820:80da:af6b:8b2c"},
+		"localhost":             {"127.0.0.1", "::1"},
+		"intranet.example.com":  {"93.184.215.15", "10.0.0.7"},
+		"xn--bcher-kva.example": {"192.0.2.1"},
+	}
+	policy := DestinationPolicy{
+		Schemes:      []string{"http", "https"},
+		MaxLength:    64,
+		BlockPrivate: true,
+		LookupIPAddr: func(ctx context.Context, host string) ([]net.IPAddr, error) {
+			ips, ok := hosts[host]
+			if !ok {
+				return nil, errors.New("no such host")
+			}
+			addrs := make([]net.IPAddr, len(ips))
+			for i, ip := range ips {
+				addrs[i] = net.IPAddr{IP: net.ParseIP(ip)}
+			}
+			return addrs, nil
+		},
+	}
+
+	tests := []struct {
+		name         string
+		longUrl      string
+		blockPrivate bool
+		expectedCode string
+	}{
+		{name: "Public host", longUrl: "https://example.com/a?q=1", blockPrivate: true},
+		{name: "Uppercase scheme", longUrl: "HTTPS://example.com/", blockPrivate: true},
+		{name: "Internationalized host", longUrl: "http://bücher.example/", blockPrivate: true},
+		{name: "Public address", longUrl: "http://93.184.215.14/", blockPrivate: true},
+		{name: "Javascript scheme", longUrl: "javascript:alert(1)", expectedCode: "url_scheme_not_allowed"},
+		{name: "File scheme", longUrl: "file:///etc/passwd", expectedCode: "url_scheme_not_allowed"},
+		{name: "Ftp scheme", longUrl: "ftp://example.com/file", expectedCode: "url_scheme_not_allowed"},
+		{name: "Data scheme", longUrl: "data:text/html,<script>alert(1)</script>", expectedCode: "url_scheme_not_allowed"},
+		{name: "No scheme", longUrl: "example.com/a", expectedCode: "url_scheme_not_allowed"},
+		{name: "No host", longUrl: "http:///a", expectedCode: "url_host_missing"},
+		{name: "Only a port", longUrl: "https://:443/", expectedCode: "url_host_missing"},
+		{name: "Opaque http", longUrl: "http:example.com", expectedCode: "url_host_missing"},
+		{name: "Too long", longUrl: "https://example.com/" + strings.Repeat("a", 45), expectedCode: "url_too_long"},
+		{name: "Longest", longUrl: "https://example.com/" + strings.Repeat("a", 44)},
+		{name: "Newline", longUrl: "https://example.com/a\nb", expectedCode: "url_control_character"},
+		{name: "Null byte", longUrl: "https://example.com/\x00", expectedCode: "url_control_character"},
+		{name: "Tab in scheme", longUrl: "java\tscript:alert(1)", expectedCode: "url_control_character"},
+		{name: "Space", longUrl: "https://example.com/a b", expectedCode: "url_control_character"},
+		{name: "Unicode line separator", longUrl: "https://example.com/a\u2028", expectedCode: "url_control_character"},
+		{name: "Invalid utf-8", longUrl: "https://example.com/\xff", expectedCode: "url_invalid"},
+		{name: "Unparseable", longUrl: "https://example.com:port/", expectedCode: "url_invalid"},
+		{name: "Private address allowed", longUrl: "http://10.0.0.1/"},
+		{name: "Private address", longUrl: "http://10.0.0.1/", blockPrivate: true, expectedCode: "url_private_address"},
+		{name: "Loopback address", longUrl: "http://127.0.0.1:8080/", blockPrivate: true, expectedCode: "url_private_address"},
+		{name: "Link-local address", longUrl: "http://169.254.169.254/latest/meta-data", blockPrivate: true, expectedCode: "url_private_address"},
+		{name: "Unspecified address", longUrl: "http://0.0.0.0/", blockPrivate: true, expectedCode: "url_private_address"},
+		{name: "IPv6 loopback", longUrl: "http://[::1]/", blockPrivate: true, expectedCode: "url_private_address"},
+		{name: "IPv6 unique local", longUrl: "http://[fd00::1]/", blockPrivate: true, expectedCode: "url_private_address"},
+		{name: "IPv4-mapped IPv6", longUrl: "http://[::ffff:192.168.0.1]/", blockPrivate: true, expectedCode: "url_private_address"},
+		{name: "Loopback host", longUrl: "http://LOCALHOST/", blockPrivate: true, expectedCode: "url_private_address"},
+		{name: "Host with a private address", longUrl: "https://intranet.example.com/", blockPrivate: true, expectedCode: "url_private_address"},
+		{name: "Unresolved host", longUrl: "https://unknown.example/", blockPrivate: true, expectedCode: "url_host_unresolved"},
+		{name: "Unresolved host allowed", longUrl: "https://unknown.example/"},
+	}
+
+	for _, tt := range tests {
+		policy.BlockPrivate = tt.blockPrivate
+		err := policy.Validate(context.Background(), tt.longUrl)
+		if tt.expectedCode == "" {
+			assert.NoError(t, err, tt.name)
+			continue
+		}
+		var urlErr *UrlError
+		if assert.ErrorAs(t, err, &urlErr, tt.name) {
+			assert.Equal(t, tt.expectedCode, urlErr.ErrorCode(), tt.name)
+		}
+	}
+}
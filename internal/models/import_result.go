@@ -0,0 +1,8 @@
+package models
+
+// ImportResult counts the shortened URLs of an import that were imported, and the ones skipped as already taken or
+// already imported
+type ImportResult struct {
+	Imported int `json:"imported"`
+	Skipped  int `json:"skipped"`
+}
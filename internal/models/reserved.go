@@ -0,0 +1,57 @@
+package models
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+)
+
+var (
+	reservedMu sync.RWMutex
+	// reservedWords are lowercased path segments of the API that no shortened URL may shadow
+	reservedWords = map[string]struct{}{"health": {}, "swagger": {}, "data": {}, "api": {}, "docs": {}, "admin": {}}
+)
+
+// ReserveWords adds words to the path segments that can not be used as shortened URLs
+func ReserveWords(words ...string) {
+	reservedMu.Lock()
+	defer reservedMu.Unlock()
+
+	for _, word := range words {
+		if word != "" {
+			reservedWords[strings.ToLower(word)] = struct{}{}
+		}
+	}
+}
+
+// ReservedWords returns the reserved words, lowercased and sorted
+func ReservedWords() []string {
+	reservedMu.RLock()
+	defer reservedMu.RUnlock()
+
+	return slices.Sorted(maps.Keys(reservedWords))
+}
+
+// SaveReservedWords returns a function restoring the reserved words to the ones reserved now, so tests reserving
+// words of their own can undo them
+func SaveReservedWords() (restore func()) {
+	reservedMu.RLock()
+	saved := maps.Clone(reservedWords)
+	reservedMu.RUnlock()
+
+	return func() {
+		reservedMu.Lock()
+		defer reservedMu.Unlock()
+		reservedWords = saved
+	}
+}
+
+// IsReserved reports whether shortUrl is a reserved word, compared case-insensitively
+func IsReserved(shortUrl string) bool {
+	reservedMu.RLock()
+	defer reservedMu.RUnlock()
+
+	_, ok := reservedWords[strings.ToLower(shortUrl)]
+	return ok
+}
@@ -0,0 +1,15 @@
+package models
+
+// ShortenResult is the outcome of shortening one URL of a batch, holding either the shortened URL or the error
+type ShortenResult struct {
+	Status int    `json:"status" example:"201"`
+	Url    *Url   `json:"url,omitempty"`
+	Error  string `json:"error,omitempty"`
+	// ErrorCode tells apart the errors with the same status, for the errors that have one
+	ErrorCode string `json:"errorCode,omitempty" example:"url_scheme_not_allowed"`
+}
+
+// ShortenBatchResult holds the outcome of shortening each URL of a batch, in the order of the batch
+type ShortenBatchResult struct {
+	Results []ShortenResult `json:"results"`
+}
@@ -0,0 +1,108 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/kjj1998/url-shortener-go/constants"
+)
+
+var (
+	ErrTagsCount  = fmt.Errorf("at most %d tags may be set", constants.TagsMaxCount)
+	ErrTagLength  = fmt.Errorf("tags must be between 1 and %d characters long", constants.TagMaxLength)
+	ErrTagCharset = errors.New("tags may not contain ','")
+	ErrMergeTags  = errors.New("from must hold at least one tag other than to")
+)
+
+// NormalizeTag returns the stored form of a tag, trimmed and lowercased so tags differing in case are the same tag
+func NormalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
+// NormalizeTags returns the stored form of a set of tags, normalized, sorted and without duplicates
+func NormalizeTags(tags []string) []string {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		normalized = append(normalized, NormalizeTag(tag))
+	}
+	slices.Sort(normalized)
+	return slices.Compact(normalized)
+}
+
+func validateTags(tags []string) error {
+	normalized := NormalizeTags(tags)
+	if len(normalized) > constants.TagsMaxCount {
+		return ErrTagsCount
+	}
+	for _, tag := range normalized {
+		if err := validateTag(tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateTag checks a normalized tag. Tags are stored comma-delimited by the SQL backends, so they may not hold commas
+func validateTag(tag string) error {
+	switch {
+	case len(tag) == 0 || utf8.RuneCountInString(tag) > constants.TagMaxLength:
+		return ErrTagLength
+	case strings.Contains(tag, ","):
+		return ErrTagCharset
+	default:
+		return nil
+	}
+}
+
+// TagMerge replaces the tags in From with To on every shortened URL. Renaming a tag is merging it alone into its new name
+type TagMerge struct {
+	From []string `json:"from" example:"golang"`
+	To   string   `json:"to" example:"go"`
+	// Owner limits the merge to the shortened URLs of an owner when set. It is the caller, unless they are an admin
+	Owner string `json:"-"`
+}
+
+func (m TagMerge) Validation() error {
+	if err := validateTag(NormalizeTag(m.To)); err != nil {
+		return err
+	}
+
+	sources := m.Sources()
+	if len(sources) == 0 {
+		return ErrMergeTags
+	}
+	for _, tag := range sources {
+		if err := validateTag(tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Sources returns the normalized tags merged into To, leaving out To itself
+func (m TagMerge) Sources() []string {
+	to := NormalizeTag(m.To)
+	return slices.DeleteFunc(NormalizeTags(m.From), func(tag string) bool { return tag == to })
+}
+
+// Merged returns tags after the merge, or false when it holds none of the merged tags
+func (m TagMerge) Merged(tags []string) ([]string, bool) {
+	sources := m.Sources()
+	merged := slices.DeleteFunc(slices.Clone(tags), func(tag string) bool { return slices.Contains(sources, tag) })
+	if len(merged) == len(tags) {
+		return tags, false
+	}
+	return NormalizeTags(append(merged, m.To)), true
+}
+
+// TagMergeResult reports the number of shortened URLs whose tags were changed by a merge
+type TagMergeResult struct {
+	Links int `json:"links"`
+}
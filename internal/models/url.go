@@ -1,24 +1,182 @@
 package models
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
 
-var ErrNameInvalid = errors.New("invalid parameter names in json body")
+	"github.com/kjj1998/url-shortener-go/constants"
+)
+
+var (
+	ErrNameInvalid    = errors.New("invalid parameter names in json body")
+	ErrAliasLength    = fmt.Errorf("alias must be between %d and %d characters long", constants.AliasMinLength, constants.AliasMaxLength)
+	ErrAliasCharset   = errors.New("alias may only contain letters, digits, '-' and '_'")
+	ErrAliasReserved  = errors.New("alias is a reserved word")
+	ErrExpiryTwice    = errors.New("only one of expiresAt and ttlSeconds may be set")
+	ErrExpiryPast     = errors.New("expiresAt must be in the future")
+	ErrTtlInvalid     = errors.New("ttlSeconds must be positive")
+	ErrMaxClicks      = errors.New("maxClicks must be positive")
+	ErrPasswordLength = fmt.Errorf("password must be at most %d bytes long", constants.PasswordMaxLength)
+	ErrTitleLength    = fmt.Errorf("title must be at most %d characters long", constants.TitleMaxLength)
+	ErrDescLength     = fmt.Errorf("description must be at most %d characters long", constants.DescriptionMaxLength)
+	ErrNotesLength    = fmt.Errorf("notes must be at most %d characters long", constants.NotesMaxLength)
+	ErrReuseOptions   = errors.New("reuse can not be combined with alias, expiresAt, ttlSeconds, maxClicks or password")
+)
+
+var aliasCharset = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
 
 type Url struct {
 	Id       uint64 `json:"id"`
 	ShortUrl string `json:"shortUrl"`
 	LongUrl  string `json:"longUrl"`
+	// ExpiresAt is when the shortened URL stops redirecting, stored as epoch seconds so DynamoDB's TTL can reap it
+	ExpiresAt *time.Time `json:"expiresAt,omitempty" dynamodbav:",omitempty,unixtime"`
+	// MaxClicks is the number of redirects the shortened URL serves, 0 for unlimited. Clicks counts the ones
+	// served so far, and is only kept for shortened URLs with a MaxClicks
+	MaxClicks int64 `json:"maxClicks,omitempty" dynamodbav:",omitempty"`
+	Clicks    int64 `json:"clicks,omitempty" dynamodbav:",omitempty"`
+	// PasswordHash is the bcrypt hash of the password required to follow the shortened URL, it is never sent to clients
+	PasswordHash string `json:"-" dynamodbav:",omitempty"`
+	// DeletedAt marks the entry as the tombstone of a deleted shortened URL, kept so the shortened URL is never reissued
+	DeletedAt *time.Time `json:"deletedAt,omitempty" dynamodbav:",omitempty,unixtime"`
+	// CreatedAt is unset for shortened URLs created before it was recorded
+	CreatedAt *time.Time `json:"createdAt,omitempty" dynamodbav:",omitempty,unixtime"`
+	Owner     string     `json:"owner,omitempty" dynamodbav:",omitempty"`
+	// Domain is the host of LongUrl, stored so shortened URLs can be listed by destination domain
+	Domain string `json:"-" dynamodbav:",omitempty"`
+	// Destination is LongUrl canonicalized by DestinationOf, stored so shortened URLs can be reused for the same destination
+	Destination string `json:"-" dynamodbav:",omitempty"`
+	Title       string `json:"title,omitempty" dynamodbav:",omitempty"`
+	Description string `json:"description,omitempty" dynamodbav:",omitempty"`
+	Notes       string `json:"notes,omitempty" dynamodbav:",omitempty"`
+	// Tags are normalized by NormalizeTags, and stored as a string set so DynamoDB can filter on them
+	Tags []string `json:"tags,omitempty" dynamodbav:",omitempty,stringset"`
+}
+
+// Expired reports whether the shortened URL has an expiry that is not after now
+func (u Url) Expired(now time.Time) bool {
+	return u.ExpiresAt != nil && !u.ExpiresAt.After(now)
+}
+
+// DomainOf returns the lowercased host of a long URL, without its port, or an empty string when it has none
+func DomainOf(longUrl string) string {
+	parsed, err := url.Parse(longUrl)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(parsed.Hostname())
+}
+
+// Deleted reports whether the entry is the tombstone of a deleted shortened URL
+func (u Url) Deleted() bool {
+	return u.DeletedAt != nil
+}
+
+// Reusable reports whether the shortened URL may be handed out again for a request to shorten its destination,
+// which is only the case for live shortened URLs that anyone can follow for as long as they exist
+func (u Url) Reusable() bool {
+	return !u.Deleted() && u.ExpiresAt == nil && u.MaxClicks == 0 && u.PasswordHash == ""
 }
 
 type LongUrl struct {
 	LongUrl string `json:"longUrl"`
+	// Alias is an optional custom shortened URL, e.g. spring-sale
+	Alias string `json:"alias,omitempty" example:"spring-sale"`
+	// ExpiresAt and TtlSeconds optionally limit the lifetime of the shortened URL, at most one of them may be set
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty" example:"2030-01-01T00:00:00Z"`
+	TtlSeconds int64      `json:"ttlSeconds,omitempty" example:"86400"`
+	// MaxClicks optionally limits the number of redirects served by the shortened URL, 1 for a one-time link
+	MaxClicks int64 `json:"maxClicks,omitempty" example:"1"`
+	// Password optionally has to be given before the shortened URL redirects
+	Password    string   `json:"password,omitempty"`
+	Title       string   `json:"title,omitempty" example:"Spring sale"`
+	Description string   `json:"description,omitempty"`
+	Notes       string   `json:"notes,omitempty"`
+	Tags        []string `json:"tags,omitempty" example:"marketing,spring"`
+	// Reuse returns the shortened URL already created by the owner for the same destination, if there is one,
+	// instead of creating another one
+	Reuse bool `json:"reuse,omitempty"`
 }
 
 func (l LongUrl) Validation() error {
-	switch {
-	case len(l.LongUrl) == 0:
+	if len(l.LongUrl) == 0 {
 		return ErrNameInvalid
+	}
+	if err := l.validateExpiry(time.Now()); err != nil {
+		return err
+	}
+	if l.MaxClicks < 0 {
+		return ErrMaxClicks
+	}
+	if len(l.Password) > constants.PasswordMaxLength {
+		return ErrPasswordLength
+	}
+	if err := validateMetadata(l.Title, l.Description, l.Notes, l.Tags); err != nil {
+		return err
+	}
+	if l.Reuse && (l.Alias != "" || l.ExpiresAt != nil || l.TtlSeconds != 0 || l.MaxClicks != 0 || l.Password != "") {
+		return ErrReuseOptions
+	}
+
+	switch {
+	case l.Alias == "":
+		return nil
+	case len(l.Alias) < constants.AliasMinLength || len(l.Alias) > constants.AliasMaxLength:
+		return ErrAliasLength
+	case !aliasCharset.MatchString(l.Alias):
+		return ErrAliasCharset
+	case IsReserved(l.Alias):
+		return ErrAliasReserved
 	default:
 		return nil
 	}
 }
+
+// validateMetadata checks the lengths of the descriptive fields of a shortened URL and its tags
+func validateMetadata(title string, description string, notes string, tags []string) error {
+	switch {
+	case utf8.RuneCountInString(title) > constants.TitleMaxLength:
+		return ErrTitleLength
+	case utf8.RuneCountInString(description) > constants.DescriptionMaxLength:
+		return ErrDescLength
+	case utf8.RuneCountInString(notes) > constants.NotesMaxLength:
+		return ErrNotesLength
+	default:
+		return validateTags(tags)
+	}
+}
+
+func (l LongUrl) validateExpiry(now time.Time) error {
+	switch {
+	case l.ExpiresAt != nil && l.TtlSeconds != 0:
+		return ErrExpiryTwice
+	case l.ExpiresAt != nil && !l.ExpiresAt.After(now):
+		return ErrExpiryPast
+	case l.TtlSeconds < 0:
+		return ErrTtlInvalid
+	default:
+		return nil
+	}
+}
+
+// Expiry returns when a shortened URL requested at now expires, or nil when it never does.
+// It is truncated to the second, the precision it is stored with
+func (l LongUrl) Expiry(now time.Time) *time.Time {
+	var expiresAt time.Time
+	switch {
+	case l.ExpiresAt != nil:
+		expiresAt = *l.ExpiresAt
+	case l.TtlSeconds > 0:
+		expiresAt = now.Add(time.Duration(l.TtlSeconds) * time.Second)
+	default:
+		return nil
+	}
+
+	expiresAt = expiresAt.UTC().Truncate(time.Second)
+	return &expiresAt
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+// UrlFilter narrows down the shortened URLs that are listed, fields left empty match every shortened URL
+type UrlFilter struct {
+	// CreatedAfter and CreatedBefore bound the creation time, CreatedAfter inclusive and CreatedBefore exclusive
+	CreatedAfter  *time.Time `form:"createdAfter" time_format:"2006-01-02T15:04:05Z07:00"`
+	CreatedBefore *time.Time `form:"createdBefore" time_format:"2006-01-02T15:04:05Z07:00"`
+	// Domain matches the host of the long URL exactly, ignoring case
+	Domain string `form:"domain"`
+	Owner  string `form:"owner"`
+	// Search matches a substring of the long URL or of the shortened URL
+	Search string `form:"q"`
+	// Tag matches the shortened URLs holding the tag, ignoring case
+	Tag string `form:"tag"`
+}
+
+// Matches reports whether url is listed under the filter. Deleted shortened URLs never are
+func (f UrlFilter) Matches(url Url) bool {
+	switch {
+	case url.Deleted():
+		return false
+	case f.CreatedAfter != nil && (url.CreatedAt == nil || url.CreatedAt.Before(*f.CreatedAfter)):
+		return false
+	case f.CreatedBefore != nil && (url.CreatedAt == nil || !url.CreatedAt.Before(*f.CreatedBefore)):
+		return false
+	case f.Domain != "" && url.Domain != strings.ToLower(f.Domain):
+		return false
+	case f.Owner != "" && url.Owner != f.Owner:
+		return false
+	case f.Search != "" && !strings.Contains(url.LongUrl, f.Search) && !strings.Contains(url.ShortUrl, f.Search):
+		return false
+	case f.Tag != "" && !slices.Contains(url.Tags, NormalizeTag(f.Tag)):
+		return false
+	default:
+		return true
+	}
+}
+
+// UrlPage is a page of listed shortened URLs. NextCursor fetches the next page, it is empty on the last one
+type UrlPage struct {
+	Links      []Url  `json:"links"`
+	NextCursor string `json:"nextCursor,omitempty"`
+}
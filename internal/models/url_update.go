@@ -0,0 +1,104 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/kjj1998/url-shortener-go/constants"
+)
+
+var (
+	ErrNoChanges      = errors.New("no changes in json body")
+	ErrExpiryConflict = errors.New("only one of expiresAt and removeExpiry may be set")
+)
+
+// UrlUpdate holds the changes to an existing shortened URL, fields that are left out are not changed
+type UrlUpdate struct {
+	LongUrl   *string    `json:"longUrl,omitempty" example:"https://example.com"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty" example:"2030-01-01T00:00:00Z"`
+	// RemoveExpiry removes the expiry, so the shortened URL never expires
+	RemoveExpiry bool `json:"removeExpiry,omitempty"`
+	// MaxClicks of 0 removes the click limit
+	MaxClicks *int64 `json:"maxClicks,omitempty" example:"10"`
+	// Password of "" removes the password
+	Password *string `json:"password,omitempty"`
+	// PasswordHash is the hash of Password that is stored, set by the handler
+	PasswordHash *string `json:"-"`
+	// Domain and Destination are the domain and destination of LongUrl that are stored, set by the handler
+	Domain      *string `json:"-"`
+	Destination *string `json:"-"`
+	// Title, Description and Notes of "" remove them
+	Title       *string `json:"title,omitempty" example:"Spring sale"`
+	Description *string `json:"description,omitempty"`
+	Notes       *string `json:"notes,omitempty"`
+	// Tags replace the tags of the shortened URL, an empty list removes them
+	Tags *[]string `json:"tags,omitempty" example:"marketing,spring"`
+}
+
+func (u UrlUpdate) Validation() error {
+	switch {
+	case u.LongUrl == nil && u.ExpiresAt == nil && !u.RemoveExpiry && u.MaxClicks == nil && u.Password == nil &&
+		u.Title == nil && u.Description == nil && u.Notes == nil && u.Tags == nil:
+		return ErrNoChanges
+	case u.ExpiresAt != nil && u.RemoveExpiry:
+		return ErrExpiryConflict
+	case u.LongUrl != nil && len(*u.LongUrl) == 0:
+		return ErrNameInvalid
+	case u.ExpiresAt != nil && !u.ExpiresAt.After(time.Now()):
+		return ErrExpiryPast
+	case u.MaxClicks != nil && *u.MaxClicks < 0:
+		return ErrMaxClicks
+	case u.Password != nil && len(*u.Password) > constants.PasswordMaxLength:
+		return ErrPasswordLength
+	default:
+		return validateMetadata(deref(u.Title), deref(u.Description), deref(u.Notes), deref(u.Tags))
+	}
+}
+
+// deref returns the value p points to, or the zero value when p is nil
+func deref[T any](p *T) T {
+	var zero T
+	if p == nil {
+		return zero
+	}
+	return *p
+}
+
+// Apply returns url with the changes of the update, ExpiresAt being truncated to the second it is stored with
+// and Tags normalized
+func (u UrlUpdate) Apply(url Url) Url {
+	if u.LongUrl != nil {
+		url.LongUrl = *u.LongUrl
+	}
+	if u.ExpiresAt != nil {
+		expiresAt := u.ExpiresAt.UTC().Truncate(time.Second)
+		url.ExpiresAt = &expiresAt
+	} else if u.RemoveExpiry {
+		url.ExpiresAt = nil
+	}
+	if u.MaxClicks != nil {
+		url.MaxClicks = *u.MaxClicks
+	}
+	if u.PasswordHash != nil {
+		url.PasswordHash = *u.PasswordHash
+	}
+	if u.Domain != nil {
+		url.Domain = *u.Domain
+	}
+	if u.Destination != nil {
+		url.Destination = *u.Destination
+	}
+	if u.Title != nil {
+		url.Title = *u.Title
+	}
+	if u.Description != nil {
+		url.Description = *u.Description
+	}
+	if u.Notes != nil {
+		url.Notes = *u.Notes
+	}
+	if u.Tags != nil {
+		url.Tags = NormalizeTags(*u.Tags)
+	}
+	return url
+}
@@ -0,0 +1,107 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// BlockedError is returned for a host matched by a block rule, naming the rule
+type BlockedError struct {
+	Host string
+	Rule Rule
+}
+
+func (e *BlockedError) Error() string {
+	return fmt.Sprintf("%v is blocked by rule %q on line %d of the domain policy", e.Host, e.Rule, e.Rule.Line)
+}
+
+// ErrorCode returns the code of the error, domain_blocked
+func (e *BlockedError) ErrorCode() string {
+	return "domain_blocked"
+}
+
+// DomainPolicy holds the rules of a policy file, reloading them when the file changes. A nil DomainPolicy allows
+// every host
+type DomainPolicy struct {
+	path  string
+	rules atomic.Pointer[RuleSet]
+
+	// mu serializes reloads, modTime and size being those of the file the rules were read from
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+}
+
+// LoadDomainPolicy reads the rules of the policy file at path
+func LoadDomainPolicy(path string) (*DomainPolicy, error) {
+	policy := &DomainPolicy{path: path}
+	if _, err := policy.Reload(); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// Check returns a BlockedError when the most specific rule matching host blocks it
+func (p *DomainPolicy) Check(host string) error {
+	if p == nil {
+		return nil
+	}
+	if rule, ok := p.rules.Load().Match(host); ok && rule.Action == Block {
+		return &BlockedError{Host: host, Rule: rule}
+	}
+	return nil
+}
+
+// Reload reads the rules of the policy file again when its modification time or size changed, reporting whether it
+// did. The rules in use are kept when the file can not be read or holds an invalid rule
+func (p *DomainPolicy) Reload() (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	info, err := os.Stat(p.path)
+	if err != nil {
+		return false, err
+	}
+	if p.rules.Load() != nil && info.ModTime().Equal(p.modTime) && info.Size() == p.size {
+		return false, nil
+	}
+
+	file, err := os.Open(p.path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	rules, err := ParseRules(file)
+	if err != nil {
+		return false, fmt.Errorf("%v: %w", p.path, err)
+	}
+	p.rules.Store(rules)
+	p.modTime, p.size = info.ModTime(), info.Size()
+	return true, nil
+}
+
+// Watch reloads the policy file every interval until ctx is done
+func (p *DomainPolicy) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloaded, err := p.Reload()
+			if err != nil {
+				log.Printf("Couldn't reload domain policy. Here's why: %v\n", err)
+			} else if reloaded {
+				log.Printf("Reloaded domain policy from %v\n", p.path)
+			}
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testRules = `# Phishing domains
+block evil.com
+block *.zip
+allow docs.zip
+block github.io
+block attacker.github.io
+block login.example.com
+block *.ads.example.org
+allow *.evil.com
+block bücher.example
+block 203.0.113.7
+`
+
+func TestRuleSet_Match(t *testing.T) {
+	rules, err := ParseRules(strings.NewReader(testRules))
+	assert.NoError(t, err)
+
+	tests := []struct {
+		host         string
+		expectedRule string
+		expectedLine int
+	}{
+		{host: "evil.com", expectedRule: "block evil.com", expectedLine: 2},
+		{host: "EVIL.com.", expectedRule: "block evil.com", expectedLine: 2},
+		{host: "www.evil.com", expectedRule: "block evil.com", expectedLine: 2},
+		{host: "a.b.evil.com", expectedRule: "block evil.com", expectedLine: 2},
+		{host: "notevil.com"},
+		{host: "archive.zip", expectedRule: "block *.zip", expectedLine: 3},
+		{host: "zip"},
+		{host: "docs.zip", expectedRule: "allow docs.zip", expectedLine: 4},
+		{host: "www.docs.zip", expectedRule: "allow docs.zip", expectedLine: 4},
+		{host: "github.io", expectedRule: "block github.io", expectedLine: 5},
+		{host: "someone.github.io"},
+		{host: "attacker.github.io", expectedRule: "block attacker.github.io", expectedLine: 6},
+		{host: "www.attacker.github.io", expectedRule: "block attacker.github.io", expectedLine: 6},
+		{host: "login.example.com", expectedRule: "block login.example.com", expectedLine: 7},
+		{host: "www.login.example.com"},
+		{host: "example.com"},
+		{host: "ads.example.org"},
+		{host: "x.ads.example.org", expectedRule: "block *.ads.example.org", expectedLine: 8},
+		{host: "xn--bcher-kva.example", expectedRule: "block bücher.example", expectedLine: 10},
+		{host: "shop.bücher.example", expectedRule: "block bücher.example", expectedLine: 10},
+		{host: "203.0.113.7", expectedRule: "block 203.0.113.7", expectedLine: 11},
+		{host: "1.203.0.113.7"},
+	}
+
+	for _, tt := range tests {
+		rule, ok := rules.Match(tt.host)
+		assert.Equal(t, tt.expectedRule != "", ok, tt.host)
+		if ok {
+			assert.Equal(t, tt.expectedRule, rule.String(), tt.host)
+			assert.Equal(t, tt.expectedLine, rule.Line, tt.host)
+		}
+	}
+}
+
+func TestRuleSet_MatchEverything(t *testing.T) {
+	rules, err := ParseRules(strings.NewReader("block *\nallow example.com\nallow 192.0.2.1\n"))
+	assert.NoError(t, err)
+
+	for host, expected := range map[string]Action{
+		"example.com":     Allow,
+		"www.example.com": Allow,
+		"example.org":     Block,
+		"192.0.2.1":       Allow,
+		"192.0.2.2":       Block,
+	} {
+		rule, ok := rules.Match(host)
+		assert.True(t, ok, host)
+		assert.Equal(t, expected, rule.Action, host)
+	}
+}
+
+func TestParseRules_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"deny example.com":      `line 1: action must be allow or block, got "deny"`,
+		"block":                 `line 1: expected an action and a domain pattern, got "block"`,
+		"block a.com b.com":     `line 1: expected an action and a domain pattern, got "block a.com b.com"`,
+		"\n# ok\nblock ex*.com": `line 3: invalid domain pattern "ex*.com"`,
+		"block *.*.example.com": `line 1: invalid domain pattern "*.*.example.com"`,
+		"allow *.":              `line 1: invalid domain pattern "*."`,
+	}
+
+	for content, expected := range tests {
+		_, err := ParseRules(strings.NewReader(content))
+		assert.EqualError(t, err, expected, content)
+	}
+}
+
+func TestDomainPolicy_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "domains.txt")
+	assert.NoError(t, os.WriteFile(path, []byte("block evil.com\n"), 0o644))
+
+	policy, err := LoadDomainPolicy(path)
+	assert.NoError(t, err)
+	assert.EqualError(t, policy.Check("www.evil.com"), `www.evil.com is blocked by rule "block evil.com" on line 1 of the domain policy`)
+	assert.NoError(t, policy.Check("example.com"))
+
+	reloaded, err := policy.Reload()
+	assert.NoError(t, err)
+	assert.False(t, reloaded, "unchanged file")
+
+	assert.NoError(t, os.WriteFile(path, []byte("# Moved\nblock example.com\n"), 0o644))
+	assert.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Minute)))
+	reloaded, err = policy.Reload()
+	assert.NoError(t, err)
+	assert.True(t, reloaded)
+	assert.NoError(t, policy.Check("evil.com"))
+	assert.Error(t, policy.Check("example.com"))
+
+	assert.NoError(t, os.WriteFile(path, []byte("block bad*.com\n"), 0o644))
+	assert.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(2*time.Minute)))
+	_, err = policy.Reload()
+	assert.Error(t, err)
+	assert.Error(t, policy.Check("example.com"), "rules in use are kept")
+
+	var blocked *BlockedError
+	assert.ErrorAs(t, policy.Check("example.com"), &blocked)
+	assert.Equal(t, "domain_blocked", blocked.ErrorCode())
+}
+
+func TestDomainPolicy_Nil(t *testing.T) {
+	var policy *DomainPolicy
+	assert.NoError(t, policy.Check("evil.com"))
+}
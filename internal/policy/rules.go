@@ -0,0 +1,163 @@
+// Package policy decides which destination domains may be shortened and redirected to, from allow and block rules
+// kept in a file
+package policy
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+	"golang.org/x/net/publicsuffix"
+)
+
+// Action is what a rule does with the domains it matches
+type Action string
+
+const (
+	Allow Action = "allow"
+	Block Action = "block"
+)
+
+// Rule is a line of the policy file, e.g. "block *.example.com"
+type Rule struct {
+	Action  Action
+	Pattern string
+	// Line is the line of the policy file holding the rule
+	Line int
+}
+
+func (r Rule) String() string {
+	return fmt.Sprintf("%v %v", r.Action, r.Pattern)
+}
+
+// RuleSet holds the rules of a policy file indexed by the domains they match. A host is matched by its most specific
+// rule: a rule for the host itself comes first, then the rules for its parent domains from the closest one. When an
+// allow and a block rule are equally specific the block rule wins
+type RuleSet struct {
+	// hosts holds the rules matching a host exactly
+	hosts map[string]Rule
+	// subdomains holds the rules matching every subdomain of a domain, the empty domain holding the rule for "*"
+	subdomains map[string]Rule
+}
+
+// ParseRules reads the rules of a policy file, one per line. Each rule is an action, allow or block, followed by a
+// domain pattern. Blank lines and lines starting with '#' are ignored. Patterns are:
+//
+//   - "*", matching every host
+//   - "*.example.com", matching the subdomains of example.com at any depth, even when it is a public suffix
+//   - "example.com", matching example.com, and its subdomains too when it is a registrable domain, i.e. one label
+//     under a public suffix. So "example.co.uk" and "user.github.io" match their subdomains while "github.io" or
+//     "www.example.com" only match themselves
+//
+// Internationalized domain names may be written in Unicode
+func ParseRules(r io.Reader) (*RuleSet, error) {
+	rules := &RuleSet{hosts: make(map[string]Rule), subdomains: make(map[string]Rule)}
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected an action and a domain pattern, got %q", line, text)
+		}
+		action := Action(strings.ToLower(fields[0]))
+		if action != Allow && action != Block {
+			return nil, fmt.Errorf("line %d: action must be %v or %v, got %q", line, Allow, Block, fields[0])
+		}
+		rule := Rule{Action: action, Pattern: fields[1], Line: line}
+		if err := rules.add(rule); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+// add indexes rule under the domains it matches
+func (rules *RuleSet) add(rule Rule) error {
+	if rule.Pattern == "*" {
+		setRule(rules.subdomains, "", rule)
+		return nil
+	}
+
+	domain, wildcard := strings.CutPrefix(rule.Pattern, "*.")
+	domain, err := normalizeDomain(domain)
+	if err != nil || strings.Contains(domain, "*") {
+		return fmt.Errorf("invalid domain pattern %q", rule.Pattern)
+	}
+
+	switch {
+	case wildcard:
+		setRule(rules.subdomains, domain, rule)
+	case isRegistrable(domain):
+		setRule(rules.hosts, domain, rule)
+		setRule(rules.subdomains, domain, rule)
+	default:
+		setRule(rules.hosts, domain, rule)
+	}
+	return nil
+}
+
+// setRule indexes rule under domain, unless a block rule is already indexed there
+func setRule(index map[string]Rule, domain string, rule Rule) {
+	if existing, ok := index[domain]; !ok || existing.Action == Allow {
+		index[domain] = rule
+	}
+}
+
+// Match returns the most specific rule matching host, and false when no rule does
+func (rules *RuleSet) Match(host string) (Rule, bool) {
+	if normalized, err := normalizeDomain(host); err == nil {
+		host = normalized
+	}
+
+	if rule, ok := rules.hosts[host]; ok {
+		return rule, true
+	}
+	// The parents of an address are not domains, only "*" matches it
+	if net.ParseIP(host) == nil {
+		for parent := host; strings.Contains(parent, "."); {
+			_, parent, _ = strings.Cut(parent, ".")
+			if rule, ok := rules.subdomains[parent]; ok {
+				return rule, true
+			}
+		}
+	}
+	rule, ok := rules.subdomains[""]
+	return rule, ok
+}
+
+// normalizeDomain lowercases domain and converts it to punycode, without the dot of a fully qualified domain name
+func normalizeDomain(domain string) (string, error) {
+	domain = strings.TrimSuffix(strings.ToLower(domain), ".")
+	if domain == "" {
+		return "", fmt.Errorf("empty domain")
+	}
+	for i := 0; i < len(domain); i++ {
+		if domain[i] >= utf8.RuneSelf {
+			return idna.Lookup.ToASCII(domain)
+		}
+	}
+	return domain, nil
+}
+
+// isRegistrable reports whether domain is one label under its public suffix, so its subdomains belong to the same
+// owner. Addresses and public suffixes are not registrable
+func isRegistrable(domain string) bool {
+	if net.ParseIP(domain) != nil {
+		return false
+	}
+	registrable, err := publicsuffix.EffectiveTLDPlusOne(domain)
+	return err == nil && registrable == domain
+}
@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"hash/fnv"
+	"math"
+	"sync"
+)
+
+// bloomFilter answers whether a shortened URL may exist. It never reports an added shortened URL
+// as absent, but may report an absent one as present with the false positive rate it was sized for
+type bloomFilter struct {
+	mu     sync.RWMutex
+	bits   []uint64
+	hashes uint64
+}
+
+// newBloomFilter sizes a filter for capacity entries at the given false positive rate
+func newBloomFilter(capacity int, falsePositiveRate float64) *bloomFilter {
+	if capacity < 1 {
+		capacity = 1
+	}
+
+	m := math.Ceil(-float64(capacity) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2))
+	k := math.Max(1, math.Round(m/float64(capacity)*math.Ln2))
+
+	return &bloomFilter{
+		bits:   make([]uint64, (uint64(m)+63)/64),
+		hashes: uint64(k),
+	}
+}
+
+// locations derives the bit positions of key by double hashing its FNV-1a hash
+func (filter *bloomFilter) locations(key string) []uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	sum := h.Sum64()
+	h1, h2 := sum&0xffffffff, sum>>32|1
+
+	size := uint64(len(filter.bits)) * 64
+	locations := make([]uint64, filter.hashes)
+	for i := range locations {
+		locations[i] = (h1 + uint64(i)*h2) % size
+	}
+	return locations
+}
+
+func (filter *bloomFilter) add(key string) {
+	locations := filter.locations(key)
+
+	filter.mu.Lock()
+	defer filter.mu.Unlock()
+	for _, location := range locations {
+		filter.bits[location/64] |= 1 << (location % 64)
+	}
+}
+
+func (filter *bloomFilter) mayContain(key string) bool {
+	locations := filter.locations(key)
+
+	filter.mu.RLock()
+	defer filter.mu.RUnlock()
+	for _, location := range locations {
+		if filter.bits[location/64]&(1<<(location%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,215 @@
+package repository
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kjj1998/url-shortener-go/constants"
+	"github.com/kjj1998/url-shortener-go/internal/models"
+	"golang.org/x/sync/singleflight"
+)
+
+// CacheOptions configures the caches of a CachedClient, a size of 0 disables the matching cache.
+// The Bloom filter is enabled separately through RebuildBloomFilter
+type CacheOptions struct {
+	// Size and Ttl bound the cache of entries found for shortened URLs
+	Size int
+	Ttl  time.Duration
+	// NegativeSize and NegativeTtl bound the cache of shortened URLs recently found not to exist
+	NegativeSize int
+	NegativeTtl  time.Duration
+}
+
+// CachedClient is a read-through cache in front of another UrlRepository. Entries looked up
+// by RetrieveUrl are kept in a bounded LRU cache, and concurrent misses for the same shortened URL
+// are coalesced into a single lookup on the underlying repository. Shortened URLs that were not
+// found are remembered in a negative cache, and an optional Bloom filter of every stored shortened
+// URL rejects unknown ones without a lookup. Every other operation is passed through to the
+// underlying repository
+type CachedClient struct {
+	UrlRepository
+	cache     *LruCache[models.Url]
+	negatives *LruCache[struct{}]
+	group     singleflight.Group
+	// generation is bumped on every invalidation so lookups in flight do not cache stale URLs
+	generation atomic.Uint64
+
+	bloomMu  sync.Mutex
+	bloom    atomic.Pointer[bloomFilter]
+	rebuilds *bloomFilter
+
+	hits, misses, negativeHits, bloomRejections, bloomFalsePositives atomic.Uint64
+}
+
+// NewCachedClient wraps repository with the caches configured in options
+func NewCachedClient(repository UrlRepository, options CacheOptions) *CachedClient {
+	return &CachedClient{
+		UrlRepository: repository,
+		cache:         NewLruCache[models.Url](options.Size, options.Ttl),
+		negatives:     NewLruCache[struct{}](options.NegativeSize, options.NegativeTtl),
+	}
+}
+
+// AddUrl adds the URL to the underlying repository, invalidating the cached entries of its shortened URL
+func (client *CachedClient) AddUrl(ctx context.Context, url models.Url) error {
+	err := client.UrlRepository.AddUrl(ctx, url)
+	if err == nil {
+		client.addToBloomFilter(url.ShortUrl)
+	}
+	client.Invalidate(url.ShortUrl)
+	return err
+}
+
+// AddUrls adds the URLs to the underlying repository, invalidating the cached entries of their shortened URLs
+func (client *CachedClient) AddUrls(ctx context.Context, urls []models.Url) []error {
+	errs := client.UrlRepository.AddUrls(ctx, urls)
+	for i, url := range urls {
+		if errs[i] == nil {
+			client.addToBloomFilter(url.ShortUrl)
+		}
+		client.Invalidate(url.ShortUrl)
+	}
+	return errs
+}
+
+// PutUrl stores the URL in the underlying repository, invalidating the cached entries of its shortened URL
+func (client *CachedClient) PutUrl(ctx context.Context, url models.Url) error {
+	err := client.UrlRepository.PutUrl(ctx, url)
+	if err == nil {
+		client.addToBloomFilter(url.ShortUrl)
+	}
+	client.Invalidate(url.ShortUrl)
+	return err
+}
+
+// UpdateUrl updates the entry in the underlying repository, invalidating the cached entries of its shortened URL
+func (client *CachedClient) UpdateUrl(ctx context.Context, shortUrl string, update models.UrlUpdate) (models.Url, error) {
+	url, err := client.UrlRepository.UpdateUrl(ctx, shortUrl, update)
+	client.Invalidate(shortUrl)
+	return url, err
+}
+
+// DeleteUrl deletes the entry in the underlying repository, invalidating the cached entries of its shortened URL
+func (client *CachedClient) DeleteUrl(ctx context.Context, shortUrl string) error {
+	err := client.UrlRepository.DeleteUrl(ctx, shortUrl)
+	client.Invalidate(shortUrl)
+	return err
+}
+
+// RetrieveUrl returns the cached entry of a shortened URL, looking it up in the underlying
+// repository on a miss unless the shortened URL is known not to exist
+func (client *CachedClient) RetrieveUrl(ctx context.Context, shortUrl string) (models.Url, error) {
+	if url, ok := client.cache.Get(shortUrl); ok {
+		client.hits.Add(1)
+		return url, nil
+	}
+	if bloom := client.bloom.Load(); bloom != nil && !bloom.mayContain(shortUrl) {
+		client.bloomRejections.Add(1)
+		return models.Url{}, nil
+	}
+	if _, ok := client.negatives.Get(shortUrl); ok {
+		client.negativeHits.Add(1)
+		return models.Url{}, nil
+	}
+	client.misses.Add(1)
+
+	// The lookup is shared by every caller waiting on it, so it is not cancelled along with the caller that started it
+	result, err, _ := client.group.Do(shortUrl, func() (interface{}, error) {
+		generation := client.generation.Load()
+
+		url, err := client.UrlRepository.RetrieveUrl(context.WithoutCancel(ctx), shortUrl)
+		if err != nil || generation != client.generation.Load() {
+			return url, err
+		}
+
+		if url.ShortUrl != "" {
+			client.cache.Set(shortUrl, url)
+		} else {
+			if client.bloom.Load() != nil {
+				client.bloomFalsePositives.Add(1)
+			}
+			client.negatives.Set(shortUrl, struct{}{})
+		}
+		return url, nil
+	})
+
+	return result.(models.Url), err
+}
+
+// Invalidate drops the cached entries of a shortened URL. It must be called whenever the entry
+// of the shortened URL is updated or deleted without going through this client
+func (client *CachedClient) Invalidate(shortUrl string) {
+	client.generation.Add(1)
+	client.cache.Remove(shortUrl)
+	client.negatives.Remove(shortUrl)
+	client.group.Forget(shortUrl)
+}
+
+// Stats returns the hit and miss counters of the caches since the client was created
+func (client *CachedClient) Stats() models.CacheStats {
+	return models.CacheStats{
+		Hits:                client.hits.Load(),
+		Misses:              client.misses.Load(),
+		NegativeHits:        client.negativeHits.Load(),
+		BloomFilterEnabled:  client.bloom.Load() != nil,
+		BloomRejections:     client.bloomRejections.Load(),
+		BloomFalsePositives: client.bloomFalsePositives.Load(),
+	}
+}
+
+// RebuildBloomFilter replaces the Bloom filter with one sized for capacity shortened URLs, holding every
+// shortened URL of the underlying repository. Shortened URLs added while the filter is being rebuilt
+// are added to both the current and the rebuilt filter
+func (client *CachedClient) RebuildBloomFilter(ctx context.Context, capacity int) error {
+	bloom := newBloomFilter(capacity, constants.BloomFilterFalsePositiveRate)
+	client.bloomMu.Lock()
+	client.rebuilds = bloom
+	client.bloomMu.Unlock()
+
+	err := client.UrlRepository.ScanShortUrls(ctx, func(shortUrl string) error {
+		bloom.add(shortUrl)
+		return nil
+	})
+
+	client.bloomMu.Lock()
+	defer client.bloomMu.Unlock()
+	if err == nil {
+		client.bloom.Store(bloom)
+	}
+	client.rebuilds = nil
+
+	return err
+}
+
+// RefreshBloomFilter rebuilds the Bloom filter every interval, so shortened URLs added by other
+// instances of the service are eventually known to this one
+func (client *CachedClient) RefreshBloomFilter(ctx context.Context, capacity int, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := client.RebuildBloomFilter(ctx, capacity); err != nil {
+				log.Printf("Couldn't rebuild bloom filter. Here's why: %v\n", err)
+			}
+		}
+	}
+}
+
+func (client *CachedClient) addToBloomFilter(shortUrl string) {
+	client.bloomMu.Lock()
+	defer client.bloomMu.Unlock()
+
+	if bloom := client.bloom.Load(); bloom != nil {
+		bloom.add(shortUrl)
+	}
+	if client.rebuilds != nil {
+		client.rebuilds.add(shortUrl)
+	}
+}
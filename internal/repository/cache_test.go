@@ -0,0 +1,253 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kjj1998/url-shortener-go/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// countingClient counts the lookups reaching the wrapped repository, holding them until release is closed.
+// Lookups whose context is cancelled by then fail, as they would on a networked store
+type countingClient struct {
+	UrlRepository
+	lookups atomic.Int32
+	release chan struct{}
+}
+
+func (client *countingClient) RetrieveUrl(ctx context.Context, shortUrl string) (models.Url, error) {
+	client.lookups.Add(1)
+	if client.release != nil {
+		<-client.release
+	}
+	if err := ctx.Err(); err != nil {
+		return models.Url{}, err
+	}
+	return client.UrlRepository.RetrieveUrl(ctx, shortUrl)
+}
+
+func enterCacheTest(t *testing.T, options CacheOptions) (context.Context, *countingClient, *CachedClient) {
+	ctx := context.Background()
+	backend := &countingClient{UrlRepository: NewMemoryClient()}
+
+	url := models.Url{Id: 12345, LongUrl: "https://www.youtube.com", ShortUrl: "NEDF34qw"}
+	if err := backend.AddUrl(ctx, url); err != nil {
+		t.Fatalf("Couldn't add url: %v", err)
+	}
+
+	return ctx, backend, NewCachedClient(backend, options)
+}
+
+func TestCachedClient_RetrieveUrl(t *testing.T) {
+	ctx, backend, client := enterCacheTest(t, CacheOptions{Size: 10, Ttl: time.Minute})
+
+	for i := 0; i < 3; i++ {
+		url, err := client.RetrieveUrl(ctx, "NEDF34qw")
+		assert.NoError(t, err)
+		assert.Equal(t, "https://www.youtube.com", url.LongUrl)
+	}
+
+	assert.Equal(t, int32(1), backend.lookups.Load())
+}
+
+func TestCachedClient_RetrieveNoUrl(t *testing.T) {
+	ctx, backend, client := enterCacheTest(t, CacheOptions{Size: 10, Ttl: time.Minute})
+
+	for i := 0; i < 2; i++ {
+		url, err := client.RetrieveUrl(ctx, "NEWDSa31")
+		assert.NoError(t, err)
+		assert.Empty(t, url)
+	}
+
+	assert.Equal(t, int32(2), backend.lookups.Load())
+}
+
+func TestCachedClient_Expiry(t *testing.T) {
+	ctx, backend, client := enterCacheTest(t, CacheOptions{Size: 10, Ttl: time.Minute})
+	now := time.Now()
+	client.cache.now = func() time.Time { return now }
+
+	_, _ = client.RetrieveUrl(ctx, "NEDF34qw")
+	now = now.Add(2 * time.Minute)
+	_, _ = client.RetrieveUrl(ctx, "NEDF34qw")
+
+	assert.Equal(t, int32(2), backend.lookups.Load())
+}
+
+func TestCachedClient_Eviction(t *testing.T) {
+	ctx, backend, client := enterCacheTest(t, CacheOptions{Size: 1, Ttl: time.Minute})
+	assert.NoError(t, client.AddUrl(ctx, models.Url{Id: 1, LongUrl: "https://www.google.com", ShortUrl: "KWBG425d"}))
+
+	_, _ = client.RetrieveUrl(ctx, "NEDF34qw")
+	_, _ = client.RetrieveUrl(ctx, "KWBG425d")
+	_, _ = client.RetrieveUrl(ctx, "NEDF34qw")
+
+	assert.Equal(t, int32(3), backend.lookups.Load())
+}
+
+func TestCachedClient_Invalidate(t *testing.T) {
+	ctx, backend, client := enterCacheTest(t, CacheOptions{Size: 10, Ttl: time.Minute})
+
+	_, _ = client.RetrieveUrl(ctx, "NEDF34qw")
+
+	// Replace the entry behind the back of the cache, as another instance of the service would
+	backend.UrlRepository = NewMemoryClient()
+	assert.NoError(t, backend.AddUrl(ctx, models.Url{Id: 12345, LongUrl: "https://www.google.com", ShortUrl: "NEDF34qw"}))
+	client.Invalidate("NEDF34qw")
+
+	url, err := client.RetrieveUrl(ctx, "NEDF34qw")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://www.google.com", url.LongUrl)
+	assert.Equal(t, int32(2), backend.lookups.Load())
+}
+
+func TestCachedClient_UpdateUrl(t *testing.T) {
+	ctx, backend, client := enterCacheTest(t, CacheOptions{Size: 10, Ttl: time.Minute})
+	_, _ = client.RetrieveUrl(ctx, "NEDF34qw")
+
+	longUrl := "https://www.google.com"
+	_, err := client.UpdateUrl(ctx, "NEDF34qw", models.UrlUpdate{LongUrl: &longUrl})
+	assert.NoError(t, err)
+
+	url, err := client.RetrieveUrl(ctx, "NEDF34qw")
+	assert.NoError(t, err)
+	assert.Equal(t, longUrl, url.LongUrl)
+	assert.Equal(t, int32(2), backend.lookups.Load())
+
+	assert.NoError(t, client.DeleteUrl(ctx, "NEDF34qw"))
+	url, err = client.RetrieveUrl(ctx, "NEDF34qw")
+	assert.NoError(t, err)
+	assert.True(t, url.Deleted())
+}
+
+func TestCachedClient_Coalescing(t *testing.T) {
+	ctx, backend, client := enterCacheTest(t, CacheOptions{Size: 10, Ttl: time.Minute})
+	backend.release = make(chan struct{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			url, err := client.RetrieveUrl(ctx, "NEDF34qw")
+			assert.NoError(t, err)
+			assert.Equal(t, "https://www.youtube.com", url.LongUrl)
+		}()
+	}
+
+	// Give the lookups time to pile up behind the one reaching the backend
+	time.Sleep(50 * time.Millisecond)
+	close(backend.release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), backend.lookups.Load())
+}
+
+func TestCachedClient_CoalescingCancelled(t *testing.T) {
+	ctx, backend, client := enterCacheTest(t, CacheOptions{Size: 10, Ttl: time.Minute})
+	backend.release = make(chan struct{})
+	cancelled, cancel := context.WithCancel(ctx)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, _ = client.RetrieveUrl(cancelled, "NEDF34qw")
+	}()
+	time.Sleep(50 * time.Millisecond)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		url, err := client.RetrieveUrl(ctx, "NEDF34qw")
+		assert.NoError(t, err, "the shared lookup is not cancelled along with the caller that started it")
+		assert.Equal(t, "https://www.youtube.com", url.LongUrl)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	close(backend.release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), backend.lookups.Load())
+}
+
+func TestCachedClient_NegativeCache(t *testing.T) {
+	ctx, backend, client := enterCacheTest(t, CacheOptions{NegativeSize: 10, NegativeTtl: time.Minute})
+
+	for i := 0; i < 3; i++ {
+		url, err := client.RetrieveUrl(ctx, "NEWDSa31")
+		assert.NoError(t, err)
+		assert.Empty(t, url)
+	}
+	assert.Equal(t, int32(1), backend.lookups.Load())
+
+	assert.NoError(t, client.AddUrl(ctx, models.Url{Id: 1, LongUrl: "https://www.google.com", ShortUrl: "NEWDSa31"}))
+	url, err := client.RetrieveUrl(ctx, "NEWDSa31")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://www.google.com", url.LongUrl)
+
+	stats := client.Stats()
+	assert.Equal(t, uint64(2), stats.NegativeHits)
+	assert.Equal(t, uint64(2), stats.Misses)
+}
+
+func TestCachedClient_AddUrls(t *testing.T) {
+	ctx, _, client := enterCacheTest(t, CacheOptions{NegativeSize: 10, NegativeTtl: time.Minute})
+
+	url, err := client.RetrieveUrl(ctx, "NEWDSa31")
+	assert.NoError(t, err)
+	assert.Empty(t, url)
+
+	errs := client.AddUrls(ctx, []models.Url{{Id: 1, LongUrl: "https://www.google.com", ShortUrl: "NEWDSa31"}})
+	assert.Equal(t, []error{nil}, errs)
+	url, err = client.RetrieveUrl(ctx, "NEWDSa31")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://www.google.com", url.LongUrl)
+}
+
+func TestCachedClient_BloomFilter(t *testing.T) {
+	ctx, backend, client := enterCacheTest(t, CacheOptions{})
+	assert.NoError(t, client.RebuildBloomFilter(ctx, 100))
+
+	url, err := client.RetrieveUrl(ctx, "NEDF34qw")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://www.youtube.com", url.LongUrl)
+
+	url, err = client.RetrieveUrl(ctx, "NEWDSa31")
+	assert.NoError(t, err)
+	assert.Empty(t, url)
+
+	assert.NoError(t, client.AddUrl(ctx, models.Url{Id: 1, LongUrl: "https://www.google.com", ShortUrl: "KWBG425d"}))
+	url, err = client.RetrieveUrl(ctx, "KWBG425d")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://www.google.com", url.LongUrl)
+
+	stats := client.Stats()
+	assert.True(t, stats.BloomFilterEnabled)
+	assert.Equal(t, uint64(1), stats.BloomRejections)
+	assert.Equal(t, int32(2), backend.lookups.Load())
+}
+
+func TestBloomFilter(t *testing.T) {
+	filter := newBloomFilter(1000, 0.01)
+
+	for i := 0; i < 1000; i++ {
+		filter.add(fmt.Sprintf("code%d", i))
+	}
+	for i := 0; i < 1000; i++ {
+		assert.True(t, filter.mayContain(fmt.Sprintf("code%d", i)))
+	}
+
+	falsePositives := 0
+	for i := 1000; i < 11000; i++ {
+		if filter.mayContain(fmt.Sprintf("code%d", i)) {
+			falsePositives++
+		}
+	}
+	assert.Less(t, falsePositives, 300)
+}
@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+// ErrCursorInvalid is returned when a cursor passed to ListUrls was not returned by it
+var ErrCursorInvalid = errors.New("invalid cursor")
+
+// listCursor is the key of the last shortened URL of a page, which every backend resumes listing after.
+// It is the LastEvaluatedKey of the DynamoDB table, as the table is partitioned by ShortUrl
+type listCursor struct {
+	ShortUrl string `json:"ShortUrl"`
+}
+
+// encodeCursor wraps the key of the last shortened URL of a page into an opaque cursor
+func encodeCursor(shortUrl string) string {
+	encoded, _ := json.Marshal(listCursor{ShortUrl: shortUrl})
+	return base64.RawURLEncoding.EncodeToString(encoded)
+}
+
+// decodeCursor returns the shortened URL to resume listing after, or an empty string for the first page
+func decodeCursor(cursor string) (string, error) {
+	if cursor == "" {
+		return "", nil
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", ErrCursorInvalid
+	}
+	var key listCursor
+	if err := json.Unmarshal(decoded, &key); err != nil || key.ShortUrl == "" {
+		return "", ErrCursorInvalid
+	}
+	return key.ShortUrl, nil
+}
@@ -0,0 +1,22 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCursor(t *testing.T) {
+	shortUrl, err := decodeCursor(encodeCursor("NEDF34qw"))
+	assert.NoError(t, err)
+	assert.Equal(t, "NEDF34qw", shortUrl)
+
+	shortUrl, err = decodeCursor("")
+	assert.NoError(t, err)
+	assert.Empty(t, shortUrl)
+
+	for _, cursor := range []string{"not base64!", "bm90IGpzb24", "e30"} {
+		_, err = decodeCursor(cursor)
+		assert.ErrorIs(t, err, ErrCursorInvalid, cursor)
+	}
+}
@@ -2,84 +2,324 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
-	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/kjj1998/url-shortener-go/constants"
+	appconfig "github.com/kjj1998/url-shortener-go/internal/config"
 	"github.com/kjj1998/url-shortener-go/internal/models"
 )
 
+var (
+	// ErrUnprocessedItem is returned for the items AddUrls still could not write after every retry
+	ErrUnprocessedItem = errors.New("item was left unprocessed by dynamodb")
+	// ErrItemRejected is returned for the items AddUrls could not write for a reason retrying does not fix
+	ErrItemRejected = errors.New("item was rejected by dynamodb")
+)
+
+// retryableCancellations are the reasons DynamoDB cancels a put of a transaction for that a retry can get past
+var retryableCancellations = []string{"ThrottlingError", "ProvisionedThroughputExceeded", "TransactionConflict"}
+
 type DynamoDbApi interface {
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
 	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error)
+	UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
+	DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
 	Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error)
+	Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
 }
 
+// TableClient stores shortened URLs in a DynamoDB table partitioned by ShortUrl
 type TableClient struct {
 	DynamoDbClient DynamoDbApi
 	TableName      string
+	// ConsistentReads makes RetrieveUrl use strongly consistent reads
+	ConsistentReads bool
+	// LegacyTableName is the table of the previous layout, partitioned by Id with a ShortUrl-index.
+	// When set, shortened URLs missing from TableName are looked up in it while its entries are migrated, and new
+	// shortened URLs are checked against it at the cost of a query each. It is not set by default
+	LegacyTableName string
+	// DestinationIndexName is the global secondary index of the table partitioned by Destination, projecting every
+	// attribute. RetrieveReusableUrl finds nothing when it is not set
+	DestinationIndexName string
+	// ApiKeyTableName is the table of the API keys, partitioned by Id
+	ApiKeyTableName string
 }
 
-var Client TableClient
-
-func init() {
-	env, exists := os.LookupEnv("ENVIRONMENT")
+// NewTableClient creates a TableClient backed by DynamoDB, loading the AWS SDK config for the given environment
+func NewTableClient(env string) (TableClient, error) {
 	var cfg aws.Config
 	var err error
 
-	if exists && env == "PRODUCTION" {
+	if env == "PRODUCTION" {
 		cfg, err = config.LoadDefaultConfig(context.TODO(),
 			config.WithRegion("ap-southeast-1"),
 			config.WithLogger(aws.NewConfig().Logger), // Logs AWS SDK activity
 			config.WithClientLogMode(aws.LogRetries|aws.LogRequest|aws.LogResponse),
 		)
-	} else if exists && env == "DEVELOPMENT" {
+	} else if env == "DEVELOPMENT" {
 		cfg, err = config.LoadDefaultConfig(context.TODO(),
 			config.WithSharedConfigProfile(constants.AwsProfile),
 			config.WithRegion(constants.AwsRegion),
 		)
-	} else if exists {
-		log.Fatalf("%v environment is not recognised", env)
 	} else {
-		log.Fatalf("No input for environment variable ENVIRONMENT detected")
+		return TableClient{}, fmt.Errorf("%v environment is not recognised", env)
 	}
 
 	if err != nil {
 		if env == "DEVELOPMENT" {
-			log.Fatalf("Development: unable to load SDK config, %v", err)
-		} else if env == "PRODUCTION" {
-			log.Fatalf("Production: unable to load SDK config, %v", err)
+			return TableClient{}, fmt.Errorf("development: unable to load SDK config, %w", err)
 		}
+		return TableClient{}, fmt.Errorf("production: unable to load SDK config, %w", err)
 	}
 
-	Client = TableClient{
-		DynamoDbClient: dynamodb.NewFromConfig(cfg),
-		TableName:      constants.TableName,
-	}
+	return TableClient{
+		DynamoDbClient:       dynamodb.NewFromConfig(cfg),
+		TableName:            appconfig.String("DYNAMODB_TABLE", constants.TableName),
+		ConsistentReads:      appconfig.Bool("DYNAMODB_CONSISTENT_READS", false),
+		LegacyTableName:      appconfig.String("DYNAMODB_LEGACY_TABLE", ""),
+		DestinationIndexName: appconfig.String("DYNAMODB_DESTINATION_INDEX", constants.DestinationIndex),
+		ApiKeyTableName:      appconfig.String("DYNAMODB_API_KEY_TABLE", constants.ApiKeyTableName),
+	}, nil
+}
+
+func newTableClient(env string) (UrlRepository, error) {
+	return NewTableClient(env)
 }
 
 // AddUrl adds a URL and its shortened form as an entry into the DynamoDB table
-// Returns an error when the URL could not be inserted into the table
+// The write is conditional on the shortened URL not being in the table. While the legacy table is migrated it is
+// queried first, which is best-effort: the query and the write are not atomic, so an entry of the legacy table may be
+// overwritten by a shortened URL added at the same time it is copied over
+// Returns ErrShortUrlTaken when the shortened URL is already in use, or an error when the URL could not be inserted into the table
 func (client TableClient) AddUrl(ctx context.Context, url models.Url) error {
+	if client.LegacyTableName != "" {
+		legacyUrl, err := client.retrieveLegacyUrl(ctx, url.ShortUrl)
+		if err != nil {
+			return err
+		}
+		if legacyUrl.ShortUrl != "" {
+			return ErrShortUrlTaken
+		}
+	}
+
+	err := client.putNewItem(ctx, url)
+	var conditionFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) {
+		return ErrShortUrlTaken
+	}
+	if err != nil {
+		log.Printf("Couldn't add item to table. Here's why: %v\n", err)
+	}
+	return err
+}
+
+// putNewItem puts url into the DynamoDB table on the condition that its shortened URL is not in the table yet
+func (client TableClient) putNewItem(ctx context.Context, url models.Url) error {
 	item, err := attributevalue.MarshalMap(url)
 	if err != nil {
 		panic(err)
 	}
 
+	cond := expression.AttributeNotExists(expression.Name("ShortUrl"))
+	expr, err := expression.NewBuilder().WithCondition(cond).Build()
+	if err != nil {
+		log.Printf("Couldn't build expression for put item. Here's why: %v\n", err)
+		return err
+	}
+
 	_, err = client.DynamoDbClient.PutItem(ctx, &dynamodb.PutItemInput{
-		TableName: aws.String(client.TableName), Item: item,
+		TableName:                aws.String(client.TableName),
+		Item:                     item,
+		ConditionExpression:      expr.Condition(),
+		ExpressionAttributeNames: expr.Names(),
 	})
+	return err
+}
+
+// AddUrls puts the URLs into the DynamoDB table with TransactWriteItems, at most DynamoDbTransactWriteSize in a
+// transaction. BatchWriteItem is not used as it takes no condition expressions, so it would overwrite the aliases and
+// tombstones its generated shortened URLs collide with. Like AddUrl, each put is conditional on the shortened URL not being in the table, the legacy table
+// being checked on a best-effort basis while it is migrated, and ErrShortUrlTaken is returned for the URLs whose
+// shortened URL is taken
+func (client TableClient) AddUrls(ctx context.Context, urls []models.Url) []error {
+	errs := make([]error, len(urls))
+	for start := 0; start < len(urls); start += constants.DynamoDbTransactWriteSize {
+		end := min(start+constants.DynamoDbTransactWriteSize, len(urls))
+		client.transactWriteUrls(ctx, urls[start:end], errs[start:end])
+	}
+	return errs
+}
+
+// transactWriteUrls puts at most DynamoDbTransactWriteSize URLs in a transaction, setting the error of each URL in errs.
+// DynamoDB cancels the whole transaction when a put fails, so the URLs whose condition failed are left out and the
+// others are written again in a new transaction, with exponential backoff when it was cancelled by a conflicting
+// write or throttling. The URLs cancelled for any other reason, e.g. a ValidationError, fail with ErrItemRejected
+func (client TableClient) transactWriteUrls(ctx context.Context, urls []models.Url, errs []error) {
+	cond := expression.AttributeNotExists(expression.Name("ShortUrl"))
+	expr, err := expression.NewBuilder().WithCondition(cond).Build()
 	if err != nil {
-		log.Printf("Couldn't add item to table. Here's why: %v\n", err)
+		log.Printf("Couldn't build expression for transact write items. Here's why: %v\n", err)
+		for i := range errs {
+			errs[i] = err
+		}
+		return
+	}
+
+	items := make([]map[string]types.AttributeValue, len(urls))
+	pending := make([]int, 0, len(urls))
+	for i, url := range urls {
+		if client.LegacyTableName != "" {
+			legacyUrl, err := client.retrieveLegacyUrl(ctx, url.ShortUrl)
+			if err != nil {
+				errs[i] = err
+				continue
+			}
+			if legacyUrl.ShortUrl != "" {
+				errs[i] = ErrShortUrlTaken
+				continue
+			}
+		}
+		if items[i], err = attributevalue.MarshalMap(url); err != nil {
+			errs[i] = err
+			continue
+		}
+		pending = append(pending, i)
+	}
+
+	// failPending sets err as the error of the URLs that were not written
+	failPending := func(err error) {
+		for _, i := range pending {
+			errs[i] = err
+		}
+	}
+
+	backoff := constants.BatchWriteBackoff
+	for attempt := 1; len(pending) > 0; attempt++ {
+		transactItems := make([]types.TransactWriteItem, len(pending))
+		for j, i := range pending {
+			transactItems[j] = types.TransactWriteItem{Put: &types.Put{
+				TableName:                aws.String(client.TableName),
+				Item:                     items[i],
+				ConditionExpression:      expr.Condition(),
+				ExpressionAttributeNames: expr.Names(),
+			}}
+		}
+
+		_, err := client.DynamoDbClient.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: transactItems})
+		if err == nil {
+			return
+		}
+		var cancelled *types.TransactionCanceledException
+		if !errors.As(err, &cancelled) || len(cancelled.CancellationReasons) != len(pending) {
+			log.Printf("Couldn't transact write items to table. Here's why: %v\n", err)
+			failPending(err)
+			return
+		}
+
+		// The reasons are in the order of the puts, those of the puts cancelled along with the failed ones are None
+		retry := make([]int, 0, len(pending))
+		throttled := false
+		for j, reason := range cancelled.CancellationReasons {
+			code := aws.ToString(reason.Code)
+			switch {
+			case code == "ConditionalCheckFailed":
+				errs[pending[j]] = ErrShortUrlTaken
+			case code == "None":
+				retry = append(retry, pending[j])
+			case slices.Contains(retryableCancellations, code):
+				throttled = true
+				retry = append(retry, pending[j])
+			default:
+				log.Printf("Couldn't transact write item to table. Here's why: %v: %v\n", code, aws.ToString(reason.Message))
+				errs[pending[j]] = fmt.Errorf("%w, %v: %v", ErrItemRejected, code, aws.ToString(reason.Message))
+			}
+		}
+		pending = retry
+		if len(pending) == 0 {
+			return
+		}
+		if attempt == constants.BatchWriteAttempts {
+			log.Printf("Couldn't transact write %v items to table after %v attempts\n", len(pending), attempt)
+			failPending(ErrUnprocessedItem)
+			return
+		}
+		if !throttled {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			failPending(ctx.Err())
+			return
+		case <-time.After(backoff):
+			backoff *= 2
+		}
+	}
+}
+
+// PutUrl puts url into the DynamoDB table, replacing the entry of its shortened URL if there is one
+func (client TableClient) PutUrl(ctx context.Context, url models.Url) error {
+	item, err := attributevalue.MarshalMap(url)
+	if err != nil {
+		return err
+	}
+
+	_, err = client.DynamoDbClient.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(client.TableName),
+		Item:      item,
+	})
+	if err != nil {
+		log.Printf("Couldn't put item into table. Here's why: %v\n", err)
 	}
 	return err
 }
 
-func (client TableClient) RetrieveUrl(ctx context.Context, shortUrl string) (string, error) {
+// RetrieveUrl gets the entry of a shortened URL from the DynamoDB table by its partition key,
+// falling back to the legacy table when the entry has not been migrated yet
+// Returns a zero Url when no entry exists for the shortened URL. Expired entries are returned until
+// DynamoDB's TTL deletes them, which can take a while after their ExpiresAt
+func (client TableClient) RetrieveUrl(ctx context.Context, shortUrl string) (models.Url, error) {
+	response, err := client.DynamoDbClient.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(client.TableName),
+		Key:            map[string]types.AttributeValue{"ShortUrl": &types.AttributeValueMemberS{Value: shortUrl}},
+		ConsistentRead: aws.Bool(client.ConsistentReads),
+	})
+	if err != nil {
+		log.Printf("Couldn't get item with shortened url %v. Here's why: %v\n", shortUrl, err)
+		return models.Url{}, err
+	}
+
+	if response.Item == nil {
+		if client.LegacyTableName != "" {
+			return client.retrieveLegacyUrl(ctx, shortUrl)
+		}
+		return models.Url{}, nil
+	}
+
+	var url models.Url
+	if err = attributevalue.UnmarshalMap(response.Item, &url); err != nil {
+		log.Printf("Couldn't unmarshal get item response. Here's why: %v\n", err)
+		return models.Url{}, err
+	}
+	return url, nil
+}
+
+// retrieveLegacyUrl queries the ShortUrl-index of the legacy table for the entry of a shortened URL
+func (client TableClient) retrieveLegacyUrl(ctx context.Context, shortUrl string) (models.Url, error) {
 	var err error
 	var response *dynamodb.QueryOutput
 	var urls []models.Url
@@ -91,7 +331,7 @@ func (client TableClient) RetrieveUrl(ctx context.Context, shortUrl string) (str
 		log.Printf("Couldn't build expression for query. Here's why: %v\n", err)
 	} else {
 		queryPaginator := dynamodb.NewQueryPaginator(client.DynamoDbClient, &dynamodb.QueryInput{
-			TableName:                 aws.String(client.TableName),
+			TableName:                 aws.String(client.LegacyTableName),
 			ExpressionAttributeNames:  expr.Names(),
 			ExpressionAttributeValues: expr.Values(),
 			KeyConditionExpression:    expr.KeyCondition(),
@@ -116,8 +356,684 @@ func (client TableClient) RetrieveUrl(ctx context.Context, shortUrl string) (str
 	}
 
 	if len(urls) > 0 {
-		return urls[0].LongUrl, err
+		return urls[0], err
 	} else {
-		return "", err
+		return models.Url{}, err
+	}
+}
+
+// RetrieveReusableUrl queries the destination index for the entries of owner for destination, filtering out the
+// ones that are not reusable, and returns the one with the lowest shortened URL. Entries still in the legacy table
+// are not indexed. The index is eventually consistent, so an entry added a moment ago may not be found
+func (client TableClient) RetrieveReusableUrl(ctx context.Context, owner string, destination string) (models.Url, error) {
+	if client.DestinationIndexName == "" {
+		return models.Url{}, nil
+	}
+
+	expr, err := expression.NewBuilder().
+		WithKeyCondition(expression.Key("Destination").Equal(expression.Value(destination))).
+		WithFilter(reusableUrlCondition(owner)).
+		Build()
+	if err != nil {
+		log.Printf("Couldn't build expression for query. Here's why: %v\n", err)
+		return models.Url{}, err
+	}
+
+	var reusable models.Url
+	queryPaginator := dynamodb.NewQueryPaginator(client.DynamoDbClient, &dynamodb.QueryInput{
+		TableName:                 aws.String(client.TableName),
+		IndexName:                 aws.String(client.DestinationIndexName),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		KeyConditionExpression:    expr.KeyCondition(),
+		FilterExpression:          expr.Filter(),
+	})
+	for queryPaginator.HasMorePages() {
+		response, err := queryPaginator.NextPage(ctx)
+		if err != nil {
+			log.Printf("Couldn't query for urls with destination %v. Here's why: %v\n", destination, err)
+			return models.Url{}, err
+		}
+
+		var urlPage []models.Url
+		if err = attributevalue.UnmarshalListOfMaps(response.Items, &urlPage); err != nil {
+			log.Printf("Couldn't unmarshal query response. Here's why: %v\n", err)
+			return models.Url{}, err
+		}
+		for _, url := range urlPage {
+			if reusable.ShortUrl == "" || url.ShortUrl < reusable.ShortUrl {
+				reusable = url
+			}
+		}
+	}
+	return reusable, nil
+}
+
+// reusableUrlCondition holds for the entries of owner that models.Url.Reusable holds for
+func reusableUrlCondition(owner string) expression.ConditionBuilder {
+	ownerCond := expression.Name("Owner").Equal(expression.Value(owner))
+	if owner == "" {
+		ownerCond = expression.Name("Owner").AttributeNotExists()
+	}
+	return ownerCond.And(
+		expression.Name("DeletedAt").AttributeNotExists(),
+		expression.Name("ExpiresAt").AttributeNotExists(),
+		expression.Name("MaxClicks").AttributeNotExists(),
+		expression.Name("PasswordHash").AttributeNotExists(),
+	)
+}
+
+// UpdateUrl applies update to the entry of a shortened URL with an UpdateItem conditional on the entry existing
+// and not being deleted, returning the updated entry. Entries still in the legacy table are moved into the table first
+// Returns ErrUrlNotFound when there is no entry to update
+func (client TableClient) UpdateUrl(ctx context.Context, shortUrl string, update models.UrlUpdate) (models.Url, error) {
+	changes, changed := updateUrlExpression(update)
+	if !changed {
+		url, err := client.RetrieveUrl(ctx, shortUrl)
+		if err == nil && (url.ShortUrl == "" || url.Deleted()) {
+			return models.Url{}, ErrUrlNotFound
+		}
+		return url, err
+	}
+
+	return client.updateLiveItem(ctx, shortUrl, changes)
+}
+
+// updateUrlExpression sets the attributes changed by update, removing the optional ones when they are cleared
+// Returns false when update has no changes
+func updateUrlExpression(update models.UrlUpdate) (expression.UpdateBuilder, bool) {
+	var changes expression.UpdateBuilder
+	changed := false
+
+	if update.LongUrl != nil {
+		changes = changes.Set(expression.Name("LongUrl"), expression.Value(*update.LongUrl))
+		changed = true
+	}
+	if update.ExpiresAt != nil {
+		changes = changes.Set(expression.Name("ExpiresAt"), expression.Value(update.ExpiresAt.Unix()))
+		changed = true
+	} else if update.RemoveExpiry {
+		changes = changes.Remove(expression.Name("ExpiresAt"))
+		changed = true
+	}
+	if update.MaxClicks != nil && *update.MaxClicks > 0 {
+		changes = changes.Set(expression.Name("MaxClicks"), expression.Value(*update.MaxClicks))
+		changed = true
+	} else if update.MaxClicks != nil {
+		changes = changes.Remove(expression.Name("MaxClicks"))
+		changed = true
+	}
+	if update.Domain != nil {
+		changes = changes.Set(expression.Name("Domain"), expression.Value(*update.Domain))
+		changed = true
+	}
+	attributes := []struct {
+		name  string
+		value *string
+	}{
+		{"Destination", update.Destination},
+		{"PasswordHash", update.PasswordHash},
+		{"Title", update.Title},
+		{"Description", update.Description},
+		{"Notes", update.Notes},
+	}
+	for _, attribute := range attributes {
+		if attribute.value != nil && *attribute.value != "" {
+			changes = changes.Set(expression.Name(attribute.name), expression.Value(*attribute.value))
+			changed = true
+		} else if attribute.value != nil {
+			changes = changes.Remove(expression.Name(attribute.name))
+			changed = true
+		}
+	}
+	// DynamoDB has no empty string sets, so clearing Tags removes them
+	if update.Tags != nil && len(*update.Tags) > 0 {
+		tags := models.NormalizeTags(*update.Tags)
+		changes = changes.Set(expression.Name("Tags"), expression.Value(&types.AttributeValueMemberSS{Value: tags}))
+		changed = true
+	} else if update.Tags != nil {
+		changes = changes.Remove(expression.Name("Tags"))
+		changed = true
+	}
+
+	return changes, changed
+}
+
+// DeleteUrl turns the entry of a shortened URL into a tombstone by setting its DeletedAt. Its ExpiresAt is removed
+// so DynamoDB's TTL does not reap the tombstone, which would let the shortened URL be reissued
+// Returns ErrUrlNotFound when there is no entry, or it has already been deleted
+func (client TableClient) DeleteUrl(ctx context.Context, shortUrl string) error {
+	_, err := client.updateLiveItem(ctx, shortUrl, deleteUrlExpression(time.Now()))
+	return err
+}
+
+func deleteUrlExpression(now time.Time) expression.UpdateBuilder {
+	return expression.Set(expression.Name("DeletedAt"), expression.Value(now.Unix())).
+		Remove(expression.Name("ExpiresAt"))
+}
+
+// liveItemCondition matches an entry that exists and has not been deleted
+func liveItemCondition() expression.ConditionBuilder {
+	return expression.And(
+		expression.AttributeExists(expression.Name("ShortUrl")),
+		expression.AttributeNotExists(expression.Name("DeletedAt")),
+	)
+}
+
+// updateLiveItem applies changes to the entry of a shortened URL with an UpdateItem conditional on liveItemCondition,
+// moving the entry from the legacy table when the condition fails because it has not been migrated yet
+// Returns the updated entry, or ErrUrlNotFound when there is no entry that is not deleted
+func (client TableClient) updateLiveItem(ctx context.Context, shortUrl string, changes expression.UpdateBuilder) (models.Url, error) {
+	expr, err := expression.NewBuilder().WithCondition(liveItemCondition()).WithUpdate(changes).Build()
+	if err != nil {
+		log.Printf("Couldn't build expression for update item. Here's why: %v\n", err)
+		return models.Url{}, err
+	}
+
+	response, err := client.DynamoDbClient.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(client.TableName),
+		Key:                       map[string]types.AttributeValue{"ShortUrl": &types.AttributeValueMemberS{Value: shortUrl}},
+		ConditionExpression:       expr.Condition(),
+		UpdateExpression:          expr.Update(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		ReturnValues:              types.ReturnValueAllNew,
+	})
+	var conditionFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) {
+		migrated, err := client.migrateLegacyUrl(ctx, shortUrl)
+		if err != nil {
+			return models.Url{}, err
+		}
+		if !migrated {
+			return models.Url{}, ErrUrlNotFound
+		}
+		return client.updateLiveItem(ctx, shortUrl, changes)
+	}
+	if err != nil {
+		log.Printf("Couldn't update item with shortened url %v. Here's why: %v\n", shortUrl, err)
+		return models.Url{}, err
+	}
+
+	var url models.Url
+	if err = attributevalue.UnmarshalMap(response.Attributes, &url); err != nil {
+		log.Printf("Couldn't unmarshal update item response. Here's why: %v\n", err)
+		return models.Url{}, err
+	}
+	return url, nil
+}
+
+// migrateLegacyUrl moves the entry of a shortened URL from the legacy table into the table
+// Returns false when the legacy table holds no entry for the shortened URL, or the table already does
+func (client TableClient) migrateLegacyUrl(ctx context.Context, shortUrl string) (bool, error) {
+	if client.LegacyTableName == "" {
+		return false, nil
+	}
+
+	url, err := client.retrieveLegacyUrl(ctx, shortUrl)
+	if err != nil || url.ShortUrl == "" {
+		return false, err
+	}
+
+	err = client.putNewItem(ctx, url)
+	var conditionFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) {
+		return false, nil
+	}
+	if err != nil {
+		log.Printf("Couldn't copy item with shortened url %v. Here's why: %v\n", shortUrl, err)
+		return false, err
+	}
+
+	_, err = client.DynamoDbClient.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(client.LegacyTableName),
+		Key:       map[string]types.AttributeValue{"Id": &types.AttributeValueMemberN{Value: strconv.FormatUint(url.Id, 10)}},
+	})
+	if err != nil {
+		log.Printf("Couldn't delete legacy item with shortened url %v. Here's why: %v\n", shortUrl, err)
+		return false, err
+	}
+	return true, nil
+}
+
+// ListUrls scans the DynamoDB table for the entries that match filter, starting after the shortened URL in cursor.
+// A scan reads at most limit entries per page before filtering them, so pages are scanned until limit entries
+// match or the table ends. Entries still in the legacy table are not listed until they are migrated
+func (client TableClient) ListUrls(ctx context.Context, filter models.UrlFilter, cursor string, limit int) (models.UrlPage, error) {
+	after, err := decodeCursor(cursor)
+	if err != nil {
+		return models.UrlPage{}, err
 	}
+
+	expr, err := expression.NewBuilder().WithFilter(listUrlsFilter(filter)).Build()
+	if err != nil {
+		log.Printf("Couldn't build expression for scan. Here's why: %v\n", err)
+		return models.UrlPage{}, err
+	}
+
+	input := &dynamodb.ScanInput{
+		TableName:                 aws.String(client.TableName),
+		FilterExpression:          expr.Filter(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		Limit:                     aws.Int32(int32(limit)),
+	}
+	if after != "" {
+		input.ExclusiveStartKey = map[string]types.AttributeValue{"ShortUrl": &types.AttributeValueMemberS{Value: after}}
+	}
+
+	page := models.UrlPage{Links: make([]models.Url, 0, limit)}
+	for {
+		response, err := client.DynamoDbClient.Scan(ctx, input)
+		if err != nil {
+			log.Printf("Couldn't scan table %v. Here's why: %v\n", client.TableName, err)
+			return models.UrlPage{}, err
+		}
+
+		var urlPage []models.Url
+		if err = attributevalue.UnmarshalListOfMaps(response.Items, &urlPage); err != nil {
+			log.Printf("Couldn't unmarshal scan response. Here's why: %v\n", err)
+			return models.UrlPage{}, err
+		}
+		for i, url := range urlPage {
+			page.Links = append(page.Links, url)
+			if len(page.Links) == limit {
+				// The page ends on this entry, unless it is the last one of the table
+				if i < len(urlPage)-1 || response.LastEvaluatedKey != nil {
+					page.NextCursor = encodeCursor(url.ShortUrl)
+				}
+				return page, nil
+			}
+		}
+
+		if response.LastEvaluatedKey == nil {
+			return page, nil
+		}
+		input.ExclusiveStartKey = response.LastEvaluatedKey
+	}
+}
+
+// listUrlsFilter builds the filter expression of ListUrls, which always leaves out tombstones
+func listUrlsFilter(filter models.UrlFilter) expression.ConditionBuilder {
+	cond := expression.AttributeNotExists(expression.Name("DeletedAt"))
+	if filter.CreatedAfter != nil {
+		cond = cond.And(expression.Name("CreatedAt").GreaterThanEqual(expression.Value(filter.CreatedAfter.Unix())))
+	}
+	if filter.CreatedBefore != nil {
+		cond = cond.And(expression.Name("CreatedAt").LessThan(expression.Value(filter.CreatedBefore.Unix())))
+	}
+	if filter.Domain != "" {
+		cond = cond.And(expression.Name("Domain").Equal(expression.Value(strings.ToLower(filter.Domain))))
+	}
+	if filter.Owner != "" {
+		cond = cond.And(expression.Name("Owner").Equal(expression.Value(filter.Owner)))
+	}
+	if filter.Search != "" {
+		cond = cond.And(expression.Or(
+			expression.Name("LongUrl").Contains(filter.Search),
+			expression.Name("ShortUrl").Contains(filter.Search),
+		))
+	}
+	if filter.Tag != "" {
+		cond = cond.And(expression.Name("Tags").Contains(models.NormalizeTag(filter.Tag)))
+	}
+	return cond
+}
+
+// MergeTags scans the table for the entries that are not deleted and hold one of the merged tags, adding the target
+// tag to the string set of each and then deleting the merged tags from it. Adding to and deleting from the set keeps
+// concurrent changes of its other tags. Entries still in the legacy table have no tags
+func (client TableClient) MergeTags(ctx context.Context, merge models.TagMerge) (int, error) {
+	filter := expression.AttributeNotExists(expression.Name("DeletedAt")).And(mergeTagsCondition(merge))
+	if merge.Owner != "" {
+		filter = filter.And(expression.Name("Owner").Equal(expression.Value(merge.Owner)))
+	}
+	projection := expression.NamesList(expression.Name("ShortUrl"))
+	expr, err := expression.NewBuilder().WithFilter(filter).WithProjection(projection).Build()
+	if err != nil {
+		log.Printf("Couldn't build expression for scan. Here's why: %v\n", err)
+		return 0, err
+	}
+
+	merged := 0
+	err = client.scanTable(ctx, &dynamodb.ScanInput{
+		TableName:                 aws.String(client.TableName),
+		FilterExpression:          expr.Filter(),
+		ProjectionExpression:      expr.Projection(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	}, func(url models.Url) error {
+		ok, err := client.mergeItemTags(ctx, url.ShortUrl, merge)
+		if ok {
+			merged++
+		}
+		return err
+	})
+
+	return merged, err
+}
+
+// mergeTagsCondition matches an entry holding one of the merged tags
+func mergeTagsCondition(merge models.TagMerge) expression.ConditionBuilder {
+	sources := merge.Sources()
+	cond := expression.Name("Tags").Contains(sources[0])
+	for _, tag := range sources[1:] {
+		cond = cond.Or(expression.Name("Tags").Contains(tag))
+	}
+	return cond
+}
+
+// mergeTagsExpressions returns the updates of an entry merging tags, the first one adding the target tag while the
+// entry still holds one of the merged tags and the second one deleting the merged tags
+func mergeTagsExpressions(merge models.TagMerge) ([]expression.Expression, error) {
+	add, err := expression.NewBuilder().
+		WithCondition(liveItemCondition().And(mergeTagsCondition(merge))).
+		WithUpdate(expression.Add(expression.Name("Tags"),
+			expression.Value(&types.AttributeValueMemberSS{Value: []string{models.NormalizeTag(merge.To)}}))).
+		Build()
+	if err != nil {
+		return nil, err
+	}
+
+	remove, err := expression.NewBuilder().
+		WithCondition(liveItemCondition()).
+		WithUpdate(expression.Delete(expression.Name("Tags"),
+			expression.Value(&types.AttributeValueMemberSS{Value: merge.Sources()}))).
+		Build()
+	if err != nil {
+		return nil, err
+	}
+
+	return []expression.Expression{add, remove}, nil
+}
+
+// mergeItemTags applies the updates of mergeTagsExpressions to the entry of a shortened URL
+// Returns false when the entry was deleted, or no longer holds any of the merged tags
+func (client TableClient) mergeItemTags(ctx context.Context, shortUrl string, merge models.TagMerge) (bool, error) {
+	exprs, err := mergeTagsExpressions(merge)
+	if err != nil {
+		log.Printf("Couldn't build expression for update item. Here's why: %v\n", err)
+		return false, err
+	}
+
+	for _, expr := range exprs {
+		_, err = client.DynamoDbClient.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+			TableName:                 aws.String(client.TableName),
+			Key:                       map[string]types.AttributeValue{"ShortUrl": &types.AttributeValueMemberS{Value: shortUrl}},
+			ConditionExpression:       expr.Condition(),
+			UpdateExpression:          expr.Update(),
+			ExpressionAttributeNames:  expr.Names(),
+			ExpressionAttributeValues: expr.Values(),
+		})
+		var conditionFailed *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionFailed) {
+			return false, nil
+		}
+		if err != nil {
+			log.Printf("Couldn't merge tags of item with shortened url %v. Here's why: %v\n", shortUrl, err)
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// RecordClick adds one to the Clicks of a shortened URL with an UpdateItem that is conditional on Clicks
+// being below MaxClicks, so concurrent redirects never serve more than MaxClicks between them
+// Returns ErrClicksExhausted when the condition fails
+func (client TableClient) RecordClick(ctx context.Context, shortUrl string) error {
+	expr, err := recordClickExpression()
+	if err != nil {
+		log.Printf("Couldn't build expression for update item. Here's why: %v\n", err)
+		return err
+	}
+
+	_, err = client.DynamoDbClient.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(client.TableName),
+		Key:                       map[string]types.AttributeValue{"ShortUrl": &types.AttributeValueMemberS{Value: shortUrl}},
+		ConditionExpression:       expr.Condition(),
+		UpdateExpression:          expr.Update(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	})
+	var conditionFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) {
+		return ErrClicksExhausted
+	}
+	if err != nil {
+		log.Printf("Couldn't record click of shortened url %v. Here's why: %v\n", shortUrl, err)
+	}
+	return err
+}
+
+func recordClickExpression() (expression.Expression, error) {
+	clicks := expression.Name("Clicks")
+	maxClicks := expression.Name("MaxClicks")
+
+	cond := expression.And(
+		expression.AttributeExists(maxClicks),
+		expression.Or(expression.AttributeNotExists(clicks), clicks.LessThan(maxClicks)),
+	)
+	update := expression.Add(clicks, expression.Value(1))
+
+	return expression.NewBuilder().WithCondition(cond).WithUpdate(update).Build()
+}
+
+// ScanShortUrls scans the DynamoDB table page by page, calling fn with every shortened URL in it,
+// then does the same with the legacy table while its entries are migrated
+// Stops at the first error returned by DynamoDB or fn
+func (client TableClient) ScanShortUrls(ctx context.Context, fn func(shortUrl string) error) error {
+	projection := expression.NamesList(expression.Name("ShortUrl"))
+	expr, err := expression.NewBuilder().WithProjection(projection).Build()
+	if err != nil {
+		log.Printf("Couldn't build expression for scan. Here's why: %v\n", err)
+		return err
+	}
+
+	tableNames := []string{client.TableName}
+	if client.LegacyTableName != "" {
+		tableNames = append(tableNames, client.LegacyTableName)
+	}
+	for _, tableName := range tableNames {
+		err = client.scanTable(ctx, &dynamodb.ScanInput{
+			TableName:                aws.String(tableName),
+			ExpressionAttributeNames: expr.Names(),
+			ProjectionExpression:     expr.Projection(),
+		}, func(url models.Url) error { return fn(url.ShortUrl) })
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ScanUrls calls fn with every entry of the DynamoDB table, then with the entries of the legacy table that have not
+// been migrated yet while it is in use. The shortened URLs seen in the table are kept to leave out migrated entries
+// Stops at the first error returned by DynamoDB or fn
+func (client TableClient) ScanUrls(ctx context.Context, fn func(url models.Url) error) error {
+	var seen map[string]struct{}
+	if client.LegacyTableName != "" {
+		seen = make(map[string]struct{})
+	}
+
+	err := client.scanTable(ctx, &dynamodb.ScanInput{TableName: aws.String(client.TableName)}, func(url models.Url) error {
+		if seen != nil {
+			seen[url.ShortUrl] = struct{}{}
+		}
+		return fn(url)
+	})
+	if err != nil || seen == nil {
+		return err
+	}
+
+	return client.scanTable(ctx, &dynamodb.ScanInput{TableName: aws.String(client.LegacyTableName)}, func(url models.Url) error {
+		if _, ok := seen[url.ShortUrl]; ok {
+			return nil
+		}
+		return fn(url)
+	})
+}
+
+// MigrateLegacyTable copies every entry of the legacy table, partitioned by Id, into the table
+// partitioned by ShortUrl. Entries whose shortened URL is already in the table are skipped, so the
+// migration can be rerun and never overwrites entries written since the new layout went live
+// Returns the number of entries copied and skipped
+func (client TableClient) MigrateLegacyTable(ctx context.Context) (copied int, skipped int, err error) {
+	if client.LegacyTableName == "" {
+		return 0, 0, errors.New("no legacy table to migrate from")
+	}
+
+	err = client.scanTable(ctx, &dynamodb.ScanInput{TableName: aws.String(client.LegacyTableName)}, func(url models.Url) error {
+		err := client.putNewItem(ctx, url)
+		var conditionFailed *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionFailed) {
+			skipped++
+			return nil
+		}
+		if err != nil {
+			log.Printf("Couldn't copy item with shortened url %v. Here's why: %v\n", url.ShortUrl, err)
+			return err
+		}
+
+		copied++
+		return nil
+	})
+
+	return copied, skipped, err
+}
+
+// scanTable scans a DynamoDB table page by page, calling fn with every entry in it
+func (client TableClient) scanTable(ctx context.Context, input *dynamodb.ScanInput, fn func(url models.Url) error) error {
+	scanPaginator := dynamodb.NewScanPaginator(client.DynamoDbClient, input)
+	for scanPaginator.HasMorePages() {
+		response, err := scanPaginator.NextPage(ctx)
+		if err != nil {
+			log.Printf("Couldn't scan table %v. Here's why: %v\n", aws.ToString(input.TableName), err)
+			return err
+		}
+
+		var urlPage []models.Url
+		if err = attributevalue.UnmarshalListOfMaps(response.Items, &urlPage); err != nil {
+			log.Printf("Couldn't unmarshal scan response. Here's why: %v\n", err)
+			return err
+		}
+		for _, url := range urlPage {
+			if err = fn(url); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// AddApiKey puts an API key as an entry into the API key table
+func (client TableClient) AddApiKey(ctx context.Context, key models.ApiKey) error {
+	item, err := attributevalue.MarshalMap(key)
+	if err != nil {
+		return err
+	}
+
+	_, err = client.DynamoDbClient.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(client.ApiKeyTableName),
+		Item:      item,
+	})
+	if err != nil {
+		log.Printf("Couldn't add item to api key table. Here's why: %v\n", err)
+	}
+	return err
+}
+
+// RetrieveApiKey gets the entry of an API key from the API key table by its partition key with a strongly
+// consistent read, so revoked keys stop authenticating right away
+// Returns a zero ApiKey when no entry exists for id
+func (client TableClient) RetrieveApiKey(ctx context.Context, id string) (models.ApiKey, error) {
+	response, err := client.DynamoDbClient.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(client.ApiKeyTableName),
+		Key:            map[string]types.AttributeValue{"Id": &types.AttributeValueMemberS{Value: id}},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		log.Printf("Couldn't get item with api key %v. Here's why: %v\n", id, err)
+		return models.ApiKey{}, err
+	}
+
+	var key models.ApiKey
+	if response.Item == nil {
+		return key, nil
+	}
+	if err = attributevalue.UnmarshalMap(response.Item, &key); err != nil {
+		log.Printf("Couldn't unmarshal get item response. Here's why: %v\n", err)
+		return models.ApiKey{}, err
+	}
+	return key, nil
+}
+
+// ListApiKeys scans the API key table for the entries of owner, or every entry when it is empty, sorting them by Id.
+// The table only holds a handful of keys, so it has no index on Owner
+func (client TableClient) ListApiKeys(ctx context.Context, owner string) ([]models.ApiKey, error) {
+	input := &dynamodb.ScanInput{TableName: aws.String(client.ApiKeyTableName)}
+	if owner != "" {
+		expr, err := expression.NewBuilder().
+			WithFilter(expression.Name("Owner").Equal(expression.Value(owner))).
+			Build()
+		if err != nil {
+			log.Printf("Couldn't build expression for scan. Here's why: %v\n", err)
+			return nil, err
+		}
+		input.FilterExpression = expr.Filter()
+		input.ExpressionAttributeNames = expr.Names()
+		input.ExpressionAttributeValues = expr.Values()
+	}
+
+	keys := make([]models.ApiKey, 0)
+	scanPaginator := dynamodb.NewScanPaginator(client.DynamoDbClient, input)
+	for scanPaginator.HasMorePages() {
+		response, err := scanPaginator.NextPage(ctx)
+		if err != nil {
+			log.Printf("Couldn't scan table %v. Here's why: %v\n", client.ApiKeyTableName, err)
+			return nil, err
+		}
+
+		var keyPage []models.ApiKey
+		if err = attributevalue.UnmarshalListOfMaps(response.Items, &keyPage); err != nil {
+			log.Printf("Couldn't unmarshal scan response. Here's why: %v\n", err)
+			return nil, err
+		}
+		keys = append(keys, keyPage...)
+	}
+
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Id < keys[j].Id })
+	return keys, nil
+}
+
+// RevokeApiKey sets the RevokedAt of the entry of an API key with an UpdateItem conditional on the entry existing
+// and not being revoked
+// Returns ErrApiKeyNotFound when there is no entry to revoke
+func (client TableClient) RevokeApiKey(ctx context.Context, id string, revokedAt time.Time) error {
+	cond := expression.AttributeExists(expression.Name("Id")).
+		And(expression.AttributeNotExists(expression.Name("RevokedAt")))
+	changes := expression.Set(expression.Name("RevokedAt"), expression.Value(revokedAt.Unix()))
+	expr, err := expression.NewBuilder().WithCondition(cond).WithUpdate(changes).Build()
+	if err != nil {
+		log.Printf("Couldn't build expression for update item. Here's why: %v\n", err)
+		return err
+	}
+
+	_, err = client.DynamoDbClient.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(client.ApiKeyTableName),
+		Key:                       map[string]types.AttributeValue{"Id": &types.AttributeValueMemberS{Value: id}},
+		ConditionExpression:       expr.Condition(),
+		UpdateExpression:          expr.Update(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	})
+	var conditionFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) {
+		return ErrApiKeyNotFound
+	}
+	if err != nil {
+		log.Printf("Couldn't revoke api key %v. Here's why: %v\n", id, err)
+	}
+	return err
 }
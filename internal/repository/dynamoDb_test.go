@@ -3,9 +3,10 @@ package repository
 import (
 	"context"
 	"errors"
-	"fmt"
+	"reflect"
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
@@ -27,6 +28,8 @@ func enterTest() (context.Context, *testtools.AwsmStubber, *TableClient) {
 func TestTableClient_AddUrl(t *testing.T) {
 	t.Run("NoErrors", func(t *testing.T) { AddUrl(nil, t) })
 	t.Run("TestError", func(t *testing.T) { AddUrl(&testtools.StubError{Err: errors.New("TestError")}, t) })
+	t.Run("ShortUrlTaken", func(t *testing.T) { AddTakenUrl(t) })
+	t.Run("LegacyShortUrlTaken", func(t *testing.T) { AddLegacyTakenUrl(t) })
 }
 
 func AddUrl(raiseErr *testtools.StubError, t *testing.T) {
@@ -46,19 +49,253 @@ func AddUrl(raiseErr *testtools.StubError, t *testing.T) {
 	testtools.ExitTest(stubber, t)
 }
 
+func AddTakenUrl(t *testing.T) {
+	ctx, stubber, client := enterTest()
+
+	url := models.Url{Id: 12345, LongUrl: "https://www.youtube.com", ShortUrl: "NEDF34qw"}
+	item, _ := attributevalue.MarshalMap(url)
+
+	stubber.Add(StubAddUrl(client.TableName, item, &testtools.StubError{
+		Err:           &types.ConditionalCheckFailedException{},
+		ContinueAfter: true,
+	}))
+
+	err := client.AddUrl(ctx, url)
+
+	if !errors.Is(err, ErrShortUrlTaken) {
+		t.Errorf("Expected ErrShortUrlTaken, got %v", err)
+	}
+	testtools.ExitTest(stubber, t)
+}
+
+func AddLegacyTakenUrl(t *testing.T) {
+	ctx, stubber, client := enterTest()
+	client.LegacyTableName = constants.LegacyTableName
+
+	url := models.Url{Id: 12345, LongUrl: "https://www.youtube.com", ShortUrl: "NEDF34qw"}
+
+	stubber.Add(StubRetrieveLegacyUrl(client.LegacyTableName, url.ShortUrl, "https://www.google.com", 67890, nil))
+
+	err := client.AddUrl(ctx, url)
+
+	if !errors.Is(err, ErrShortUrlTaken) {
+		t.Errorf("Expected ErrShortUrlTaken, got %v", err)
+	}
+	testtools.ExitTest(stubber, t)
+}
+
 func StubAddUrl(tableName string, item map[string]types.AttributeValue, raiseErr *testtools.StubError) testtools.Stub {
+	cond := expression.AttributeNotExists(expression.Name("ShortUrl"))
+	expr, _ := expression.NewBuilder().WithCondition(cond).Build()
+
 	return testtools.Stub{
 		OperationName: "PutItem",
-		Input:         &dynamodb.PutItemInput{TableName: aws.String(tableName), Item: item},
+		Input: &dynamodb.PutItemInput{
+			TableName:                aws.String(tableName),
+			Item:                     item,
+			ConditionExpression:      expr.Condition(),
+			ExpressionAttributeNames: expr.Names(),
+		},
+		Output: &dynamodb.PutItemOutput{},
+		Error:  raiseErr,
+	}
+}
+
+func TestTableClient_AddUrls(t *testing.T) {
+	t.Run("NoErrors", func(t *testing.T) { AddUrls(t) })
+	t.Run("TestError", func(t *testing.T) { AddUrlsError(t) })
+	t.Run("ShortUrlTaken", func(t *testing.T) { AddTakenUrls(t) })
+	t.Run("LegacyShortUrlTaken", func(t *testing.T) { AddLegacyTakenUrls(t) })
+	t.Run("Conflicts", func(t *testing.T) { AddConflictingUrls(t) })
+	t.Run("Rejected", func(t *testing.T) { AddRejectedUrls(t) })
+}
+
+func batchUrls(n int) ([]models.Url, []map[string]types.AttributeValue) {
+	urls := make([]models.Url, n)
+	items := make([]map[string]types.AttributeValue, n)
+	for i := range urls {
+		urls[i] = models.Url{Id: uint64(i + 1), ShortUrl: "code" + strconv.Itoa(i+1), LongUrl: "https://www.youtube.com"}
+		items[i], _ = attributevalue.MarshalMap(urls[i])
+	}
+	return urls, items
+}
+
+func AddUrls(t *testing.T) {
+	ctx, stubber, client := enterTest()
+	urls, items := batchUrls(130)
+
+	// A transaction is limited to 100 items
+	stubber.Add(StubTransactWriteUrls(client.TableName, items[:100], nil))
+	stubber.Add(StubTransactWriteUrls(client.TableName, items[100:], nil))
+
+	errs := client.AddUrls(ctx, urls)
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("Expected url %v to be added, got %v", i, err)
+		}
+	}
+	testtools.ExitTest(stubber, t)
+}
+
+func AddUrlsError(t *testing.T) {
+	ctx, stubber, client := enterTest()
+	urls, items := batchUrls(3)
+	raiseErr := &testtools.StubError{Err: errors.New("TestError")}
+
+	stubber.Add(StubTransactWriteUrls(client.TableName, items, raiseErr))
+
+	errs := client.AddUrls(ctx, urls)
+
+	for _, err := range errs {
+		testtools.VerifyError(err, raiseErr, t)
+	}
+	testtools.ExitTest(stubber, t)
+}
+
+func AddTakenUrls(t *testing.T) {
+	ctx, stubber, client := enterTest()
+	urls, items := batchUrls(3)
+
+	// The second shortened URL is already in the table, which cancels the transaction of all three
+	stubber.Add(StubTransactWriteUrls(client.TableName, items, cancelledTransaction("None", "ConditionalCheckFailed", "None")))
+	stubber.Add(StubTransactWriteUrls(client.TableName, []map[string]types.AttributeValue{items[0], items[2]}, nil))
+
+	errs := client.AddUrls(ctx, urls)
+
+	if errs[0] != nil || errs[2] != nil {
+		t.Errorf("Expected the free urls to be added, got %v", errs)
+	}
+	if !errors.Is(errs[1], ErrShortUrlTaken) {
+		t.Errorf("Expected ErrShortUrlTaken, got %v", errs[1])
+	}
+	testtools.ExitTest(stubber, t)
+}
+
+func AddLegacyTakenUrls(t *testing.T) {
+	ctx, stubber, client := enterTest()
+	client.LegacyTableName = constants.LegacyTableName
+	urls, items := batchUrls(2)
+
+	stubber.Add(StubRetrieveLegacyUrl(client.LegacyTableName, urls[0].ShortUrl, "https://www.google.com", 67890, nil))
+	missing := StubRetrieveLegacyUrl(client.LegacyTableName, urls[1].ShortUrl, "", 0, nil)
+	missing.Output = &dynamodb.QueryOutput{}
+	stubber.Add(missing)
+	stubber.Add(StubTransactWriteUrls(client.TableName, items[1:], nil))
+
+	errs := client.AddUrls(ctx, urls)
+
+	if !errors.Is(errs[0], ErrShortUrlTaken) {
+		t.Errorf("Expected ErrShortUrlTaken, got %v", errs[0])
+	}
+	if errs[1] != nil {
+		t.Errorf("Expected the free url to be added, got %v", errs[1])
+	}
+	testtools.ExitTest(stubber, t)
+}
+
+func AddConflictingUrls(t *testing.T) {
+	ctx, stubber, client := enterTest()
+	urls, items := batchUrls(2)
+
+	for attempt := 1; attempt <= constants.BatchWriteAttempts; attempt++ {
+		stubber.Add(StubTransactWriteUrls(client.TableName, items, cancelledTransaction("TransactionConflict", "None")))
+	}
+
+	errs := client.AddUrls(ctx, urls)
+
+	for _, err := range errs {
+		if !errors.Is(err, ErrUnprocessedItem) {
+			t.Errorf("Expected ErrUnprocessedItem, got %v", err)
+		}
+	}
+	testtools.ExitTest(stubber, t)
+}
+
+func AddRejectedUrls(t *testing.T) {
+	ctx, stubber, client := enterTest()
+	urls, items := batchUrls(2)
+
+	// The rejected put is not retried, the other one is written again without waiting
+	stubber.Add(StubTransactWriteUrls(client.TableName, items, cancelledTransaction("ValidationError", "None")))
+	stubber.Add(StubTransactWriteUrls(client.TableName, items[1:], nil))
+
+	errs := client.AddUrls(ctx, urls)
+
+	if !errors.Is(errs[0], ErrItemRejected) {
+		t.Errorf("Expected ErrItemRejected, got %v", errs[0])
+	}
+	if errs[1] != nil {
+		t.Errorf("Expected the valid url to be added, got %v", errs[1])
+	}
+	testtools.ExitTest(stubber, t)
+}
+
+// cancelledTransaction is the error of a transaction DynamoDB cancelled for the reasons of each of its items
+func cancelledTransaction(codes ...string) *testtools.StubError {
+	reasons := make([]types.CancellationReason, len(codes))
+	for i, code := range codes {
+		reasons[i] = types.CancellationReason{Code: aws.String(code)}
+	}
+	return &testtools.StubError{Err: &types.TransactionCanceledException{CancellationReasons: reasons}, ContinueAfter: true}
+}
+
+func StubTransactWriteUrls(tableName string, items []map[string]types.AttributeValue, raiseErr *testtools.StubError) testtools.Stub {
+	cond := expression.AttributeNotExists(expression.Name("ShortUrl"))
+	expr, _ := expression.NewBuilder().WithCondition(cond).Build()
+
+	transactItems := make([]types.TransactWriteItem, len(items))
+	for i, item := range items {
+		transactItems[i] = types.TransactWriteItem{Put: &types.Put{
+			TableName:                aws.String(tableName),
+			Item:                     item,
+			ConditionExpression:      expr.Condition(),
+			ExpressionAttributeNames: expr.Names(),
+		}}
+	}
+
+	return testtools.Stub{
+		OperationName: "TransactWriteItems",
+		Input:         &dynamodb.TransactWriteItemsInput{TransactItems: transactItems},
+		Output:        &dynamodb.TransactWriteItemsOutput{},
+		// The SDK fills in a random idempotency token for every transaction
+		IgnoreFields: []string{"ClientRequestToken"},
+		Error:        raiseErr,
+	}
+}
+
+func TestTableClient_PutUrl(t *testing.T) {
+	t.Run("NoErrors", func(t *testing.T) { PutUrl(nil, t) })
+	t.Run("TestError", func(t *testing.T) { PutUrl(&testtools.StubError{Err: errors.New("TestError")}, t) })
+}
+
+func PutUrl(raiseErr *testtools.StubError, t *testing.T) {
+	ctx, stubber, client := enterTest()
+
+	deletedAt := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	url := models.Url{Id: 12345, LongUrl: "https://www.youtube.com", ShortUrl: "NEDF34qw", Clicks: 3, DeletedAt: &deletedAt}
+	item, _ := attributevalue.MarshalMap(url)
+
+	stubber.Add(testtools.Stub{
+		OperationName: "PutItem",
+		Input:         &dynamodb.PutItemInput{TableName: aws.String(client.TableName), Item: item},
 		Output:        &dynamodb.PutItemOutput{},
 		Error:         raiseErr,
-	}
+	})
+
+	err := client.PutUrl(ctx, url)
+
+	testtools.VerifyError(err, raiseErr, t)
+	testtools.ExitTest(stubber, t)
 }
 
 func TestTableClient_RetrieveUrl(t *testing.T) {
 	t.Run("NoErrors", func(t *testing.T) { RetrieveUrl(nil, t) })
 	t.Run("TestError", func(t *testing.T) { RetrieveUrl(&testtools.StubError{Err: errors.New("TestError")}, t) })
 	t.Run("NoUrlsRetrieved", func(t *testing.T) { RetrieveNoUrl(nil, t) })
+	t.Run("LegacyUrlRetrieved", func(t *testing.T) { RetrieveLegacyUrl(nil, t) })
+	t.Run("LegacyTestError", func(t *testing.T) { RetrieveLegacyUrl(&testtools.StubError{Err: errors.New("TestError")}, t) })
+	t.Run("ExpiringUrlRetrieved", func(t *testing.T) { RetrieveExpiringUrl(t) })
 }
 
 func RetrieveUrl(raiseErr *testtools.StubError, t *testing.T) {
@@ -70,41 +307,108 @@ func RetrieveUrl(raiseErr *testtools.StubError, t *testing.T) {
 
 	stubber.Add(StubRetrieveUrl(client.TableName, shortUrl, longUrl, Id, raiseErr))
 
-	urls, err := client.RetrieveUrl(ctx, shortUrl)
+	url, err := client.RetrieveUrl(ctx, shortUrl)
+
+	testtools.VerifyError(err, raiseErr, t)
+	if err == nil && url.LongUrl != longUrl {
+		t.Errorf("Expected %v, got %v", longUrl, url.LongUrl)
+	}
+
+	testtools.ExitTest(stubber, t)
+}
+
+func RetrieveNoUrl(raiseErr *testtools.StubError, t *testing.T) {
+	ctx, stubber, client := enterTest()
+
+	shortUrl := "NEWDSa31"
+
+	stubber.Add(StubRetrieveNoUrl(client.TableName, shortUrl, raiseErr))
+
+	url, err := client.RetrieveUrl(ctx, shortUrl)
 
 	testtools.VerifyError(err, raiseErr, t)
+
 	if err == nil {
-		if len(urls) == 0 {
-			t.Errorf("Expected at least 1 url")
+		if url.ShortUrl != "" {
+			t.Errorf("Expected no url, got %v", url)
 		}
 	}
 
 	testtools.ExitTest(stubber, t)
 }
 
-func RetrieveNoUrl(raiseErr *testtools.StubError, t *testing.T) {
+func RetrieveLegacyUrl(raiseErr *testtools.StubError, t *testing.T) {
 	ctx, stubber, client := enterTest()
+	client.LegacyTableName = constants.LegacyTableName
 
 	shortUrl := "NEWDSa31"
 	longUrl := "https://www.youtube.com"
 	var Id uint64 = 5438989247290
 
-	stubber.Add(StubRetrieveNoUrl(client.TableName, shortUrl, longUrl, Id, raiseErr))
+	stubber.Add(StubRetrieveNoUrl(client.TableName, shortUrl, nil))
+	stubber.Add(StubRetrieveLegacyUrl(client.LegacyTableName, shortUrl, longUrl, Id, raiseErr))
 
-	urls, err := client.RetrieveUrl(ctx, shortUrl)
+	url, err := client.RetrieveUrl(ctx, shortUrl)
 
 	testtools.VerifyError(err, raiseErr, t)
+	if err == nil && url.LongUrl != longUrl {
+		t.Errorf("Expected %v, got %v", longUrl, url.LongUrl)
+	}
 
-	if err == nil {
-		if len(urls) == 0 {
-			fmt.Println("No URLs retrieved")
-		}
+	testtools.ExitTest(stubber, t)
+}
+
+func RetrieveExpiringUrl(t *testing.T) {
+	ctx, stubber, client := enterTest()
+
+	shortUrl := "NEWDSa31"
+	expiresAt := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	stub := StubRetrieveUrl(client.TableName, shortUrl, "https://www.youtube.com", 5438989247290, nil)
+	stub.Output.(*dynamodb.GetItemOutput).Item["ExpiresAt"] = &types.AttributeValueMemberN{Value: strconv.FormatInt(expiresAt.Unix(), 10)}
+	stubber.Add(stub)
+
+	url, err := client.RetrieveUrl(ctx, shortUrl)
+
+	testtools.VerifyError(err, nil, t)
+	if url.ExpiresAt == nil || !url.ExpiresAt.Equal(expiresAt) {
+		t.Errorf("Expected expiry %v, got %v", expiresAt, url.ExpiresAt)
 	}
 
 	testtools.ExitTest(stubber, t)
 }
 
 func StubRetrieveUrl(tableName string, shortUrl string, longUrl string, id uint64, raiseErr *testtools.StubError) testtools.Stub {
+	return testtools.Stub{
+		OperationName: "GetItem",
+		Input: &dynamodb.GetItemInput{
+			TableName:      aws.String(tableName),
+			Key:            map[string]types.AttributeValue{"ShortUrl": &types.AttributeValueMemberS{Value: shortUrl}},
+			ConsistentRead: aws.Bool(false),
+		},
+		Output: &dynamodb.GetItemOutput{Item: map[string]types.AttributeValue{
+			"Id":       &types.AttributeValueMemberN{Value: strconv.FormatUint(id, 10)},
+			"ShortUrl": &types.AttributeValueMemberS{Value: shortUrl},
+			"LongUrl":  &types.AttributeValueMemberS{Value: longUrl},
+		}},
+		Error: raiseErr,
+	}
+}
+
+func StubRetrieveNoUrl(tableName string, shortUrl string, raiseErr *testtools.StubError) testtools.Stub {
+	return testtools.Stub{
+		OperationName: "GetItem",
+		Input: &dynamodb.GetItemInput{
+			TableName:      aws.String(tableName),
+			Key:            map[string]types.AttributeValue{"ShortUrl": &types.AttributeValueMemberS{Value: shortUrl}},
+			ConsistentRead: aws.Bool(false),
+		},
+		Output: &dynamodb.GetItemOutput{},
+		Error:  raiseErr,
+	}
+}
+
+func StubRetrieveLegacyUrl(tableName string, shortUrl string, longUrl string, id uint64, raiseErr *testtools.StubError) testtools.Stub {
 	keyEx := expression.Key("ShortUrl").Equal(expression.Value(shortUrl))
 	expr, _ := expression.NewBuilder().WithKeyCondition(keyEx).Build()
 
@@ -126,20 +430,644 @@ func StubRetrieveUrl(tableName string, shortUrl string, longUrl string, id uint6
 	}
 }
 
-func StubRetrieveNoUrl(tableName string, shortUrl string, longUrl string, id uint64, raiseErr *testtools.StubError) testtools.Stub {
-	keyEx := expression.Key("ShortUrl").Equal(expression.Value(shortUrl))
-	expr, _ := expression.NewBuilder().WithKeyCondition(keyEx).Build()
+func TestTableClient_RetrieveReusableUrl(t *testing.T) {
+	t.Run("NoErrors", func(t *testing.T) { RetrieveReusableUrl("alice", nil, t) })
+	t.Run("NoOwner", func(t *testing.T) { RetrieveReusableUrl("", nil, t) })
+	t.Run("TestError", func(t *testing.T) {
+		RetrieveReusableUrl("alice", &testtools.StubError{Err: errors.New("TestError")}, t)
+	})
+	t.Run("NoIndex", func(t *testing.T) {
+		ctx, stubber, client := enterTest()
+		url, err := client.RetrieveReusableUrl(ctx, "alice", "https://example.com/")
+		if err != nil || url.ShortUrl != "" {
+			t.Errorf("Expected no url without a destination index, got %v, %v", url, err)
+		}
+		testtools.ExitTest(stubber, t)
+	})
+}
+
+func RetrieveReusableUrl(owner string, raiseErr *testtools.StubError, t *testing.T) {
+	ctx, stubber, client := enterTest()
+	client.DestinationIndexName = constants.DestinationIndex
+
+	destination := "https://example.com/"
+	urls := []models.Url{
+		{Id: 2, ShortUrl: "bbb", LongUrl: destination, Destination: destination, Owner: owner},
+		{Id: 1, ShortUrl: "aaa", LongUrl: destination, Destination: destination, Owner: owner},
+	}
+	stubber.Add(StubRetrieveReusableUrl(client.TableName, owner, destination, urls, raiseErr))
+
+	url, err := client.RetrieveReusableUrl(ctx, owner, destination)
+
+	testtools.VerifyError(err, raiseErr, t)
+	if err == nil && url.ShortUrl != "aaa" {
+		t.Errorf("Expected the reusable url with the lowest shortened url, got %v", url.ShortUrl)
+	}
+	testtools.ExitTest(stubber, t)
+}
+
+func StubRetrieveReusableUrl(tableName string, owner string, destination string, urls []models.Url, raiseErr *testtools.StubError) testtools.Stub {
+	expr, _ := expression.NewBuilder().
+		WithKeyCondition(expression.Key("Destination").Equal(expression.Value(destination))).
+		WithFilter(reusableUrlCondition(owner)).
+		Build()
+	var items []map[string]types.AttributeValue
+	for _, url := range urls {
+		item, _ := attributevalue.MarshalMap(url)
+		items = append(items, item)
+	}
 
 	return testtools.Stub{
 		OperationName: "Query",
 		Input: &dynamodb.QueryInput{
 			TableName:                 aws.String(tableName),
+			IndexName:                 aws.String(constants.DestinationIndex),
 			ExpressionAttributeNames:  expr.Names(),
 			ExpressionAttributeValues: expr.Values(),
 			KeyConditionExpression:    expr.KeyCondition(),
-			IndexName:                 aws.String(constants.TableSecondaryIndex),
+			FilterExpression:          expr.Filter(),
 		},
-		Output: &dynamodb.QueryOutput{Items: []map[string]types.AttributeValue{}},
+		Output: &dynamodb.QueryOutput{Items: items},
 		Error:  raiseErr,
 	}
 }
+
+func TestTableClient_UpdateUrl(t *testing.T) {
+	t.Run("NoErrors", func(t *testing.T) { UpdateUrl(nil, t) })
+	t.Run("TestError", func(t *testing.T) { UpdateUrl(&testtools.StubError{Err: errors.New("TestError")}, t) })
+	t.Run("NotFound", func(t *testing.T) { UpdateNoUrl(t) })
+	t.Run("LegacyUrlMigrated", func(t *testing.T) { UpdateLegacyUrl(t) })
+	t.Run("RemoveExpiry", func(t *testing.T) { UpdateRemoveExpiry(t) })
+}
+
+func UpdateUrl(raiseErr *testtools.StubError, t *testing.T) {
+	ctx, stubber, client := enterTest()
+
+	longUrl := "https://www.google.com"
+	update := models.UrlUpdate{LongUrl: &longUrl}
+	changes, _ := updateUrlExpression(update)
+
+	stubber.Add(StubUpdateLiveItem(client.TableName, "NEDF34qw", changes, models.Url{Id: 12345, ShortUrl: "NEDF34qw", LongUrl: longUrl}, raiseErr))
+
+	url, err := client.UpdateUrl(ctx, "NEDF34qw", update)
+
+	testtools.VerifyError(err, raiseErr, t)
+	if err == nil && url.LongUrl != longUrl {
+		t.Errorf("Expected %v, got %v", longUrl, url.LongUrl)
+	}
+	testtools.ExitTest(stubber, t)
+}
+
+func UpdateRemoveExpiry(t *testing.T) {
+	ctx, stubber, client := enterTest()
+
+	stubber.Add(StubUpdateLiveItem(client.TableName, "NEDF34qw", expression.Remove(expression.Name("ExpiresAt")),
+		models.Url{Id: 12345, ShortUrl: "NEDF34qw", LongUrl: "https://www.youtube.com"}, nil))
+
+	url, err := client.UpdateUrl(ctx, "NEDF34qw", models.UrlUpdate{RemoveExpiry: true})
+
+	testtools.VerifyError(err, nil, t)
+	if url.ExpiresAt != nil {
+		t.Errorf("Expected no expiry, got %v", url.ExpiresAt)
+	}
+	testtools.ExitTest(stubber, t)
+}
+
+func UpdateNoUrl(t *testing.T) {
+	ctx, stubber, client := enterTest()
+
+	maxClicks := int64(0)
+	update := models.UrlUpdate{MaxClicks: &maxClicks}
+	changes, _ := updateUrlExpression(update)
+
+	stubber.Add(StubUpdateLiveItem(client.TableName, "NEDF34qw", changes, models.Url{}, &testtools.StubError{
+		Err:           &types.ConditionalCheckFailedException{},
+		ContinueAfter: true,
+	}))
+
+	_, err := client.UpdateUrl(ctx, "NEDF34qw", update)
+
+	if !errors.Is(err, ErrUrlNotFound) {
+		t.Errorf("Expected ErrUrlNotFound, got %v", err)
+	}
+	testtools.ExitTest(stubber, t)
+}
+
+func UpdateLegacyUrl(t *testing.T) {
+	ctx, stubber, client := enterTest()
+	client.LegacyTableName = constants.LegacyTableName
+
+	longUrl := "https://www.google.com"
+	update := models.UrlUpdate{LongUrl: &longUrl}
+	changes, _ := updateUrlExpression(update)
+	legacyUrl := models.Url{Id: 67890, ShortUrl: "NEDF34qw", LongUrl: "https://www.youtube.com"}
+	item, _ := attributevalue.MarshalMap(legacyUrl)
+
+	stubber.Add(StubUpdateLiveItem(client.TableName, legacyUrl.ShortUrl, changes, models.Url{}, &testtools.StubError{
+		Err:           &types.ConditionalCheckFailedException{},
+		ContinueAfter: true,
+	}))
+	stubber.Add(StubRetrieveLegacyUrl(client.LegacyTableName, legacyUrl.ShortUrl, legacyUrl.LongUrl, legacyUrl.Id, nil))
+	stubber.Add(StubAddUrl(client.TableName, item, nil))
+	stubber.Add(StubDeleteLegacyItem(client.LegacyTableName, legacyUrl.Id, nil))
+	stubber.Add(StubUpdateLiveItem(client.TableName, legacyUrl.ShortUrl, changes, models.Url{Id: 67890, ShortUrl: "NEDF34qw", LongUrl: longUrl}, nil))
+
+	url, err := client.UpdateUrl(ctx, legacyUrl.ShortUrl, update)
+
+	testtools.VerifyError(err, nil, t)
+	if url.LongUrl != longUrl {
+		t.Errorf("Expected %v, got %v", longUrl, url.LongUrl)
+	}
+	testtools.ExitTest(stubber, t)
+}
+
+func TestTableClient_DeleteUrl(t *testing.T) {
+	t.Run("NoErrors", func(t *testing.T) { DeleteUrl(nil, t) })
+	t.Run("TestError", func(t *testing.T) { DeleteUrl(&testtools.StubError{Err: errors.New("TestError")}, t) })
+	t.Run("NotFound", func(t *testing.T) { DeleteNoUrl(t) })
+}
+
+func DeleteUrl(raiseErr *testtools.StubError, t *testing.T) {
+	ctx, stubber, client := enterTest()
+
+	stubber.Add(StubUpdateLiveItem(client.TableName, "NEDF34qw", deleteUrlExpression(time.Now()), models.Url{}, raiseErr))
+
+	err := client.DeleteUrl(ctx, "NEDF34qw")
+
+	testtools.VerifyError(err, raiseErr, t)
+	testtools.ExitTest(stubber, t)
+}
+
+func DeleteNoUrl(t *testing.T) {
+	ctx, stubber, client := enterTest()
+
+	stubber.Add(StubUpdateLiveItem(client.TableName, "NEDF34qw", deleteUrlExpression(time.Now()), models.Url{}, &testtools.StubError{
+		Err:           &types.ConditionalCheckFailedException{},
+		ContinueAfter: true,
+	}))
+
+	err := client.DeleteUrl(ctx, "NEDF34qw")
+
+	if !errors.Is(err, ErrUrlNotFound) {
+		t.Errorf("Expected ErrUrlNotFound, got %v", err)
+	}
+	testtools.ExitTest(stubber, t)
+}
+
+// StubUpdateLiveItem stubs the conditional UpdateItem of updateLiveItem. The values of the expression are
+// not compared, as the DeletedAt set by DeleteUrl depends on the time
+func StubUpdateLiveItem(tableName string, shortUrl string, changes expression.UpdateBuilder, updated models.Url, raiseErr *testtools.StubError) testtools.Stub {
+	expr, _ := expression.NewBuilder().WithCondition(liveItemCondition()).WithUpdate(changes).Build()
+	attributes, _ := attributevalue.MarshalMap(updated)
+
+	return testtools.Stub{
+		OperationName: "UpdateItem",
+		Input: &dynamodb.UpdateItemInput{
+			TableName:                 aws.String(tableName),
+			Key:                       map[string]types.AttributeValue{"ShortUrl": &types.AttributeValueMemberS{Value: shortUrl}},
+			ConditionExpression:       expr.Condition(),
+			UpdateExpression:          expr.Update(),
+			ExpressionAttributeNames:  expr.Names(),
+			ExpressionAttributeValues: expr.Values(),
+			ReturnValues:              types.ReturnValueAllNew,
+		},
+		Output:       &dynamodb.UpdateItemOutput{Attributes: attributes},
+		Error:        raiseErr,
+		IgnoreFields: []string{"ExpressionAttributeValues"},
+	}
+}
+
+func StubDeleteLegacyItem(tableName string, id uint64, raiseErr *testtools.StubError) testtools.Stub {
+	return testtools.Stub{
+		OperationName: "DeleteItem",
+		Input: &dynamodb.DeleteItemInput{
+			TableName: aws.String(tableName),
+			Key:       map[string]types.AttributeValue{"Id": &types.AttributeValueMemberN{Value: strconv.FormatUint(id, 10)}},
+		},
+		Output: &dynamodb.DeleteItemOutput{},
+		Error:  raiseErr,
+	}
+}
+
+func TestTableClient_ListUrls(t *testing.T) {
+	t.Run("NoErrors", func(t *testing.T) { ListUrls(nil, t) })
+	t.Run("TestError", func(t *testing.T) { ListUrls(&testtools.StubError{Err: errors.New("TestError")}, t) })
+	t.Run("InvalidCursor", func(t *testing.T) { ListUrlsInvalidCursor(t) })
+}
+
+func ListUrls(raiseErr *testtools.StubError, t *testing.T) {
+	ctx, stubber, client := enterTest()
+	filter := models.UrlFilter{Owner: "alice"}
+
+	// The first scanned page holds a single match, so a second page is scanned to fill the limit
+	stubber.Add(StubListUrls(client.TableName, filter, "", 2, []string{"aaa"}, "bbb", raiseErr))
+	if raiseErr == nil {
+		stubber.Add(StubListUrls(client.TableName, filter, "bbb", 2, []string{"ccc", "ddd"}, "ddd", nil))
+	}
+
+	page, err := client.ListUrls(ctx, filter, "", 2)
+
+	testtools.VerifyError(err, raiseErr, t)
+	if err == nil {
+		if len(page.Links) != 2 || page.Links[0].ShortUrl != "aaa" || page.Links[1].ShortUrl != "ccc" {
+			t.Errorf("Expected links aaa and ccc, got %v", page.Links)
+		}
+		if after, _ := decodeCursor(page.NextCursor); after != "ccc" {
+			t.Errorf("Expected next cursor after ccc, got %v", after)
+		}
+	}
+
+	testtools.ExitTest(stubber, t)
+}
+
+func ListUrlsInvalidCursor(t *testing.T) {
+	ctx, stubber, client := enterTest()
+
+	_, err := client.ListUrls(ctx, models.UrlFilter{}, "not base64!", 2)
+	if !errors.Is(err, ErrCursorInvalid) {
+		t.Errorf("Expected ErrCursorInvalid, got %v", err)
+	}
+
+	testtools.ExitTest(stubber, t)
+}
+
+func StubListUrls(tableName string, filter models.UrlFilter, after string, limit int32, shortUrls []string, lastShortUrl string, raiseErr *testtools.StubError) testtools.Stub {
+	expr, _ := expression.NewBuilder().WithFilter(listUrlsFilter(filter)).Build()
+
+	input := &dynamodb.ScanInput{
+		TableName:                 aws.String(tableName),
+		FilterExpression:          expr.Filter(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		Limit:                     aws.Int32(limit),
+	}
+	if after != "" {
+		input.ExclusiveStartKey = map[string]types.AttributeValue{"ShortUrl": &types.AttributeValueMemberS{Value: after}}
+	}
+
+	var items []map[string]types.AttributeValue
+	for i, shortUrl := range shortUrls {
+		item, _ := attributevalue.MarshalMap(models.Url{Id: uint64(i + 1), ShortUrl: shortUrl, LongUrl: "https://www.youtube.com", Owner: filter.Owner})
+		items = append(items, item)
+	}
+	output := &dynamodb.ScanOutput{Items: items}
+	if lastShortUrl != "" {
+		output.LastEvaluatedKey = map[string]types.AttributeValue{"ShortUrl": &types.AttributeValueMemberS{Value: lastShortUrl}}
+	}
+
+	return testtools.Stub{
+		OperationName: "Scan",
+		Input:         input,
+		Output:        output,
+		Error:         raiseErr,
+	}
+}
+
+func TestTableClient_MergeTags(t *testing.T) {
+	t.Run("NoErrors", func(t *testing.T) { MergeTags(nil, "", t) })
+	t.Run("TestError", func(t *testing.T) { MergeTags(&testtools.StubError{Err: errors.New("TestError")}, "", t) })
+	t.Run("Owner", func(t *testing.T) { MergeTags(nil, "alice", t) })
+}
+
+func MergeTags(raiseErr *testtools.StubError, owner string, t *testing.T) {
+	ctx, stubber, client := enterTest()
+	merge := models.TagMerge{From: []string{"golang"}, To: "go", Owner: owner}
+
+	stubber.Add(StubScanMergedTags(client.TableName, merge, []string{"NEDF34qw", "NEWDSa31"}))
+	stubber.Add(StubMergeItemTags(client.TableName, "NEDF34qw", merge, 0, raiseErr))
+	if raiseErr == nil {
+		stubber.Add(StubMergeItemTags(client.TableName, "NEDF34qw", merge, 1, nil))
+		// The second entry was changed since the scan and no longer holds the merged tag
+		stubber.Add(StubMergeItemTags(client.TableName, "NEWDSa31", merge, 0, &testtools.StubError{
+			Err:           &types.ConditionalCheckFailedException{},
+			ContinueAfter: true,
+		}))
+	}
+
+	merged, err := client.MergeTags(ctx, merge)
+
+	testtools.VerifyError(err, raiseErr, t)
+	if err == nil && merged != 1 {
+		t.Errorf("Expected 1 merged url, got %v", merged)
+	}
+	testtools.ExitTest(stubber, t)
+}
+
+func StubScanMergedTags(tableName string, merge models.TagMerge, shortUrls []string) testtools.Stub {
+	filter := expression.AttributeNotExists(expression.Name("DeletedAt")).And(mergeTagsCondition(merge))
+	if merge.Owner != "" {
+		filter = filter.And(expression.Name("Owner").Equal(expression.Value(merge.Owner)))
+	}
+	projection := expression.NamesList(expression.Name("ShortUrl"))
+	expr, _ := expression.NewBuilder().WithFilter(filter).WithProjection(projection).Build()
+
+	var items []map[string]types.AttributeValue
+	for _, shortUrl := range shortUrls {
+		items = append(items, map[string]types.AttributeValue{"ShortUrl": &types.AttributeValueMemberS{Value: shortUrl}})
+	}
+
+	return testtools.Stub{
+		OperationName: "Scan",
+		Input: &dynamodb.ScanInput{
+			TableName:                 aws.String(tableName),
+			FilterExpression:          expr.Filter(),
+			ProjectionExpression:      expr.Projection(),
+			ExpressionAttributeNames:  expr.Names(),
+			ExpressionAttributeValues: expr.Values(),
+		},
+		Output: &dynamodb.ScanOutput{Items: items},
+	}
+}
+
+// StubMergeItemTags stubs the nth update of mergeTagsExpressions
+func StubMergeItemTags(tableName string, shortUrl string, merge models.TagMerge, n int, raiseErr *testtools.StubError) testtools.Stub {
+	exprs, _ := mergeTagsExpressions(merge)
+
+	return testtools.Stub{
+		OperationName: "UpdateItem",
+		Input: &dynamodb.UpdateItemInput{
+			TableName:                 aws.String(tableName),
+			Key:                       map[string]types.AttributeValue{"ShortUrl": &types.AttributeValueMemberS{Value: shortUrl}},
+			ConditionExpression:       exprs[n].Condition(),
+			UpdateExpression:          exprs[n].Update(),
+			ExpressionAttributeNames:  exprs[n].Names(),
+			ExpressionAttributeValues: exprs[n].Values(),
+		},
+		Output: &dynamodb.UpdateItemOutput{},
+		Error:  raiseErr,
+	}
+}
+
+func TestTableClient_RecordClick(t *testing.T) {
+	t.Run("NoErrors", func(t *testing.T) { RecordClick(nil, t) })
+	t.Run("TestError", func(t *testing.T) { RecordClick(&testtools.StubError{Err: errors.New("TestError")}, t) })
+	t.Run("ClicksExhausted", func(t *testing.T) { RecordExhaustedClick(t) })
+}
+
+func RecordClick(raiseErr *testtools.StubError, t *testing.T) {
+	ctx, stubber, client := enterTest()
+
+	stubber.Add(StubRecordClick(client.TableName, "NEDF34qw", raiseErr))
+
+	err := client.RecordClick(ctx, "NEDF34qw")
+
+	testtools.VerifyError(err, raiseErr, t)
+	testtools.ExitTest(stubber, t)
+}
+
+func RecordExhaustedClick(t *testing.T) {
+	ctx, stubber, client := enterTest()
+
+	stubber.Add(StubRecordClick(client.TableName, "NEDF34qw", &testtools.StubError{
+		Err:           &types.ConditionalCheckFailedException{},
+		ContinueAfter: true,
+	}))
+
+	err := client.RecordClick(ctx, "NEDF34qw")
+
+	if !errors.Is(err, ErrClicksExhausted) {
+		t.Errorf("Expected ErrClicksExhausted, got %v", err)
+	}
+	testtools.ExitTest(stubber, t)
+}
+
+func StubRecordClick(tableName string, shortUrl string, raiseErr *testtools.StubError) testtools.Stub {
+	expr, _ := recordClickExpression()
+
+	return testtools.Stub{
+		OperationName: "UpdateItem",
+		Input: &dynamodb.UpdateItemInput{
+			TableName:                 aws.String(tableName),
+			Key:                       map[string]types.AttributeValue{"ShortUrl": &types.AttributeValueMemberS{Value: shortUrl}},
+			ConditionExpression:       expr.Condition(),
+			UpdateExpression:          expr.Update(),
+			ExpressionAttributeNames:  expr.Names(),
+			ExpressionAttributeValues: expr.Values(),
+		},
+		Output: &dynamodb.UpdateItemOutput{},
+		Error:  raiseErr,
+	}
+}
+
+func TestTableClient_MigrateLegacyTable(t *testing.T) {
+	t.Run("NoErrors", func(t *testing.T) { MigrateLegacyTable(nil, t) })
+	t.Run("TestError", func(t *testing.T) { MigrateLegacyTable(&testtools.StubError{Err: errors.New("TestError")}, t) })
+}
+
+func MigrateLegacyTable(raiseErr *testtools.StubError, t *testing.T) {
+	ctx, stubber, client := enterTest()
+	client.LegacyTableName = constants.LegacyTableName
+
+	migrated := models.Url{Id: 12345, LongUrl: "https://www.youtube.com", ShortUrl: "NEDF34qw"}
+	existing := models.Url{Id: 67890, LongUrl: "https://www.google.com", ShortUrl: "KWBG425d"}
+	migratedItem, _ := attributevalue.MarshalMap(migrated)
+	existingItem, _ := attributevalue.MarshalMap(existing)
+
+	stubber.Add(testtools.Stub{
+		OperationName: "Scan",
+		Input:         &dynamodb.ScanInput{TableName: aws.String(client.LegacyTableName)},
+		Output:        &dynamodb.ScanOutput{Items: []map[string]types.AttributeValue{migratedItem, existingItem}},
+	})
+	stubber.Add(StubAddUrl(client.TableName, migratedItem, raiseErr))
+	if raiseErr == nil {
+		stubber.Add(StubAddUrl(client.TableName, existingItem, &testtools.StubError{
+			Err:           &types.ConditionalCheckFailedException{},
+			ContinueAfter: true,
+		}))
+	}
+
+	copied, skipped, err := client.MigrateLegacyTable(ctx)
+
+	testtools.VerifyError(err, raiseErr, t)
+	if err == nil && (copied != 1 || skipped != 1) {
+		t.Errorf("Expected 1 entry copied and 1 skipped, got %v copied and %v skipped", copied, skipped)
+	}
+
+	testtools.ExitTest(stubber, t)
+}
+
+func TestTableClient_ScanShortUrls(t *testing.T) {
+	t.Run("NoErrors", func(t *testing.T) { ScanShortUrls(nil, t) })
+	t.Run("TestError", func(t *testing.T) { ScanShortUrls(&testtools.StubError{Err: errors.New("TestError")}, t) })
+}
+
+func ScanShortUrls(raiseErr *testtools.StubError, t *testing.T) {
+	ctx, stubber, client := enterTest()
+
+	shortUrls := []string{"NEDF34qw", "NEWDSa31"}
+	stubber.Add(StubScanShortUrls(client.TableName, shortUrls, raiseErr))
+
+	var scanned []string
+	err := client.ScanShortUrls(ctx, func(shortUrl string) error {
+		scanned = append(scanned, shortUrl)
+		return nil
+	})
+
+	testtools.VerifyError(err, raiseErr, t)
+	if err == nil && len(scanned) != len(shortUrls) {
+		t.Errorf("Expected %v shortened urls, got %v", len(shortUrls), len(scanned))
+	}
+
+	testtools.ExitTest(stubber, t)
+}
+
+func StubScanShortUrls(tableName string, shortUrls []string, raiseErr *testtools.StubError) testtools.Stub {
+	projection := expression.NamesList(expression.Name("ShortUrl"))
+	expr, _ := expression.NewBuilder().WithProjection(projection).Build()
+
+	var items []map[string]types.AttributeValue
+	for _, shortUrl := range shortUrls {
+		items = append(items, map[string]types.AttributeValue{
+			"ShortUrl": &types.AttributeValueMemberS{Value: shortUrl},
+		})
+	}
+
+	return testtools.Stub{
+		OperationName: "Scan",
+		Input: &dynamodb.ScanInput{
+			TableName:                aws.String(tableName),
+			ExpressionAttributeNames: expr.Names(),
+			ProjectionExpression:     expr.Projection(),
+		},
+		Output: &dynamodb.ScanOutput{Items: items},
+		Error:  raiseErr,
+	}
+}
+
+func TestTableClient_ScanUrls(t *testing.T) {
+	t.Run("NoErrors", func(t *testing.T) { ScanUrls(nil, t) })
+	t.Run("TestError", func(t *testing.T) { ScanUrls(&testtools.StubError{Err: errors.New("TestError")}, t) })
+}
+
+func ScanUrls(raiseErr *testtools.StubError, t *testing.T) {
+	ctx, stubber, client := enterTest()
+	client.LegacyTableName = constants.LegacyTableName
+
+	migrated := models.Url{Id: 12345, LongUrl: "https://www.youtube.com", ShortUrl: "NEDF34qw"}
+	legacy := models.Url{Id: 67890, LongUrl: "https://www.google.com", ShortUrl: "KWBG425d"}
+	migratedItem, _ := attributevalue.MarshalMap(migrated)
+	legacyItem, _ := attributevalue.MarshalMap(legacy)
+
+	stubber.Add(testtools.Stub{
+		OperationName: "Scan",
+		Input:         &dynamodb.ScanInput{TableName: aws.String(client.TableName)},
+		Output:        &dynamodb.ScanOutput{Items: []map[string]types.AttributeValue{migratedItem}},
+	})
+	stubber.Add(testtools.Stub{
+		OperationName: "Scan",
+		Input:         &dynamodb.ScanInput{TableName: aws.String(client.LegacyTableName)},
+		Output:        &dynamodb.ScanOutput{Items: []map[string]types.AttributeValue{migratedItem, legacyItem}},
+		Error:         raiseErr,
+	})
+
+	var scanned []models.Url
+	err := client.ScanUrls(ctx, func(url models.Url) error {
+		scanned = append(scanned, url)
+		return nil
+	})
+
+	testtools.VerifyError(err, raiseErr, t)
+	if err == nil && !reflect.DeepEqual(scanned, []models.Url{migrated, legacy}) {
+		t.Errorf("Expected the migrated entry once and the legacy entry, got %v", scanned)
+	}
+
+	testtools.ExitTest(stubber, t)
+}
+
+func TestTableClient_RetrieveApiKey(t *testing.T) {
+	t.Run("NoErrors", func(t *testing.T) { RetrieveApiKey(nil, t) })
+	t.Run("TestError", func(t *testing.T) { RetrieveApiKey(&testtools.StubError{Err: errors.New("TestError")}, t) })
+	t.Run("NoApiKeyRetrieved", func(t *testing.T) { RetrieveNoApiKey(t) })
+}
+
+func RetrieveApiKey(raiseErr *testtools.StubError, t *testing.T) {
+	ctx, stubber, client := enterTest()
+	client.ApiKeyTableName = constants.ApiKeyTableName
+
+	key := models.ApiKey{Id: "3f9c1a7e5b2d4c60", Owner: "alice", Scopes: []string{models.ScopeCreate}, SecretHash: "hash"}
+	stubber.Add(StubRetrieveApiKey(client.ApiKeyTableName, key.Id, &key, raiseErr))
+
+	retrieved, err := client.RetrieveApiKey(ctx, key.Id)
+
+	testtools.VerifyError(err, raiseErr, t)
+	if err == nil && !reflect.DeepEqual(key, retrieved) {
+		t.Errorf("Expected %v, got %v", key, retrieved)
+	}
+	testtools.ExitTest(stubber, t)
+}
+
+func RetrieveNoApiKey(t *testing.T) {
+	ctx, stubber, client := enterTest()
+	client.ApiKeyTableName = constants.ApiKeyTableName
+
+	stubber.Add(StubRetrieveApiKey(client.ApiKeyTableName, "3f9c1a7e5b2d4c60", nil, nil))
+
+	key, err := client.RetrieveApiKey(ctx, "3f9c1a7e5b2d4c60")
+
+	if err != nil || key.Id != "" {
+		t.Errorf("Expected no api key, got %v, %v", key, err)
+	}
+	testtools.ExitTest(stubber, t)
+}
+
+// StubRetrieveApiKey stubs the GetItem of RetrieveApiKey, answering with key or with no item when it is nil
+func StubRetrieveApiKey(tableName string, id string, key *models.ApiKey, raiseErr *testtools.StubError) testtools.Stub {
+	output := &dynamodb.GetItemOutput{}
+	if key != nil {
+		output.Item, _ = attributevalue.MarshalMap(key)
+	}
+
+	return testtools.Stub{
+		OperationName: "GetItem",
+		Input: &dynamodb.GetItemInput{
+			TableName:      aws.String(tableName),
+			Key:            map[string]types.AttributeValue{"Id": &types.AttributeValueMemberS{Value: id}},
+			ConsistentRead: aws.Bool(true),
+		},
+		Output: output,
+		Error:  raiseErr,
+	}
+}
+
+func TestTableClient_RevokeApiKey(t *testing.T) {
+	t.Run("NoErrors", func(t *testing.T) { RevokeApiKey(nil, t) })
+	t.Run("TestError", func(t *testing.T) { RevokeApiKey(&testtools.StubError{Err: errors.New("TestError")}, t) })
+	t.Run("NotFound", func(t *testing.T) {
+		RevokeApiKey(&testtools.StubError{Err: &types.ConditionalCheckFailedException{}, ContinueAfter: true}, t)
+	})
+}
+
+func RevokeApiKey(raiseErr *testtools.StubError, t *testing.T) {
+	ctx, stubber, client := enterTest()
+	client.ApiKeyTableName = constants.ApiKeyTableName
+	revokedAt := time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	cond := expression.AttributeExists(expression.Name("Id")).
+		And(expression.AttributeNotExists(expression.Name("RevokedAt")))
+	changes := expression.Set(expression.Name("RevokedAt"), expression.Value(revokedAt.Unix()))
+	expr, _ := expression.NewBuilder().WithCondition(cond).WithUpdate(changes).Build()
+	stubber.Add(testtools.Stub{
+		OperationName: "UpdateItem",
+		Input: &dynamodb.UpdateItemInput{
+			TableName:                 aws.String(client.ApiKeyTableName),
+			Key:                       map[string]types.AttributeValue{"Id": &types.AttributeValueMemberS{Value: "3f9c1a7e5b2d4c60"}},
+			ConditionExpression:       expr.Condition(),
+			UpdateExpression:          expr.Update(),
+			ExpressionAttributeNames:  expr.Names(),
+			ExpressionAttributeValues: expr.Values(),
+		},
+		Output: &dynamodb.UpdateItemOutput{},
+		Error:  raiseErr,
+	})
+
+	err := client.RevokeApiKey(ctx, "3f9c1a7e5b2d4c60", revokedAt)
+
+	if raiseErr != nil && raiseErr.ContinueAfter {
+		if !errors.Is(err, ErrApiKeyNotFound) {
+			t.Errorf("Expected ErrApiKeyNotFound, got %v", err)
+		}
+	} else {
+		testtools.VerifyError(err, raiseErr, t)
+	}
+	testtools.ExitTest(stubber, t)
+}
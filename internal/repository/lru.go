@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// LruCache is a concurrency-safe cache holding at most size entries, each of them for at most ttl.
+// The least recently used entry is evicted when the cache is full
+type LruCache[V any] struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	entries map[string]*list.Element
+	order   *list.List
+	now     func() time.Time
+}
+
+type lruEntry[V any] struct {
+	key       string
+	value     V
+	expiresAt time.Time
+}
+
+// NewLruCache creates an LruCache of size entries kept for ttl, which caches nothing when size is not positive
+func NewLruCache[V any](size int, ttl time.Duration) *LruCache[V] {
+	return &LruCache[V]{
+		size:    size,
+		ttl:     ttl,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+		now:     time.Now,
+	}
+}
+
+// Get returns the value cached for key, if there is one that has not expired
+func (cache *LruCache[V]) Get(key string) (V, bool) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	var zero V
+	element, ok := cache.entries[key]
+	if !ok {
+		return zero, false
+	}
+
+	entry := element.Value.(*lruEntry[V])
+	if cache.now().After(entry.expiresAt) {
+		cache.order.Remove(element)
+		delete(cache.entries, key)
+		return zero, false
+	}
+
+	cache.order.MoveToFront(element)
+	return entry.value, true
+}
+
+// Set caches value for key, evicting the least recently used entry when the cache is full
+func (cache *LruCache[V]) Set(key string, value V) {
+	if cache.size <= 0 {
+		return
+	}
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	expiresAt := cache.now().Add(cache.ttl)
+	if element, ok := cache.entries[key]; ok {
+		entry := element.Value.(*lruEntry[V])
+		entry.value = value
+		entry.expiresAt = expiresAt
+		cache.order.MoveToFront(element)
+		return
+	}
+
+	cache.entries[key] = cache.order.PushFront(&lruEntry[V]{key: key, value: value, expiresAt: expiresAt})
+	if cache.order.Len() > cache.size {
+		oldest := cache.order.Back()
+		cache.order.Remove(oldest)
+		delete(cache.entries, oldest.Value.(*lruEntry[V]).key)
+	}
+}
+
+// Remove drops the entry cached for key, if any
+func (cache *LruCache[V]) Remove(key string) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if element, ok := cache.entries[key]; ok {
+		cache.order.Remove(element)
+		delete(cache.entries, key)
+	}
+}
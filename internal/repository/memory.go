@@ -0,0 +1,306 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kjj1998/url-shortener-go/internal/models"
+)
+
+// MemoryClient is an in-memory UrlRepository for local development and tests.
+// Entries are keyed by ShortUrl like the DynamoDB table, with a secondary index on Id
+// so Ids stay unique like in the SQL backends, and one on the owner and Destination. API keys are keyed by Id
+type MemoryClient struct {
+	mu           sync.RWMutex
+	urls         map[string]models.Url
+	ids          map[uint64]string
+	destinations map[destinationKey]map[string]struct{}
+	apiKeys      map[string]models.ApiKey
+}
+
+// destinationKey is the key of the shortened URLs of an owner for a destination
+type destinationKey struct {
+	owner       string
+	destination string
+}
+
+// NewMemoryClient creates an empty MemoryClient
+func NewMemoryClient() *MemoryClient {
+	return &MemoryClient{
+		urls:         make(map[string]models.Url),
+		ids:          make(map[uint64]string),
+		destinations: make(map[destinationKey]map[string]struct{}),
+		apiKeys:      make(map[string]models.ApiKey),
+	}
+}
+
+// store sets url as the entry of its shortened URL and indexes it, the caller holds the write lock
+func (client *MemoryClient) store(url models.Url) {
+	if replaced, ok := client.urls[url.ShortUrl]; ok {
+		delete(client.ids, replaced.Id)
+		client.unindexDestination(replaced)
+	}
+	client.urls[url.ShortUrl] = url
+	client.ids[url.Id] = url.ShortUrl
+	if url.Destination != "" {
+		key := destinationKey{url.Owner, url.Destination}
+		if client.destinations[key] == nil {
+			client.destinations[key] = make(map[string]struct{})
+		}
+		client.destinations[key][url.ShortUrl] = struct{}{}
+	}
+}
+
+func (client *MemoryClient) unindexDestination(url models.Url) {
+	key := destinationKey{url.Owner, url.Destination}
+	delete(client.destinations[key], url.ShortUrl)
+	if len(client.destinations[key]) == 0 {
+		delete(client.destinations, key)
+	}
+}
+
+func newMemoryClient(env string) (UrlRepository, error) {
+	return NewMemoryClient(), nil
+}
+
+// AddUrl adds a URL and its shortened form as an entry into the store
+// Returns ErrShortUrlTaken when an entry already holds the shortened URL or the Id
+func (client *MemoryClient) AddUrl(ctx context.Context, url models.Url) error {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	if _, ok := client.urls[url.ShortUrl]; ok {
+		return ErrShortUrlTaken
+	}
+	if _, ok := client.ids[url.Id]; ok {
+		return ErrShortUrlTaken
+	}
+	client.store(url)
+
+	return nil
+}
+
+// AddUrls adds each URL like AddUrl
+func (client *MemoryClient) AddUrls(ctx context.Context, urls []models.Url) []error {
+	errs := make([]error, len(urls))
+	for i, url := range urls {
+		errs[i] = client.AddUrl(ctx, url)
+	}
+	return errs
+}
+
+// PutUrl stores url as the entry of its shortened URL, replacing the entry already there
+// Returns ErrShortUrlTaken when the Id is held by the entry of another shortened URL
+func (client *MemoryClient) PutUrl(ctx context.Context, url models.Url) error {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	if shortUrl, ok := client.ids[url.Id]; ok && shortUrl != url.ShortUrl {
+		return ErrShortUrlTaken
+	}
+	client.store(url)
+
+	return nil
+}
+
+// RetrieveUrl looks up the entry of a shortened URL
+// Returns a zero Url when no entry exists for the shortened URL
+func (client *MemoryClient) RetrieveUrl(ctx context.Context, shortUrl string) (models.Url, error) {
+	client.mu.RLock()
+	defer client.mu.RUnlock()
+
+	return client.urls[shortUrl], nil
+}
+
+// RetrieveReusableUrl looks up the entries of owner for destination in the index, returning the reusable one with the
+// lowest shortened URL
+func (client *MemoryClient) RetrieveReusableUrl(ctx context.Context, owner string, destination string) (models.Url, error) {
+	client.mu.RLock()
+	defer client.mu.RUnlock()
+
+	var reusable models.Url
+	for shortUrl := range client.destinations[destinationKey{owner, destination}] {
+		url := client.urls[shortUrl]
+		if url.Reusable() && (reusable.ShortUrl == "" || url.ShortUrl < reusable.ShortUrl) {
+			reusable = url
+		}
+	}
+	return reusable, nil
+}
+
+// UpdateUrl applies update to the entry of a shortened URL
+// Returns ErrUrlNotFound when no entry exists for the shortened URL or it has been deleted
+func (client *MemoryClient) UpdateUrl(ctx context.Context, shortUrl string, update models.UrlUpdate) (models.Url, error) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	url, ok := client.urls[shortUrl]
+	if !ok || url.Deleted() {
+		return models.Url{}, ErrUrlNotFound
+	}
+	url = update.Apply(url)
+	client.store(url)
+
+	return url, nil
+}
+
+// DeleteUrl marks the entry of a shortened URL as deleted, keeping it as a tombstone
+// Returns ErrUrlNotFound when no entry exists for the shortened URL or it has already been deleted
+func (client *MemoryClient) DeleteUrl(ctx context.Context, shortUrl string) error {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	url, ok := client.urls[shortUrl]
+	if !ok || url.Deleted() {
+		return ErrUrlNotFound
+	}
+	deletedAt := time.Now().UTC().Truncate(time.Second)
+	url.DeletedAt = &deletedAt
+	client.urls[shortUrl] = url
+
+	return nil
+}
+
+// ListUrls returns the entries that match filter in the order of their shortened URLs
+func (client *MemoryClient) ListUrls(ctx context.Context, filter models.UrlFilter, cursor string, limit int) (models.UrlPage, error) {
+	after, err := decodeCursor(cursor)
+	if err != nil {
+		return models.UrlPage{}, err
+	}
+
+	client.mu.RLock()
+	matches := make([]models.Url, 0)
+	for shortUrl, url := range client.urls {
+		if shortUrl > after && filter.Matches(url) {
+			matches = append(matches, url)
+		}
+	}
+	client.mu.RUnlock()
+
+	sort.Slice(matches, func(i, j int) bool { return matches[i].ShortUrl < matches[j].ShortUrl })
+	page := models.UrlPage{Links: matches}
+	if len(matches) > limit {
+		page.Links = matches[:limit]
+		page.NextCursor = encodeCursor(page.Links[limit-1].ShortUrl)
+	}
+	return page, nil
+}
+
+// MergeTags merges the tags of every entry that is not deleted under the write lock
+func (client *MemoryClient) MergeTags(ctx context.Context, merge models.TagMerge) (int, error) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	merged := 0
+	for shortUrl, url := range client.urls {
+		tags, ok := merge.Merged(url.Tags)
+		if !ok || url.Deleted() || (merge.Owner != "" && url.Owner != merge.Owner) {
+			continue
+		}
+		url.Tags = tags
+		client.urls[shortUrl] = url
+		merged++
+	}
+	return merged, nil
+}
+
+// RecordClick counts a redirect of a shortened URL under the write lock, so concurrent clicks never exceed MaxClicks
+func (client *MemoryClient) RecordClick(ctx context.Context, shortUrl string) error {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	url, ok := client.urls[shortUrl]
+	if !ok || url.Clicks >= url.MaxClicks {
+		return ErrClicksExhausted
+	}
+	url.Clicks++
+	client.urls[shortUrl] = url
+
+	return nil
+}
+
+// ScanShortUrls calls fn with every shortened URL in the store, stopping at the first error
+func (client *MemoryClient) ScanShortUrls(ctx context.Context, fn func(shortUrl string) error) error {
+	client.mu.RLock()
+	shortUrls := make([]string, 0, len(client.urls))
+	for shortUrl := range client.urls {
+		shortUrls = append(shortUrls, shortUrl)
+	}
+	client.mu.RUnlock()
+
+	for _, shortUrl := range shortUrls {
+		if err := fn(shortUrl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ScanUrls calls fn with every entry in the store in the order of their shortened URLs, stopping at the first error
+func (client *MemoryClient) ScanUrls(ctx context.Context, fn func(url models.Url) error) error {
+	client.mu.RLock()
+	urls := make([]models.Url, 0, len(client.urls))
+	for _, url := range client.urls {
+		urls = append(urls, url)
+	}
+	client.mu.RUnlock()
+
+	sort.Slice(urls, func(i, j int) bool { return urls[i].ShortUrl < urls[j].ShortUrl })
+	for _, url := range urls {
+		if err := fn(url); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddApiKey adds an API key to the store, replacing the key already stored under its Id
+func (client *MemoryClient) AddApiKey(ctx context.Context, key models.ApiKey) error {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	client.apiKeys[key.Id] = key
+	return nil
+}
+
+// RetrieveApiKey looks up the API key stored under id
+func (client *MemoryClient) RetrieveApiKey(ctx context.Context, id string) (models.ApiKey, error) {
+	client.mu.RLock()
+	defer client.mu.RUnlock()
+
+	return client.apiKeys[id], nil
+}
+
+// ListApiKeys returns the API keys of owner, or every API key when it is empty, in the order of their ids
+func (client *MemoryClient) ListApiKeys(ctx context.Context, owner string) ([]models.ApiKey, error) {
+	client.mu.RLock()
+	keys := make([]models.ApiKey, 0)
+	for _, key := range client.apiKeys {
+		if owner == "" || key.Owner == owner {
+			keys = append(keys, key)
+		}
+	}
+	client.mu.RUnlock()
+
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Id < keys[j].Id })
+	return keys, nil
+}
+
+// RevokeApiKey marks the API key stored under id as revoked under the write lock
+// Returns ErrApiKeyNotFound when no API key is stored under id or it has already been revoked
+func (client *MemoryClient) RevokeApiKey(ctx context.Context, id string, revokedAt time.Time) error {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	key, ok := client.apiKeys[id]
+	if !ok || key.Revoked() {
+		return ErrApiKeyNotFound
+	}
+	revokedAt = revokedAt.UTC().Truncate(time.Second)
+	key.RevokedAt = &revokedAt
+	client.apiKeys[id] = key
+
+	return nil
+}
@@ -0,0 +1,182 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kjj1998/url-shortener-go/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryClient_AddUrl(t *testing.T) {
+	ctx := context.Background()
+	client := NewMemoryClient()
+
+	url := models.Url{Id: 12345, LongUrl: "https://www.youtube.com", ShortUrl: "NEDF34qw"}
+	assert.NoError(t, client.AddUrl(ctx, url))
+
+	retrieved, err := client.RetrieveUrl(ctx, url.ShortUrl)
+	assert.NoError(t, err)
+	assert.Equal(t, url, retrieved)
+}
+
+func TestMemoryClient_AddTakenUrl(t *testing.T) {
+	ctx := context.Background()
+	client := NewMemoryClient()
+
+	assert.NoError(t, client.AddUrl(ctx, models.Url{Id: 1, LongUrl: "https://www.youtube.com", ShortUrl: "NEDF34qw"}))
+	assert.ErrorIs(t, client.AddUrl(ctx, models.Url{Id: 2, LongUrl: "https://www.google.com", ShortUrl: "NEDF34qw"}), ErrShortUrlTaken)
+	assert.ErrorIs(t, client.AddUrl(ctx, models.Url{Id: 1, LongUrl: "https://www.google.com", ShortUrl: "KWBG425d"}), ErrShortUrlTaken)
+
+	url, err := client.RetrieveUrl(ctx, "NEDF34qw")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://www.youtube.com", url.LongUrl)
+}
+
+func TestMemoryClient_AddUrls(t *testing.T) {
+	testAddUrls(t, context.Background(), NewMemoryClient())
+}
+
+func TestMemoryClient_PutUrl(t *testing.T) {
+	testPutUrl(t, context.Background(), NewMemoryClient())
+}
+
+func TestMemoryClient_RetrieveReusableUrl(t *testing.T) {
+	testRetrieveReusableUrl(t, context.Background(), NewMemoryClient())
+}
+
+func TestMemoryClient_RetrieveNoUrl(t *testing.T) {
+	client := NewMemoryClient()
+
+	url, err := client.RetrieveUrl(context.Background(), "NEWDSa31")
+	assert.NoError(t, err)
+	assert.Empty(t, url)
+}
+
+func TestMemoryClient_Concurrency(t *testing.T) {
+	ctx := context.Background()
+	client := NewMemoryClient()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(id uint64) {
+			defer wg.Done()
+			shortUrl := fmt.Sprintf("code%d", id)
+			assert.NoError(t, client.AddUrl(ctx, models.Url{Id: id, LongUrl: "https://www.youtube.com", ShortUrl: shortUrl}))
+			_, err := client.RetrieveUrl(ctx, shortUrl)
+			assert.NoError(t, err)
+		}(uint64(i))
+	}
+	wg.Wait()
+
+	for i := 0; i < 50; i++ {
+		url, err := client.RetrieveUrl(ctx, fmt.Sprintf("code%d", i))
+		assert.NoError(t, err)
+		assert.Equal(t, "https://www.youtube.com", url.LongUrl)
+	}
+}
+
+func TestMemoryClient_UpdateUrl(t *testing.T) {
+	ctx := context.Background()
+	client := NewMemoryClient()
+	assert.NoError(t, client.AddUrl(ctx, models.Url{Id: 1, LongUrl: "https://www.youtube.com", ShortUrl: "NEDF34qw", MaxClicks: 5}))
+
+	longUrl := "https://www.google.com"
+	maxClicks := int64(0)
+	url, err := client.UpdateUrl(ctx, "NEDF34qw", models.UrlUpdate{LongUrl: &longUrl, MaxClicks: &maxClicks})
+	assert.NoError(t, err)
+	assert.Equal(t, models.Url{Id: 1, LongUrl: longUrl, ShortUrl: "NEDF34qw"}, url)
+
+	expiresAt := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	url, err = client.UpdateUrl(ctx, "NEDF34qw", models.UrlUpdate{ExpiresAt: &expiresAt})
+	assert.NoError(t, err)
+	assert.Equal(t, &expiresAt, url.ExpiresAt)
+	url, err = client.UpdateUrl(ctx, "NEDF34qw", models.UrlUpdate{RemoveExpiry: true})
+	assert.NoError(t, err)
+	assert.Nil(t, url.ExpiresAt)
+
+	_, err = client.UpdateUrl(ctx, "NEWDSa31", models.UrlUpdate{LongUrl: &longUrl})
+	assert.ErrorIs(t, err, ErrUrlNotFound)
+}
+
+func TestMemoryClient_DeleteUrl(t *testing.T) {
+	ctx := context.Background()
+	client := NewMemoryClient()
+	assert.NoError(t, client.AddUrl(ctx, models.Url{Id: 1, LongUrl: "https://www.youtube.com", ShortUrl: "NEDF34qw"}))
+
+	assert.NoError(t, client.DeleteUrl(ctx, "NEDF34qw"))
+	assert.ErrorIs(t, client.DeleteUrl(ctx, "NEDF34qw"), ErrUrlNotFound)
+	assert.ErrorIs(t, client.DeleteUrl(ctx, "NEWDSa31"), ErrUrlNotFound)
+
+	url, err := client.RetrieveUrl(ctx, "NEDF34qw")
+	assert.NoError(t, err)
+	assert.True(t, url.Deleted())
+
+	longUrl := "https://www.google.com"
+	_, err = client.UpdateUrl(ctx, "NEDF34qw", models.UrlUpdate{LongUrl: &longUrl})
+	assert.ErrorIs(t, err, ErrUrlNotFound)
+	assert.ErrorIs(t, client.AddUrl(ctx, models.Url{Id: 2, LongUrl: longUrl, ShortUrl: "NEDF34qw"}), ErrShortUrlTaken)
+}
+
+func TestMemoryClient_ListUrls(t *testing.T) {
+	testListUrls(t, context.Background(), NewMemoryClient())
+}
+
+func TestMemoryClient_MergeTags(t *testing.T) {
+	testMergeTags(t, context.Background(), NewMemoryClient())
+}
+
+func TestMemoryClient_RecordClick(t *testing.T) {
+	ctx := context.Background()
+	client := NewMemoryClient()
+	assert.NoError(t, client.AddUrl(ctx, models.Url{Id: 1, LongUrl: "https://www.youtube.com", ShortUrl: "NEDF34qw", MaxClicks: 5}))
+
+	var served atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := client.RecordClick(ctx, "NEDF34qw")
+			if err == nil {
+				served.Add(1)
+			} else {
+				assert.ErrorIs(t, err, ErrClicksExhausted)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(5), served.Load())
+	url, _ := client.RetrieveUrl(ctx, "NEDF34qw")
+	assert.Equal(t, int64(5), url.Clicks)
+}
+
+func TestMemoryClient_ScanShortUrls(t *testing.T) {
+	ctx := context.Background()
+	client := NewMemoryClient()
+	assert.NoError(t, client.AddUrl(ctx, models.Url{Id: 1, LongUrl: "https://www.youtube.com", ShortUrl: "NEDF34qw"}))
+	assert.NoError(t, client.AddUrl(ctx, models.Url{Id: 2, LongUrl: "https://www.google.com", ShortUrl: "KWBG425d"}))
+
+	var shortUrls []string
+	err := client.ScanShortUrls(ctx, func(shortUrl string) error {
+		shortUrls = append(shortUrls, shortUrl)
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"NEDF34qw", "KWBG425d"}, shortUrls)
+}
+
+func TestMemoryClient_ScanUrls(t *testing.T) {
+	testScanUrls(t, context.Background(), NewMemoryClient())
+}
+
+func TestMemoryClient_ApiKeys(t *testing.T) {
+	testApiKeys(t, context.Background(), NewMemoryClient())
+}
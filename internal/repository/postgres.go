@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/kjj1998/url-shortener-go/constants"
+)
+
+// pgUniqueViolation is the SQLSTATE raised by PostgreSQL when a unique constraint is violated
+const pgUniqueViolation = "23505"
+
+// pgMigrationLock is the key of the advisory lock held while the schema is migrated
+const pgMigrationLock = 7261548039
+
+var postgresDialect = sqlDialect{
+	placeholder: dollarPlaceholder,
+	isUniqueViolation: func(err error) bool {
+		var pgErr *pgconn.PgError
+		return errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation
+	},
+	contains: func(column string) string {
+		return "strpos(" + column + ", ?) > 0"
+	},
+	lockMigrations:   fmt.Sprintf(`SELECT pg_advisory_lock(%d)`, pgMigrationLock),
+	unlockMigrations: fmt.Sprintf(`SELECT pg_advisory_unlock(%d)`, pgMigrationLock),
+}
+
+// NewPostgresClient opens a pool of connections to the PostgreSQL database at dsn
+// and migrates its schema to the latest version
+func NewPostgresClient(dsn string) (SqlClient, error) {
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return SqlClient{}, err
+	}
+	db.SetMaxOpenConns(constants.PostgresMaxOpenConns)
+	db.SetMaxIdleConns(constants.PostgresMaxIdleConns)
+	db.SetConnMaxLifetime(constants.PostgresConnMaxLifetime)
+
+	if err := db.PingContext(context.TODO()); err != nil {
+		db.Close()
+		return SqlClient{}, err
+	}
+
+	client, err := newSqlClient(context.TODO(), db, postgresDialect)
+	if err != nil {
+		db.Close()
+		return SqlClient{}, err
+	}
+
+	return client, nil
+}
+
+func newPostgresClient(env string) (UrlRepository, error) {
+	dsn, exists := os.LookupEnv("POSTGRES_DSN")
+	if !exists {
+		return nil, fmt.Errorf("no input for environment variable POSTGRES_DSN detected")
+	}
+
+	return NewPostgresClient(dsn)
+}
@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"time"
+
+	"github.com/kjj1998/url-shortener-go/constants"
+	"github.com/kjj1998/url-shortener-go/internal/config"
+	"github.com/kjj1998/url-shortener-go/internal/models"
+)
+
+// ErrShortUrlTaken is returned when a shortened URL is already held by another entry of the store
+var ErrShortUrlTaken = errors.New("shortened url is already taken")
+
+// ErrUrlNotFound is returned when there is no entry, or only a tombstone, for the shortened URL to change
+var ErrUrlNotFound = errors.New("shortened url not found")
+
+// ErrClicksExhausted is returned when a click-limited shortened URL has served all of its redirects
+var ErrClicksExhausted = errors.New("shortened url has no clicks left")
+
+// ErrApiKeyNotFound is returned when there is no API key, or only a revoked one, to revoke
+var ErrApiKeyNotFound = errors.New("api key not found")
+
+// UrlRepository is the storage abstraction the route handlers depend on.
+// Every storage backend provides its own implementation of it
+type UrlRepository interface {
+	// AddUrl adds a URL and its shortened form to the store
+	AddUrl(ctx context.Context, url models.Url) error
+	// AddUrls adds many URLs whose shortened URLs were generated from unique ids, returning the error of adding
+	// each of them in the order of urls. Like AddUrl, ErrShortUrlTaken is returned for those whose shortened URL is taken
+	AddUrls(ctx context.Context, urls []models.Url) []error
+	// PutUrl stores url as the entry of its shortened URL, replacing the entry already there
+	// Returns ErrShortUrlTaken when the Id is held by the entry of another shortened URL, in the stores keeping Ids unique
+	PutUrl(ctx context.Context, url models.Url) error
+	// RetrieveUrl returns the entry stored for a shortened URL, or a zero Url when there is none.
+	// Expired entries are returned until the store deletes them, it is up to the caller to check Url.Expired
+	RetrieveUrl(ctx context.Context, shortUrl string) (models.Url, error)
+	// RetrieveReusableUrl returns an entry of owner for destination that is Url.Reusable, or a zero Url when there
+	// is none. It is looked up through an index on Url.Destination, so entries stored without one are not found
+	RetrieveReusableUrl(ctx context.Context, owner string, destination string) (models.Url, error)
+	// UpdateUrl applies update to the entry of a shortened URL, returning the updated entry
+	UpdateUrl(ctx context.Context, shortUrl string, update models.UrlUpdate) (models.Url, error)
+	// DeleteUrl replaces the entry of a shortened URL with a tombstone, so the shortened URL is never reissued
+	DeleteUrl(ctx context.Context, shortUrl string) error
+	// ListUrls returns up to limit shortened URLs that match filter and are not deleted, resuming after the
+	// page that returned cursor. It returns ErrCursorInvalid for a cursor it did not hand out
+	ListUrls(ctx context.Context, filter models.UrlFilter, cursor string, limit int) (models.UrlPage, error)
+	// MergeTags replaces the tags merged by merge with its target tag on every shortened URL that is not deleted,
+	// returning the number of shortened URLs changed
+	MergeTags(ctx context.Context, merge models.TagMerge) (int, error)
+	// RecordClick atomically counts a redirect of a shortened URL with a MaxClicks, returning
+	// ErrClicksExhausted instead when it has already served MaxClicks redirects
+	RecordClick(ctx context.Context, shortUrl string) error
+	// ScanShortUrls calls fn with every shortened URL in the store, stopping at the first error
+	ScanShortUrls(ctx context.Context, fn func(shortUrl string) error) error
+	// ScanUrls calls fn with every entry in the store, tombstones included, stopping at the first error
+	ScanUrls(ctx context.Context, fn func(url models.Url) error) error
+}
+
+// ApiKeyRepository stores the API keys authenticating requests, next to the shortened URLs.
+// Every storage backend provides its own implementation of it
+type ApiKeyRepository interface {
+	// AddApiKey adds an API key to the store
+	AddApiKey(ctx context.Context, key models.ApiKey) error
+	// RetrieveApiKey returns the API key stored under id, revoked ones included, or a zero ApiKey when there is none
+	RetrieveApiKey(ctx context.Context, id string) (models.ApiKey, error)
+	// ListApiKeys returns the API keys of owner, or of every owner when it is empty, in the order of their ids
+	ListApiKeys(ctx context.Context, owner string) ([]models.ApiKey, error)
+	// RevokeApiKey marks the API key stored under id as revoked at revokedAt
+	// Returns ErrApiKeyNotFound when there is no such key or it has already been revoked
+	RevokeApiKey(ctx context.Context, id string, revokedAt time.Time) error
+}
+
+// backendFactory creates the UrlRepository of a storage backend for the given environment
+type backendFactory func(env string) (UrlRepository, error)
+
+// backends maps the values accepted by the STORAGE_BACKEND environment variable to their factories
+var backends = map[string]backendFactory{
+	constants.DynamoDbBackend: newTableClient,
+	constants.MemoryBackend:   newMemoryClient,
+	constants.SqliteBackend:   newSqliteClient,
+	constants.PostgresBackend: newPostgresClient,
+}
+
+var Client UrlRepository
+
+// ApiKeys is the ApiKeyRepository of the storage backend of Client
+var ApiKeys ApiKeyRepository
+
+func init() {
+	env, exists := os.LookupEnv("ENVIRONMENT")
+	if !exists {
+		log.Fatalf("No input for environment variable ENVIRONMENT detected")
+	}
+
+	backend, exists := os.LookupEnv("STORAGE_BACKEND")
+	if !exists && env == "LOCAL" {
+		backend = constants.MemoryBackend
+	} else if !exists {
+		backend = constants.DynamoDbBackend
+	}
+
+	newClient, ok := backends[backend]
+	if !ok {
+		log.Fatalf("%v storage backend is not recognised", backend)
+	}
+
+	client, err := newClient(env)
+	if err != nil {
+		log.Fatalf("Unable to create %v storage backend, %v", backend, err)
+	}
+
+	apiKeys, ok := client.(ApiKeyRepository)
+	if !ok {
+		log.Fatalf("%v storage backend does not store api keys", backend)
+	}
+	ApiKeys = apiKeys
+	Client = newCachedClient(client)
+}
+
+// newCachedClient wraps client with the caches configured by the environment, unless they are all disabled
+func newCachedClient(client UrlRepository) UrlRepository {
+	options := CacheOptions{
+		Size:         config.Int("CACHE_SIZE", constants.CacheSize),
+		Ttl:          config.Duration("CACHE_TTL", constants.CacheTtl),
+		NegativeSize: config.Int("NEGATIVE_CACHE_SIZE", constants.NegativeCacheSize),
+		NegativeTtl:  config.Duration("NEGATIVE_CACHE_TTL", constants.NegativeCacheTtl),
+	}
+	bloomFilterCapacity := config.Int("BLOOM_FILTER_CAPACITY", 0)
+
+	if options.Size <= 0 && options.NegativeSize <= 0 && bloomFilterCapacity <= 0 {
+		return client
+	}
+	cachedClient := NewCachedClient(client, options)
+
+	if bloomFilterCapacity > 0 {
+		if err := cachedClient.RebuildBloomFilter(context.TODO(), bloomFilterCapacity); err != nil {
+			log.Fatalf("Unable to build bloom filter, %v", err)
+		}
+		if interval := config.Duration("BLOOM_FILTER_REFRESH", constants.BloomFilterRefresh); interval > 0 {
+			go cachedClient.RefreshBloomFilter(context.Background(), bloomFilterCapacity, interval)
+		}
+	}
+
+	return cachedClient
+}
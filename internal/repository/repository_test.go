@@ -0,0 +1,270 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kjj1998/url-shortener-go/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// addListedUrls adds the entries ListUrls is tested against, one of them deleted
+func addListedUrls(t *testing.T, ctx context.Context, client UrlRepository) {
+	day := func(d int) *time.Time {
+		createdAt := time.Date(2025, 1, d, 0, 0, 0, 0, time.UTC)
+		return &createdAt
+	}
+
+	urls := []models.Url{
+		{Id: 1, ShortUrl: "aaa", LongUrl: "https://www.youtube.com/watch", Domain: "www.youtube.com", Owner: "alice", CreatedAt: day(1), Tags: []string{"go", "news"}},
+		{Id: 2, ShortUrl: "bbb", LongUrl: "https://www.google.com/search", Domain: "www.google.com", Owner: "alice", CreatedAt: day(2), Tags: []string{"golang"}},
+		{Id: 3, ShortUrl: "ccc", LongUrl: "https://www.youtube.com/shorts", Domain: "www.youtube.com", Owner: "bob", CreatedAt: day(3), Tags: []string{"go-lang", "golang", "news"}},
+		{Id: 4, ShortUrl: "ddd", LongUrl: "https://example.com", Domain: "example.com", Owner: "bob", CreatedAt: day(4), Title: "Example"},
+		{Id: 5, ShortUrl: "eee", LongUrl: "https://www.youtube.com", Domain: "www.youtube.com", Owner: "alice", CreatedAt: day(5), Tags: []string{"golang"}},
+		{Id: 6, ShortUrl: "legacy", LongUrl: "https://example.org"},
+	}
+	for _, url := range urls {
+		if err := client.AddUrl(ctx, url); err != nil {
+			t.Fatalf("Couldn't add url: %v", err)
+		}
+	}
+	if err := client.DeleteUrl(ctx, "eee"); err != nil {
+		t.Fatalf("Couldn't delete url: %v", err)
+	}
+}
+
+// listShortUrls pages through ListUrls with limit, returning the shortened URLs listed
+func listShortUrls(t *testing.T, ctx context.Context, client UrlRepository, filter models.UrlFilter, limit int) []string {
+	var shortUrls []string
+	cursor := ""
+	for pages := 0; pages < 10; pages++ {
+		page, err := client.ListUrls(ctx, filter, cursor, limit)
+		if !assert.NoError(t, err) {
+			return shortUrls
+		}
+		assert.LessOrEqual(t, len(page.Links), limit)
+		for _, url := range page.Links {
+			shortUrls = append(shortUrls, url.ShortUrl)
+		}
+		if page.NextCursor == "" {
+			return shortUrls
+		}
+		cursor = page.NextCursor
+	}
+	t.Fatalf("ListUrls did not reach the last page")
+	return nil
+}
+
+// testListUrls runs the ListUrls tests shared by the backends that list in the order of the shortened URLs
+func testListUrls(t *testing.T, ctx context.Context, client UrlRepository) {
+	addListedUrls(t, ctx, client)
+	createdAfter := time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)
+	createdBefore := time.Date(2025, 1, 4, 0, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, []string{"aaa", "bbb", "ccc", "ddd", "legacy"}, listShortUrls(t, ctx, client, models.UrlFilter{}, 2))
+	assert.Equal(t, []string{"aaa", "bbb", "ccc", "ddd", "legacy"}, listShortUrls(t, ctx, client, models.UrlFilter{}, 5))
+	assert.Equal(t, []string{"bbb", "ccc"}, listShortUrls(t, ctx, client, models.UrlFilter{CreatedAfter: &createdAfter, CreatedBefore: &createdBefore}, 1))
+	assert.Equal(t, []string{"aaa", "ccc"}, listShortUrls(t, ctx, client, models.UrlFilter{Domain: "WWW.youtube.com"}, 2))
+	assert.Equal(t, []string{"aaa", "bbb"}, listShortUrls(t, ctx, client, models.UrlFilter{Owner: "alice"}, 2))
+	assert.Equal(t, []string{"aaa", "ccc"}, listShortUrls(t, ctx, client, models.UrlFilter{Search: "youtube"}, 2))
+	assert.Equal(t, []string{"legacy"}, listShortUrls(t, ctx, client, models.UrlFilter{Search: "leg"}, 2))
+	assert.Empty(t, listShortUrls(t, ctx, client, models.UrlFilter{Search: "YOUTUBE"}, 2))
+	assert.Equal(t, []string{"aaa", "ccc"}, listShortUrls(t, ctx, client, models.UrlFilter{Tag: "NEWS"}, 2))
+	assert.Equal(t, []string{"ccc"}, listShortUrls(t, ctx, client, models.UrlFilter{Tag: "go-lang"}, 2))
+
+	_, err := client.ListUrls(ctx, models.UrlFilter{}, "bm90IGpzb24", 2)
+	assert.ErrorIs(t, err, ErrCursorInvalid)
+}
+
+// testMergeTags runs the MergeTags tests shared by the backends
+func testMergeTags(t *testing.T, ctx context.Context, client UrlRepository) {
+	addListedUrls(t, ctx, client)
+
+	merged, err := client.MergeTags(ctx, models.TagMerge{From: []string{"golang", "Go-Lang"}, To: "go"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, merged, "the deleted url is not merged")
+
+	expected := map[string][]string{
+		"aaa": {"go", "news"},
+		"bbb": {"go"},
+		"ccc": {"go", "news"},
+		"ddd": nil,
+		"eee": {"golang"},
+	}
+	for shortUrl, tags := range expected {
+		url, err := client.RetrieveUrl(ctx, shortUrl)
+		assert.NoError(t, err)
+		assert.Equal(t, tags, url.Tags, shortUrl)
+	}
+
+	merged, err = client.MergeTags(ctx, models.TagMerge{From: []string{"news"}, To: "headlines"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, merged)
+	assert.Equal(t, []string{"aaa", "ccc"}, listShortUrls(t, ctx, client, models.UrlFilter{Tag: "headlines"}, 2))
+	assert.Empty(t, listShortUrls(t, ctx, client, models.UrlFilter{Tag: "news"}, 2))
+
+	merged, err = client.MergeTags(ctx, models.TagMerge{From: []string{"go"}, To: "golang", Owner: "bob"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, merged, "only the urls of the owner are merged")
+	assert.Equal(t, []string{"aaa", "bbb"}, listShortUrls(t, ctx, client, models.UrlFilter{Tag: "go"}, 2))
+}
+
+// testAddUrls runs the AddUrls tests shared by the backends that check the shortened URLs are not taken
+func testAddUrls(t *testing.T, ctx context.Context, client UrlRepository) {
+	assert.NoError(t, client.AddUrl(ctx, models.Url{Id: 1, LongUrl: "https://www.youtube.com", ShortUrl: "NEDF34qw"}))
+
+	errs := client.AddUrls(ctx, []models.Url{
+		{Id: 2, LongUrl: "https://www.google.com", ShortUrl: "KWBG425d"},
+		{Id: 3, LongUrl: "https://www.google.com", ShortUrl: "NEDF34qw"},
+		{Id: 1, LongUrl: "https://www.google.com", ShortUrl: "NEWDSa31"},
+		{Id: 4, LongUrl: "https://example.com", ShortUrl: "NWER425d", Tags: []string{"news"}},
+	})
+	assert.Len(t, errs, 4)
+	assert.NoError(t, errs[0])
+	assert.ErrorIs(t, errs[1], ErrShortUrlTaken)
+	assert.ErrorIs(t, errs[2], ErrShortUrlTaken)
+	assert.NoError(t, errs[3])
+
+	for shortUrl, longUrl := range map[string]string{
+		"NEDF34qw": "https://www.youtube.com",
+		"KWBG425d": "https://www.google.com",
+		"NEWDSa31": "",
+		"NWER425d": "https://example.com",
+	} {
+		url, err := client.RetrieveUrl(ctx, shortUrl)
+		assert.NoError(t, err)
+		assert.Equal(t, longUrl, url.LongUrl, shortUrl)
+	}
+}
+
+// testPutUrl runs the PutUrl tests shared by the backends
+func testPutUrl(t *testing.T, ctx context.Context, client UrlRepository) {
+	deletedAt := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.NoError(t, client.AddUrl(ctx, models.Url{Id: 1, LongUrl: "https://www.youtube.com", ShortUrl: "NEDF34qw"}))
+	assert.NoError(t, client.AddUrl(ctx, models.Url{Id: 2, LongUrl: "https://www.google.com", ShortUrl: "KWBG425d"}))
+
+	replaced := models.Url{Id: 3, LongUrl: "https://example.com", ShortUrl: "NEDF34qw", Clicks: 4, DeletedAt: &deletedAt, Tags: []string{"news"}}
+	assert.NoError(t, client.PutUrl(ctx, replaced))
+	url, err := client.RetrieveUrl(ctx, "NEDF34qw")
+	assert.NoError(t, err)
+	assert.Equal(t, replaced, url)
+
+	added := models.Url{Id: 1, LongUrl: "https://example.org", ShortUrl: "NEWDSa31"}
+	assert.NoError(t, client.PutUrl(ctx, added), "the id of a replaced entry is free again")
+	url, err = client.RetrieveUrl(ctx, "NEWDSa31")
+	assert.NoError(t, err)
+	assert.Equal(t, added, url)
+
+	err = client.PutUrl(ctx, models.Url{Id: 2, LongUrl: "https://example.org", ShortUrl: "NWER425d"})
+	assert.ErrorIs(t, err, ErrShortUrlTaken)
+}
+
+// testScanUrls runs the ScanUrls tests shared by the backends that scan in the order of the shortened URLs
+func testScanUrls(t *testing.T, ctx context.Context, client UrlRepository) {
+	addListedUrls(t, ctx, client)
+
+	var shortUrls []string
+	err := client.ScanUrls(ctx, func(url models.Url) error {
+		shortUrls = append(shortUrls, url.ShortUrl)
+		if url.ShortUrl == "eee" {
+			assert.True(t, url.Deleted(), "tombstones are scanned")
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"aaa", "bbb", "ccc", "ddd", "eee", "legacy"}, shortUrls)
+
+	stop := errors.New("stop")
+	scanned := 0
+	err = client.ScanUrls(ctx, func(url models.Url) error {
+		scanned++
+		return stop
+	})
+	assert.ErrorIs(t, err, stop)
+	assert.Equal(t, 1, scanned)
+}
+
+// testRetrieveReusableUrl runs the RetrieveReusableUrl tests shared by the backends that look up the index consistently
+func testRetrieveReusableUrl(t *testing.T, ctx context.Context, client UrlRepository) {
+	destination := "https://example.com/"
+	urls := []models.Url{
+		{Id: 1, ShortUrl: "bbb", LongUrl: destination, Destination: destination, Owner: "alice"},
+		{Id: 2, ShortUrl: "aaa", LongUrl: destination, Destination: destination, Owner: "alice", MaxClicks: 5},
+		{Id: 3, ShortUrl: "ccc", LongUrl: destination, Destination: destination, Owner: "bob"},
+		{Id: 4, ShortUrl: "ddd", LongUrl: destination, Destination: destination},
+		{Id: 5, ShortUrl: "eee", LongUrl: "https://example.org", Destination: "https://example.org", Owner: "alice"},
+		{Id: 6, ShortUrl: "fff", LongUrl: destination, Owner: "alice"},
+	}
+	for _, url := range urls {
+		if err := client.AddUrl(ctx, url); err != nil {
+			t.Fatalf("Couldn't add url: %v", err)
+		}
+	}
+	reusable := func(owner string) string {
+		url, err := client.RetrieveReusableUrl(ctx, owner, destination)
+		assert.NoError(t, err)
+		return url.ShortUrl
+	}
+
+	assert.Equal(t, "bbb", reusable("alice"))
+	assert.Equal(t, "ccc", reusable("bob"))
+	assert.Equal(t, "ddd", reusable(""))
+	assert.Empty(t, reusable("carol"))
+
+	assert.NoError(t, client.DeleteUrl(ctx, "bbb"))
+	assert.Empty(t, reusable("alice"), "deleted and click-limited urls are not reused")
+
+	longUrl := destination
+	_, err := client.UpdateUrl(ctx, "eee", models.UrlUpdate{LongUrl: &longUrl, Destination: &destination})
+	assert.NoError(t, err)
+	assert.Equal(t, "eee", reusable("alice"))
+
+	assert.NoError(t, client.PutUrl(ctx, models.Url{Id: 3, ShortUrl: "ccc", LongUrl: "https://example.org", Destination: "https://example.org", Owner: "bob"}))
+	assert.Empty(t, reusable("bob"))
+}
+
+// testApiKeys runs the ApiKeyRepository tests shared by the backends
+func testApiKeys(t *testing.T, ctx context.Context, client ApiKeyRepository) {
+	createdAt := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	keys := []models.ApiKey{
+		{Id: "b2", Owner: "alice", Scopes: []string{models.ScopeCreate, models.ScopeRead}, Name: "cli", SecretHash: "hash-b2", CreatedAt: &createdAt},
+		{Id: "a1", Owner: "bob", Scopes: []string{models.ScopeAdmin}, SecretHash: "hash-a1", CreatedAt: &createdAt},
+		{Id: "c3", Owner: "alice", Scopes: []string{models.ScopeDelete}, SecretHash: "hash-c3", CreatedAt: &createdAt},
+	}
+	for _, key := range keys {
+		assert.NoError(t, client.AddApiKey(ctx, key))
+	}
+
+	key, err := client.RetrieveApiKey(ctx, "b2")
+	assert.NoError(t, err)
+	assert.Equal(t, keys[0], key)
+
+	key, err = client.RetrieveApiKey(ctx, "missing")
+	assert.NoError(t, err)
+	assert.Zero(t, key)
+
+	ids := func(owner string) []string {
+		listed, err := client.ListApiKeys(ctx, owner)
+		assert.NoError(t, err)
+		ids := make([]string, 0, len(listed))
+		for _, key := range listed {
+			ids = append(ids, key.Id)
+		}
+		return ids
+	}
+	assert.Equal(t, []string{"a1", "b2", "c3"}, ids(""))
+	assert.Equal(t, []string{"b2", "c3"}, ids("alice"))
+	assert.Empty(t, ids("carol"))
+
+	revokedAt := time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)
+	assert.NoError(t, client.RevokeApiKey(ctx, "c3", revokedAt))
+	key, err = client.RetrieveApiKey(ctx, "c3")
+	assert.NoError(t, err)
+	assert.True(t, key.Revoked())
+	assert.True(t, revokedAt.Equal(*key.RevokedAt))
+
+	assert.True(t, errors.Is(client.RevokeApiKey(ctx, "c3", revokedAt), ErrApiKeyNotFound), "already revoked")
+	assert.True(t, errors.Is(client.RevokeApiKey(ctx, "missing", revokedAt), ErrApiKeyNotFound))
+}
@@ -0,0 +1,690 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kjj1998/url-shortener-go/internal/models"
+)
+
+// sqlDialect holds what differs between the SQL databases supported as backends
+type sqlDialect struct {
+	// placeholder returns the bind parameter of the nth (1-based) argument of a query, nil keeps ?
+	placeholder func(n int) string
+	// isUniqueViolation reports whether err was raised by a unique or primary key constraint
+	isUniqueViolation func(err error) bool
+	// contains returns a case-sensitive condition that a ? bind parameter is a substring of column
+	contains func(column string) string
+	// lockMigrations and unlockMigrations take and release a lock held by the connection applying the migrations,
+	// so instances starting together do not apply them twice. They are empty for databases that need no lock
+	lockMigrations   string
+	unlockMigrations string
+}
+
+// rebind rewrites the ? bind parameters of a query into the placeholders of the dialect
+func (dialect sqlDialect) rebind(query string) string {
+	if dialect.placeholder == nil {
+		return query
+	}
+
+	var builder strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			builder.WriteString(dialect.placeholder(n))
+		} else {
+			builder.WriteRune(r)
+		}
+	}
+	return builder.String()
+}
+
+func dollarPlaceholder(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+// SqlClient is a UrlRepository backed by a SQL database holding the links table.
+// Its queries are prepared once when the client is created and shared by the connection pool
+type SqlClient struct {
+	Db      *sql.DB
+	dialect sqlDialect
+
+	addUrlStmt              *sql.Stmt
+	addUrlsStmt             *sql.Stmt
+	putUrlStmt              *sql.Stmt
+	retrieveUrlStmt         *sql.Stmt
+	retrieveReusableUrlStmt *sql.Stmt
+	recordClickStmt         *sql.Stmt
+	deleteUrlStmt           *sql.Stmt
+	retrieveApiKeyStmt      *sql.Stmt
+	revokeApiKeyStmt        *sql.Stmt
+}
+
+// linkColumns are the columns of the links table read into a models.Url by scanUrl
+const linkColumns = `id, short_url, long_url, expires_at, max_clicks, clicks, password_hash, deleted_at, created_at, owner, domain, ` +
+	`destination, title, description, notes, tags`
+
+// insertLink inserts a row from the arguments of addUrlArgs
+const insertLink = `INSERT INTO links (` + linkColumns + `) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+// upsertLink replaces the columns of the row of the shortened URL with the ones inserted
+const upsertLink = insertLink + ` ON CONFLICT (short_url) DO UPDATE SET id = excluded.id, long_url = excluded.long_url, ` +
+	`expires_at = excluded.expires_at, max_clicks = excluded.max_clicks, clicks = excluded.clicks, ` +
+	`password_hash = excluded.password_hash, deleted_at = excluded.deleted_at, created_at = excluded.created_at, ` +
+	`owner = excluded.owner, domain = excluded.domain, destination = excluded.destination, title = excluded.title, description = excluded.description, ` +
+	`notes = excluded.notes, tags = excluded.tags`
+
+// apiKeyColumns are the columns of the api_keys table read into a models.ApiKey by scanApiKey
+const apiKeyColumns = `id, owner, scopes, name, secret_hash, created_at, revoked_at`
+
+// newSqlClient migrates the schema of db to the latest version and prepares the queries of the client
+func newSqlClient(ctx context.Context, db *sql.DB, dialect sqlDialect) (SqlClient, error) {
+	client := SqlClient{Db: db, dialect: dialect}
+
+	if err := migrate(ctx, db, dialect); err != nil {
+		return SqlClient{}, err
+	}
+
+	var err error
+	client.addUrlStmt, err = client.prepare(ctx, insertLink)
+	if err != nil {
+		return SqlClient{}, err
+	}
+	client.addUrlsStmt, err = client.prepare(ctx, insertLink+` ON CONFLICT DO NOTHING`)
+	if err != nil {
+		return SqlClient{}, err
+	}
+	client.putUrlStmt, err = client.prepare(ctx, upsertLink)
+	if err != nil {
+		return SqlClient{}, err
+	}
+	client.retrieveUrlStmt, err = client.prepare(ctx, `SELECT `+linkColumns+` FROM links WHERE short_url = ?`)
+	if err != nil {
+		return SqlClient{}, err
+	}
+	client.retrieveReusableUrlStmt, err = client.prepare(ctx, `SELECT `+linkColumns+` FROM links `+
+		`WHERE destination = ? AND COALESCE(owner, '') = ? AND deleted_at IS NULL AND expires_at IS NULL `+
+		`AND max_clicks IS NULL AND password_hash IS NULL ORDER BY short_url LIMIT 1`)
+	if err != nil {
+		return SqlClient{}, err
+	}
+	client.recordClickStmt, err = client.prepare(ctx, `UPDATE links SET clicks = clicks + 1 WHERE short_url = ? AND clicks < max_clicks`)
+	if err != nil {
+		return SqlClient{}, err
+	}
+	client.deleteUrlStmt, err = client.prepare(ctx, `UPDATE links SET deleted_at = ? WHERE short_url = ? AND deleted_at IS NULL`)
+	if err != nil {
+		return SqlClient{}, err
+	}
+	client.retrieveApiKeyStmt, err = client.prepare(ctx, `SELECT `+apiKeyColumns+` FROM api_keys WHERE id = ?`)
+	if err != nil {
+		return SqlClient{}, err
+	}
+	client.revokeApiKeyStmt, err = client.prepare(ctx, `UPDATE api_keys SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL`)
+	if err != nil {
+		return SqlClient{}, err
+	}
+
+	return client, nil
+}
+
+func (client SqlClient) prepare(ctx context.Context, query string) (*sql.Stmt, error) {
+	return client.Db.PrepareContext(ctx, client.dialect.rebind(query))
+}
+
+// scanUrl reads a row selected with linkColumns into a models.Url
+func scanUrl(row interface{ Scan(dest ...any) error }) (models.Url, error) {
+	var url models.Url
+	var expiresAt, maxClicks, deletedAt, createdAt sql.NullInt64
+	var passwordHash, owner, domain, destination, title, description, notes, tags sql.NullString
+
+	err := row.Scan(&url.Id, &url.ShortUrl, &url.LongUrl, &expiresAt, &maxClicks, &url.Clicks, &passwordHash, &deletedAt,
+		&createdAt, &owner, &domain, &destination, &title, &description, &notes, &tags)
+	if err != nil {
+		return models.Url{}, err
+	}
+
+	url.ExpiresAt = fromNullTime(expiresAt)
+	url.MaxClicks = maxClicks.Int64
+	url.PasswordHash = passwordHash.String
+	url.DeletedAt = fromNullTime(deletedAt)
+	url.CreatedAt = fromNullTime(createdAt)
+	url.Owner = owner.String
+	url.Domain = domain.String
+	url.Destination = destination.String
+	url.Title = title.String
+	url.Description = description.String
+	url.Notes = notes.String
+	url.Tags = splitTags(tags)
+	return url, nil
+}
+
+// nullTime stores an optional time as epoch seconds, like the attributes of the DynamoDB table
+func nullTime(t *time.Time) sql.NullInt64 {
+	if t == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: t.Unix(), Valid: true}
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// joinTags stores tags comma-delimited with a leading and trailing comma, so a tag is matched by the substring
+// delimitTag returns for it
+func joinTags(tags []string) sql.NullString {
+	if len(tags) == 0 {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: "," + strings.Join(tags, ",") + ",", Valid: true}
+}
+
+func splitTags(tags sql.NullString) []string {
+	trimmed := strings.Trim(tags.String, ",")
+	if trimmed == "" {
+		return nil
+	}
+	return strings.Split(trimmed, ",")
+}
+
+func delimitTag(tag string) string {
+	return "," + tag + ","
+}
+
+func fromNullTime(seconds sql.NullInt64) *time.Time {
+	if !seconds.Valid {
+		return nil
+	}
+	t := time.Unix(seconds.Int64, 0).UTC()
+	return &t
+}
+
+// AddUrl adds a URL and its shortened form as a row into the links table
+// Returns ErrShortUrlTaken when another row already holds the shortened URL or the Id
+func (client SqlClient) AddUrl(ctx context.Context, url models.Url) error {
+	_, err := client.addUrlStmt.ExecContext(ctx, addUrlArgs(url)...)
+	if err != nil && client.dialect.isUniqueViolation(err) {
+		return ErrShortUrlTaken
+	}
+	if err != nil {
+		log.Printf("Couldn't add row to links table. Here's why: %v\n", err)
+	}
+	return err
+}
+
+// AddUrls inserts the URLs in a single transaction, skipping the rows whose shortened URL or Id is taken so the
+// others are still added. Any other error fails every URL
+func (client SqlClient) AddUrls(ctx context.Context, urls []models.Url) []error {
+	errs := make([]error, len(urls))
+	if err := client.addUrls(ctx, urls, errs); err != nil {
+		log.Printf("Couldn't add rows to links table. Here's why: %v\n", err)
+		for i := range errs {
+			errs[i] = err
+		}
+	}
+	return errs
+}
+
+func (client SqlClient) addUrls(ctx context.Context, urls []models.Url, errs []error) error {
+	tx, err := client.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt := tx.StmtContext(ctx, client.addUrlsStmt)
+	for i, url := range urls {
+		result, err := stmt.ExecContext(ctx, addUrlArgs(url)...)
+		if err != nil {
+			return err
+		}
+		added, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if added == 0 {
+			errs[i] = ErrShortUrlTaken
+		}
+	}
+
+	return tx.Commit()
+}
+
+// addUrlArgs returns the arguments of insertLink for url
+func addUrlArgs(url models.Url) []any {
+	maxClicks := sql.NullInt64{Int64: url.MaxClicks, Valid: url.MaxClicks > 0}
+
+	return []any{url.Id, url.ShortUrl, url.LongUrl, nullTime(url.ExpiresAt), maxClicks, url.Clicks,
+		nullString(url.PasswordHash), nullTime(url.DeletedAt), nullTime(url.CreatedAt), nullString(url.Owner),
+		nullString(url.Domain), nullString(url.Destination), nullString(url.Title), nullString(url.Description),
+		nullString(url.Notes), joinTags(url.Tags)}
+}
+
+// PutUrl inserts url as the row of its shortened URL, or replaces the columns of the row already there
+// Returns ErrShortUrlTaken when the Id is held by the row of another shortened URL
+func (client SqlClient) PutUrl(ctx context.Context, url models.Url) error {
+	_, err := client.putUrlStmt.ExecContext(ctx, addUrlArgs(url)...)
+	if err != nil && client.dialect.isUniqueViolation(err) {
+		return ErrShortUrlTaken
+	}
+	if err != nil {
+		log.Printf("Couldn't put row into links table. Here's why: %v\n", err)
+	}
+	return err
+}
+
+// RetrieveUrl looks up the row of a shortened URL through the unique index on short_url
+// Returns a zero Url when no row exists for the shortened URL. Expired and deleted rows are returned as well
+func (client SqlClient) RetrieveUrl(ctx context.Context, shortUrl string) (models.Url, error) {
+	url, err := scanUrl(client.retrieveUrlStmt.QueryRowContext(ctx, shortUrl))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Url{}, nil
+	}
+	if err != nil {
+		log.Printf("Couldn't query for urls with shortened url %v. Here's why: %v\n", shortUrl, err)
+		return models.Url{}, err
+	}
+
+	return url, nil
+}
+
+// RetrieveReusableUrl looks up the rows of owner for destination through the index on destination, returning the
+// reusable one with the lowest shortened URL
+func (client SqlClient) RetrieveReusableUrl(ctx context.Context, owner string, destination string) (models.Url, error) {
+	url, err := scanUrl(client.retrieveReusableUrlStmt.QueryRowContext(ctx, destination, owner))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Url{}, nil
+	}
+	if err != nil {
+		log.Printf("Couldn't query for urls with destination %v. Here's why: %v\n", destination, err)
+		return models.Url{}, err
+	}
+
+	return url, nil
+}
+
+// UpdateUrl sets the changed columns of the row of a shortened URL that is not deleted, returning the updated row
+// Returns ErrUrlNotFound when no such row exists
+func (client SqlClient) UpdateUrl(ctx context.Context, shortUrl string, update models.UrlUpdate) (models.Url, error) {
+	var sets []string
+	var args []any
+	if update.LongUrl != nil {
+		sets = append(sets, `long_url = ?`)
+		args = append(args, *update.LongUrl)
+	}
+	if update.ExpiresAt != nil || update.RemoveExpiry {
+		sets = append(sets, `expires_at = ?`)
+		args = append(args, nullTime(update.ExpiresAt))
+	}
+	if update.MaxClicks != nil {
+		sets = append(sets, `max_clicks = ?`)
+		args = append(args, sql.NullInt64{Int64: *update.MaxClicks, Valid: *update.MaxClicks > 0})
+	}
+	if update.PasswordHash != nil {
+		sets = append(sets, `password_hash = ?`)
+		args = append(args, nullString(*update.PasswordHash))
+	}
+	if update.Domain != nil {
+		sets = append(sets, `domain = ?`)
+		args = append(args, nullString(*update.Domain))
+	}
+	if update.Destination != nil {
+		sets = append(sets, `destination = ?`)
+		args = append(args, nullString(*update.Destination))
+	}
+	if update.Title != nil {
+		sets = append(sets, `title = ?`)
+		args = append(args, nullString(*update.Title))
+	}
+	if update.Description != nil {
+		sets = append(sets, `description = ?`)
+		args = append(args, nullString(*update.Description))
+	}
+	if update.Notes != nil {
+		sets = append(sets, `notes = ?`)
+		args = append(args, nullString(*update.Notes))
+	}
+	if update.Tags != nil {
+		sets = append(sets, `tags = ?`)
+		args = append(args, joinTags(models.NormalizeTags(*update.Tags)))
+	}
+	if len(sets) == 0 {
+		url, err := client.RetrieveUrl(ctx, shortUrl)
+		if err == nil && (url.ShortUrl == "" || url.Deleted()) {
+			return models.Url{}, ErrUrlNotFound
+		}
+		return url, err
+	}
+
+	query := `UPDATE links SET ` + strings.Join(sets, ", ") + ` WHERE short_url = ? AND deleted_at IS NULL RETURNING ` + linkColumns
+	url, err := scanUrl(client.Db.QueryRowContext(ctx, client.dialect.rebind(query), append(args, shortUrl)...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Url{}, ErrUrlNotFound
+	}
+	if err != nil {
+		log.Printf("Couldn't update row of shortened url %v. Here's why: %v\n", shortUrl, err)
+		return models.Url{}, err
+	}
+
+	return url, nil
+}
+
+// DeleteUrl sets the deleted_at of the row of a shortened URL, keeping the row as a tombstone
+// Returns ErrUrlNotFound when no row exists for the shortened URL or it has already been deleted
+func (client SqlClient) DeleteUrl(ctx context.Context, shortUrl string) error {
+	result, err := client.deleteUrlStmt.ExecContext(ctx, time.Now().Unix(), shortUrl)
+	if err != nil {
+		log.Printf("Couldn't delete row of shortened url %v. Here's why: %v\n", shortUrl, err)
+		return err
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return ErrUrlNotFound
+	}
+	return nil
+}
+
+// ListUrls selects the rows that match filter in the order of their shortened URLs, resuming after the
+// shortened URL in cursor through the unique index on short_url
+func (client SqlClient) ListUrls(ctx context.Context, filter models.UrlFilter, cursor string, limit int) (models.UrlPage, error) {
+	after, err := decodeCursor(cursor)
+	if err != nil {
+		return models.UrlPage{}, err
+	}
+
+	conditions := []string{`deleted_at IS NULL`, `short_url > ?`}
+	args := []any{after}
+	if filter.CreatedAfter != nil {
+		conditions = append(conditions, `created_at >= ?`)
+		args = append(args, filter.CreatedAfter.Unix())
+	}
+	if filter.CreatedBefore != nil {
+		conditions = append(conditions, `created_at < ?`)
+		args = append(args, filter.CreatedBefore.Unix())
+	}
+	if filter.Domain != "" {
+		conditions = append(conditions, `domain = ?`)
+		args = append(args, strings.ToLower(filter.Domain))
+	}
+	if filter.Owner != "" {
+		conditions = append(conditions, `owner = ?`)
+		args = append(args, filter.Owner)
+	}
+	if filter.Search != "" {
+		conditions = append(conditions, `(`+client.dialect.contains(`long_url`)+` OR `+client.dialect.contains(`short_url`)+`)`)
+		args = append(args, filter.Search, filter.Search)
+	}
+	if filter.Tag != "" {
+		conditions = append(conditions, client.dialect.contains(`tags`))
+		args = append(args, delimitTag(models.NormalizeTag(filter.Tag)))
+	}
+
+	// One more row than the page holds tells whether there is a next page
+	query := `SELECT ` + linkColumns + ` FROM links WHERE ` + strings.Join(conditions, ` AND `) + ` ORDER BY short_url LIMIT ?`
+	rows, err := client.Db.QueryContext(ctx, client.dialect.rebind(query), append(args, limit+1)...)
+	if err != nil {
+		log.Printf("Couldn't list links table. Here's why: %v\n", err)
+		return models.UrlPage{}, err
+	}
+	defer rows.Close()
+
+	page := models.UrlPage{Links: make([]models.Url, 0, limit)}
+	for rows.Next() {
+		url, err := scanUrl(rows)
+		if err != nil {
+			return models.UrlPage{}, err
+		}
+		if len(page.Links) == limit {
+			page.NextCursor = encodeCursor(page.Links[limit-1].ShortUrl)
+			break
+		}
+		page.Links = append(page.Links, url)
+	}
+	return page, rows.Err()
+}
+
+// MergeTags merges the tags of every row that is not deleted and holds one of the merged tags
+func (client SqlClient) MergeTags(ctx context.Context, merge models.TagMerge) (int, error) {
+	var conditions []string
+	var args []any
+	for _, tag := range merge.Sources() {
+		conditions = append(conditions, client.dialect.contains(`tags`))
+		args = append(args, delimitTag(tag))
+	}
+
+	query := `SELECT short_url, tags FROM links WHERE deleted_at IS NULL AND (` + strings.Join(conditions, ` OR `) + `)`
+	if merge.Owner != "" {
+		query += ` AND owner = ?`
+		args = append(args, merge.Owner)
+	}
+	rows, err := client.Db.QueryContext(ctx, client.dialect.rebind(query), args...)
+	if err != nil {
+		log.Printf("Couldn't query for rows with merged tags. Here's why: %v\n", err)
+		return 0, err
+	}
+
+	tagsByShortUrl := make(map[string]sql.NullString)
+	for rows.Next() {
+		var shortUrl string
+		var tags sql.NullString
+		if err := rows.Scan(&shortUrl, &tags); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		tagsByShortUrl[shortUrl] = tags
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	merged := 0
+	for shortUrl, tags := range tagsByShortUrl {
+		ok, err := client.mergeRowTags(ctx, merge, shortUrl, tags)
+		if err != nil {
+			log.Printf("Couldn't merge tags of shortened url %v. Here's why: %v\n", shortUrl, err)
+			return merged, err
+		}
+		if ok {
+			merged++
+		}
+	}
+	return merged, nil
+}
+
+// mergeRowTags merges the tags of a row with an UPDATE that only matches the row while it still holds the tags
+// read, rereading them when it does not so a concurrent change of the tags is never overwritten
+// Returns false when the row no longer holds any of the merged tags
+func (client SqlClient) mergeRowTags(ctx context.Context, merge models.TagMerge, shortUrl string, tags sql.NullString) (bool, error) {
+	for {
+		mergedTags, ok := merge.Merged(splitTags(tags))
+		if !ok {
+			return false, nil
+		}
+
+		query := `UPDATE links SET tags = ? WHERE short_url = ? AND tags = ? AND deleted_at IS NULL`
+		result, err := client.Db.ExecContext(ctx, client.dialect.rebind(query), joinTags(mergedTags), shortUrl, tags)
+		if err != nil {
+			return false, err
+		}
+		updated, err := result.RowsAffected()
+		if err != nil {
+			return false, err
+		}
+		if updated > 0 {
+			return true, nil
+		}
+
+		query = `SELECT tags FROM links WHERE short_url = ? AND deleted_at IS NULL`
+		err = client.Db.QueryRowContext(ctx, client.dialect.rebind(query), shortUrl).Scan(&tags)
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+}
+
+// RecordClick adds one to the clicks of a shortened URL in a single UPDATE that only matches the row while
+// clicks is below max_clicks, so concurrent redirects never serve more than max_clicks between them
+// Returns ErrClicksExhausted when no row was updated
+func (client SqlClient) RecordClick(ctx context.Context, shortUrl string) error {
+	result, err := client.recordClickStmt.ExecContext(ctx, shortUrl)
+	if err != nil {
+		log.Printf("Couldn't record click of shortened url %v. Here's why: %v\n", shortUrl, err)
+		return err
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return ErrClicksExhausted
+	}
+	return nil
+}
+
+// ScanShortUrls calls fn with every shortened URL in the links table, stopping at the first error
+func (client SqlClient) ScanShortUrls(ctx context.Context, fn func(shortUrl string) error) error {
+	rows, err := client.Db.QueryContext(ctx, `SELECT short_url FROM links`)
+	if err != nil {
+		log.Printf("Couldn't scan links table. Here's why: %v\n", err)
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var shortUrl string
+		if err := rows.Scan(&shortUrl); err != nil {
+			return err
+		}
+		if err := fn(shortUrl); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// ScanUrls calls fn with every row of the links table in the order of their shortened URLs, stopping at the first error
+func (client SqlClient) ScanUrls(ctx context.Context, fn func(url models.Url) error) error {
+	rows, err := client.Db.QueryContext(ctx, `SELECT `+linkColumns+` FROM links ORDER BY short_url`)
+	if err != nil {
+		log.Printf("Couldn't scan links table. Here's why: %v\n", err)
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		url, err := scanUrl(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(url); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// scanApiKey reads a row selected with apiKeyColumns into a models.ApiKey
+func scanApiKey(row interface{ Scan(dest ...any) error }) (models.ApiKey, error) {
+	var key models.ApiKey
+	var scopes string
+	var name sql.NullString
+	var createdAt, revokedAt sql.NullInt64
+
+	if err := row.Scan(&key.Id, &key.Owner, &scopes, &name, &key.SecretHash, &createdAt, &revokedAt); err != nil {
+		return models.ApiKey{}, err
+	}
+
+	key.Scopes = strings.Split(scopes, ",")
+	key.Name = name.String
+	key.CreatedAt = fromNullTime(createdAt)
+	key.RevokedAt = fromNullTime(revokedAt)
+	return key, nil
+}
+
+// AddApiKey inserts an API key as a row into the api_keys table
+func (client SqlClient) AddApiKey(ctx context.Context, key models.ApiKey) error {
+	query := `INSERT INTO api_keys (` + apiKeyColumns + `) VALUES (?, ?, ?, ?, ?, ?, ?)`
+	_, err := client.Db.ExecContext(ctx, client.dialect.rebind(query), key.Id, key.Owner, strings.Join(key.Scopes, ","),
+		nullString(key.Name), key.SecretHash, nullTime(key.CreatedAt), nullTime(key.RevokedAt))
+	if err != nil {
+		log.Printf("Couldn't add row to api_keys table. Here's why: %v\n", err)
+	}
+	return err
+}
+
+// RetrieveApiKey looks up the row of an API key by its primary key
+// Returns a zero ApiKey when no row exists for id
+func (client SqlClient) RetrieveApiKey(ctx context.Context, id string) (models.ApiKey, error) {
+	key, err := scanApiKey(client.retrieveApiKeyStmt.QueryRowContext(ctx, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.ApiKey{}, nil
+	}
+	if err != nil {
+		log.Printf("Couldn't query for api key %v. Here's why: %v\n", id, err)
+		return models.ApiKey{}, err
+	}
+
+	return key, nil
+}
+
+// ListApiKeys selects the rows of owner through the index on owner, or every row when it is empty
+func (client SqlClient) ListApiKeys(ctx context.Context, owner string) ([]models.ApiKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys`
+	var args []any
+	if owner != "" {
+		query += ` WHERE owner = ?`
+		args = append(args, owner)
+	}
+
+	rows, err := client.Db.QueryContext(ctx, client.dialect.rebind(query+` ORDER BY id`), args...)
+	if err != nil {
+		log.Printf("Couldn't list api_keys table. Here's why: %v\n", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make([]models.ApiKey, 0)
+	for rows.Next() {
+		key, err := scanApiKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// RevokeApiKey sets the revoked_at of the row of an API key that is not revoked yet
+// Returns ErrApiKeyNotFound when no such row exists
+func (client SqlClient) RevokeApiKey(ctx context.Context, id string, revokedAt time.Time) error {
+	result, err := client.revokeApiKeyStmt.ExecContext(ctx, revokedAt.Unix(), id)
+	if err != nil {
+		log.Printf("Couldn't revoke api key %v. Here's why: %v\n", id, err)
+		return err
+	}
+
+	revoked, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if revoked == 0 {
+		return ErrApiKeyNotFound
+	}
+	return nil
+}
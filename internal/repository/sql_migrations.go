@@ -0,0 +1,163 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+)
+
+// sqlMigration is a versioned set of schema changes for the SQL backends
+type sqlMigration struct {
+	version    int
+	statements []string
+}
+
+// sqlMigrations are applied in order, new schema changes must be appended with the next version
+var sqlMigrations = []sqlMigration{
+	{
+		version: 1,
+		statements: []string{
+			`CREATE TABLE links (
+				id BIGINT PRIMARY KEY,
+				short_url TEXT NOT NULL,
+				long_url TEXT NOT NULL
+			)`,
+			`CREATE UNIQUE INDEX links_short_url_idx ON links (short_url)`,
+		},
+	},
+	{
+		version: 2,
+		statements: []string{
+			// Epoch seconds, like the ExpiresAt attribute of the DynamoDB table
+			`ALTER TABLE links ADD COLUMN expires_at BIGINT`,
+		},
+	},
+	{
+		version: 3,
+		statements: []string{
+			`ALTER TABLE links ADD COLUMN max_clicks BIGINT`,
+			`ALTER TABLE links ADD COLUMN clicks BIGINT NOT NULL DEFAULT 0`,
+		},
+	},
+	{
+		version: 4,
+		statements: []string{
+			`ALTER TABLE links ADD COLUMN password_hash TEXT`,
+		},
+	},
+	{
+		version: 5,
+		statements: []string{
+			`ALTER TABLE links ADD COLUMN deleted_at BIGINT`,
+		},
+	},
+	{
+		version: 6,
+		statements: []string{
+			`ALTER TABLE links ADD COLUMN created_at BIGINT`,
+			`ALTER TABLE links ADD COLUMN owner TEXT`,
+			`ALTER TABLE links ADD COLUMN domain TEXT`,
+			`CREATE INDEX links_owner_idx ON links (owner)`,
+		},
+	},
+	{
+		version: 7,
+		statements: []string{
+			`ALTER TABLE links ADD COLUMN title TEXT`,
+			`ALTER TABLE links ADD COLUMN description TEXT`,
+			`ALTER TABLE links ADD COLUMN notes TEXT`,
+			// Comma-delimited with a leading and trailing comma, e.g. ,go,news, so a tag is matched as a substring
+			`ALTER TABLE links ADD COLUMN tags TEXT`,
+		},
+	},
+	{
+		version: 8,
+		statements: []string{
+			// Rows added before this version are left without a destination, so they are never reused
+			`ALTER TABLE links ADD COLUMN destination TEXT`,
+			`CREATE INDEX links_destination_idx ON links (destination)`,
+		},
+	},
+	{
+		version: 9,
+		statements: []string{
+			// Scopes are comma-separated, secret_hash is the hex-encoded SHA-256 hash of the secret of the key
+			`CREATE TABLE api_keys (
+				id TEXT PRIMARY KEY,
+				owner TEXT NOT NULL,
+				scopes TEXT NOT NULL,
+				name TEXT,
+				secret_hash TEXT NOT NULL,
+				created_at BIGINT,
+				revoked_at BIGINT
+			)`,
+			`CREATE INDEX api_keys_owner_idx ON api_keys (owner)`,
+		},
+	},
+}
+
+// migrate brings the schema of the database up to the latest version, recording the applied
+// versions in the schema_migrations table. Each version is applied in its own transaction, on a single connection
+// holding the migration lock of the dialect so the version is only read once other instances are done migrating
+func migrate(ctx context.Context, db *sql.DB, dialect sqlDialect) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("couldn't connect to migrate schema: %w", err)
+	}
+	defer conn.Close()
+
+	if dialect.lockMigrations != "" {
+		if _, err := conn.ExecContext(ctx, dialect.lockMigrations); err != nil {
+			return fmt.Errorf("couldn't lock schema migrations: %w", err)
+		}
+		defer func() {
+			if _, err := conn.ExecContext(context.WithoutCancel(ctx), dialect.unlockMigrations); err != nil {
+				log.Printf("Couldn't unlock schema migrations. Here's why: %v\n", err)
+			}
+		}()
+	}
+
+	_, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY)`)
+	if err != nil {
+		return fmt.Errorf("couldn't create schema_migrations table: %w", err)
+	}
+
+	var current int
+	err = conn.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current)
+	if err != nil {
+		return fmt.Errorf("couldn't read schema version: %w", err)
+	}
+
+	for _, m := range sqlMigrations {
+		if m.version <= current {
+			continue
+		}
+
+		if err := applyMigration(ctx, conn, dialect, m); err != nil {
+			return fmt.Errorf("couldn't apply migration %d: %w", m.version, err)
+		}
+		log.Printf("Applied schema migration %d\n", m.version)
+	}
+
+	return nil
+}
+
+func applyMigration(ctx context.Context, conn *sql.Conn, dialect sqlDialect, m sqlMigration) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, statement := range m.statements {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, dialect.rebind(`INSERT INTO schema_migrations (version) VALUES (?)`), m.version); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,342 @@
+package repository
+
+import (
+	"context"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kjj1998/url-shortener-go/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+type enterSqlTest func(t *testing.T) (context.Context, SqlClient)
+
+func enterSqliteTest(t *testing.T) (context.Context, SqlClient) {
+	client, err := NewSqliteClient(":memory:")
+	if err != nil {
+		t.Fatalf("Couldn't open sqlite database: %v", err)
+	}
+	t.Cleanup(func() { client.Db.Close() })
+
+	return context.Background(), client
+}
+
+// enterPostgresTest connects to the database at POSTGRES_TEST_DSN, dropping every table so each
+// test starts from a freshly migrated schema
+func enterPostgresTest(t *testing.T) (context.Context, SqlClient) {
+	dsn := os.Getenv("POSTGRES_TEST_DSN")
+
+	ctx := context.Background()
+	cleanup, err := NewPostgresClient(dsn)
+	if err != nil {
+		t.Fatalf("Couldn't connect to postgres database: %v", err)
+	}
+	_, err = cleanup.Db.ExecContext(ctx, `DROP TABLE IF EXISTS links, api_keys, schema_migrations`)
+	cleanup.Db.Close()
+	if err != nil {
+		t.Fatalf("Couldn't reset postgres database: %v", err)
+	}
+
+	client, err := NewPostgresClient(dsn)
+	if err != nil {
+		t.Fatalf("Couldn't connect to postgres database: %v", err)
+	}
+	t.Cleanup(func() { client.Db.Close() })
+
+	return ctx, client
+}
+
+func TestSqliteClient(t *testing.T) {
+	runSqlClientTests(t, enterSqliteTest)
+}
+
+func TestPostgresClient(t *testing.T) {
+	if _, exists := os.LookupEnv("POSTGRES_TEST_DSN"); !exists {
+		t.Skip("POSTGRES_TEST_DSN is not set, skipping PostgreSQL tests")
+	}
+
+	runSqlClientTests(t, enterPostgresTest)
+	t.Run("ConcurrentMigrate", func(t *testing.T) {
+		ctx, client := enterPostgresTest(t)
+		_, err := client.Db.ExecContext(ctx, `DROP TABLE links, api_keys, schema_migrations`)
+		assert.NoError(t, err)
+
+		// Instances starting together take turns, so only the first one applies the migrations
+		errs := make(chan error, 4)
+		for range 4 {
+			go func() { errs <- migrate(ctx, client.Db, client.dialect) }()
+		}
+		for range 4 {
+			assert.NoError(t, <-errs)
+		}
+	})
+}
+
+func runSqlClientTests(t *testing.T, enter enterSqlTest) {
+	t.Run("AddUrl", func(t *testing.T) {
+		t.Run("NoErrors", func(t *testing.T) { sqlAddUrl(enter, false, t) })
+		t.Run("TestError", func(t *testing.T) { sqlAddUrl(enter, true, t) })
+		t.Run("ShortUrlTaken", func(t *testing.T) { sqlAddTakenUrl(enter, t) })
+	})
+	t.Run("AddUrls", func(t *testing.T) {
+		t.Run("NoErrors", func(t *testing.T) {
+			ctx, client := enter(t)
+			testAddUrls(t, ctx, client)
+		})
+		t.Run("TestError", func(t *testing.T) { sqlAddUrlsError(enter, t) })
+	})
+	t.Run("PutUrl", func(t *testing.T) {
+		ctx, client := enter(t)
+		testPutUrl(t, ctx, client)
+	})
+	t.Run("RetrieveUrl", func(t *testing.T) {
+		t.Run("NoErrors", func(t *testing.T) { sqlRetrieveUrl(enter, false, t) })
+		t.Run("TestError", func(t *testing.T) { sqlRetrieveUrl(enter, true, t) })
+		t.Run("NoUrlsRetrieved", func(t *testing.T) { sqlRetrieveNoUrl(enter, t) })
+		t.Run("ExpiringUrlRetrieved", func(t *testing.T) { sqlRetrieveExpiringUrl(enter, t) })
+	})
+	t.Run("RetrieveReusableUrl", func(t *testing.T) {
+		ctx, client := enter(t)
+		testRetrieveReusableUrl(t, ctx, client)
+	})
+	t.Run("UpdateUrl", func(t *testing.T) {
+		t.Run("NoErrors", func(t *testing.T) { sqlUpdateUrl(enter, t) })
+		t.Run("NotFound", func(t *testing.T) { sqlUpdateNoUrl(enter, t) })
+	})
+	t.Run("DeleteUrl", func(t *testing.T) { sqlDeleteUrl(enter, t) })
+	t.Run("ListUrls", func(t *testing.T) {
+		ctx, client := enter(t)
+		testListUrls(t, ctx, client)
+	})
+	t.Run("MergeTags", func(t *testing.T) {
+		ctx, client := enter(t)
+		testMergeTags(t, ctx, client)
+	})
+	t.Run("RecordClick", func(t *testing.T) {
+		t.Run("NoErrors", func(t *testing.T) { sqlRecordClick(enter, t) })
+		t.Run("Unlimited", func(t *testing.T) { sqlRecordUnlimitedClick(enter, t) })
+	})
+	t.Run("ScanShortUrls", func(t *testing.T) { sqlScanShortUrls(enter, t) })
+	t.Run("ScanUrls", func(t *testing.T) {
+		ctx, client := enter(t)
+		testScanUrls(t, ctx, client)
+	})
+	t.Run("ApiKeys", func(t *testing.T) {
+		ctx, client := enter(t)
+		testApiKeys(t, ctx, client)
+	})
+	t.Run("Migrate", func(t *testing.T) { sqlMigrate(enter, t) })
+}
+
+func sqlAddUrl(enter enterSqlTest, raiseErr bool, t *testing.T) {
+	ctx, client := enter(t)
+	if raiseErr {
+		client.Db.Close()
+	}
+
+	url := models.Url{Id: 12345, LongUrl: "https://www.youtube.com", ShortUrl: "NEDF34qw", PasswordHash: "$2a$10$hash"}
+	err := client.AddUrl(ctx, url)
+
+	if raiseErr {
+		assert.Error(t, err)
+		return
+	}
+	assert.NoError(t, err)
+
+	retrieved, err := client.RetrieveUrl(ctx, url.ShortUrl)
+	assert.NoError(t, err)
+	assert.Equal(t, url, retrieved)
+}
+
+func sqlAddTakenUrl(enter enterSqlTest, t *testing.T) {
+	ctx, client := enter(t)
+
+	assert.NoError(t, client.AddUrl(ctx, models.Url{Id: 1, LongUrl: "https://www.youtube.com", ShortUrl: "NEDF34qw"}))
+	err := client.AddUrl(ctx, models.Url{Id: 2, LongUrl: "https://www.google.com", ShortUrl: "NEDF34qw"})
+	assert.ErrorIs(t, err, ErrShortUrlTaken)
+
+	err = client.AddUrl(ctx, models.Url{Id: 1, LongUrl: "https://www.google.com", ShortUrl: "KWBG425d"})
+	assert.ErrorIs(t, err, ErrShortUrlTaken)
+}
+
+func sqlAddUrlsError(enter enterSqlTest, t *testing.T) {
+	ctx, client := enter(t)
+	client.Db.Close()
+
+	errs := client.AddUrls(ctx, []models.Url{
+		{Id: 1, LongUrl: "https://www.youtube.com", ShortUrl: "NEDF34qw"},
+		{Id: 2, LongUrl: "https://www.google.com", ShortUrl: "KWBG425d"},
+	})
+
+	assert.Len(t, errs, 2)
+	assert.Error(t, errs[0])
+	assert.Error(t, errs[1])
+}
+
+func sqlRetrieveUrl(enter enterSqlTest, raiseErr bool, t *testing.T) {
+	ctx, client := enter(t)
+
+	shortUrl := "NEWDSa31"
+	longUrl := "https://www.youtube.com"
+	var Id uint64 = 5438989247290
+
+	assert.NoError(t, client.AddUrl(ctx, models.Url{Id: Id, ShortUrl: shortUrl, LongUrl: longUrl}))
+	if raiseErr {
+		client.Db.Close()
+	}
+
+	url, err := client.RetrieveUrl(ctx, shortUrl)
+
+	if raiseErr {
+		assert.Error(t, err)
+		return
+	}
+	assert.NoError(t, err)
+	assert.Equal(t, models.Url{Id: Id, ShortUrl: shortUrl, LongUrl: longUrl}, url)
+}
+
+func sqlRetrieveNoUrl(enter enterSqlTest, t *testing.T) {
+	ctx, client := enter(t)
+
+	url, err := client.RetrieveUrl(ctx, "NEWDSa31")
+
+	assert.NoError(t, err)
+	assert.Empty(t, url)
+}
+
+func sqlRetrieveExpiringUrl(enter enterSqlTest, t *testing.T) {
+	ctx, client := enter(t)
+
+	expiresAt := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.NoError(t, client.AddUrl(ctx, models.Url{Id: 1, LongUrl: "https://www.youtube.com", ShortUrl: "NEDF34qw", ExpiresAt: &expiresAt}))
+
+	url, err := client.RetrieveUrl(ctx, "NEDF34qw")
+	assert.NoError(t, err)
+	if assert.NotNil(t, url.ExpiresAt) {
+		assert.True(t, expiresAt.Equal(*url.ExpiresAt))
+	}
+}
+
+func sqlUpdateUrl(enter enterSqlTest, t *testing.T) {
+	ctx, client := enter(t)
+	assert.NoError(t, client.AddUrl(ctx, models.Url{Id: 1, LongUrl: "https://www.youtube.com", ShortUrl: "NEDF34qw", PasswordHash: "$2a$10$hash"}))
+
+	longUrl := "https://www.google.com"
+	expiresAt := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	maxClicks := int64(3)
+	passwordHash := ""
+	title := "Search"
+	tags := []string{"Search", "google"}
+	url, err := client.UpdateUrl(ctx, "NEDF34qw", models.UrlUpdate{
+		LongUrl:      &longUrl,
+		ExpiresAt:    &expiresAt,
+		MaxClicks:    &maxClicks,
+		PasswordHash: &passwordHash,
+		Title:        &title,
+		Tags:         &tags,
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, models.Url{Id: 1, LongUrl: longUrl, ShortUrl: "NEDF34qw", ExpiresAt: &expiresAt, MaxClicks: 3,
+		Title: title, Tags: []string{"google", "search"}}, url)
+	retrieved, err := client.RetrieveUrl(ctx, "NEDF34qw")
+	assert.NoError(t, err)
+	assert.Equal(t, url, retrieved)
+
+	url, err = client.UpdateUrl(ctx, "NEDF34qw", models.UrlUpdate{RemoveExpiry: true})
+	assert.NoError(t, err)
+	assert.Nil(t, url.ExpiresAt)
+	retrieved, err = client.RetrieveUrl(ctx, "NEDF34qw")
+	assert.NoError(t, err)
+	assert.Nil(t, retrieved.ExpiresAt)
+}
+
+func sqlUpdateNoUrl(enter enterSqlTest, t *testing.T) {
+	ctx, client := enter(t)
+
+	longUrl := "https://www.google.com"
+	_, err := client.UpdateUrl(ctx, "NEWDSa31", models.UrlUpdate{LongUrl: &longUrl})
+
+	assert.ErrorIs(t, err, ErrUrlNotFound)
+}
+
+func sqlDeleteUrl(enter enterSqlTest, t *testing.T) {
+	ctx, client := enter(t)
+	assert.NoError(t, client.AddUrl(ctx, models.Url{Id: 1, LongUrl: "https://www.youtube.com", ShortUrl: "NEDF34qw"}))
+
+	assert.NoError(t, client.DeleteUrl(ctx, "NEDF34qw"))
+	assert.ErrorIs(t, client.DeleteUrl(ctx, "NEDF34qw"), ErrUrlNotFound)
+	assert.ErrorIs(t, client.DeleteUrl(ctx, "NEWDSa31"), ErrUrlNotFound)
+
+	url, err := client.RetrieveUrl(ctx, "NEDF34qw")
+	assert.NoError(t, err)
+	assert.True(t, url.Deleted())
+
+	longUrl := "https://www.google.com"
+	_, err = client.UpdateUrl(ctx, "NEDF34qw", models.UrlUpdate{LongUrl: &longUrl})
+	assert.ErrorIs(t, err, ErrUrlNotFound)
+	assert.ErrorIs(t, client.AddUrl(ctx, models.Url{Id: 2, LongUrl: longUrl, ShortUrl: "NEDF34qw"}), ErrShortUrlTaken)
+}
+
+func sqlRecordClick(enter enterSqlTest, t *testing.T) {
+	ctx, client := enter(t)
+	assert.NoError(t, client.AddUrl(ctx, models.Url{Id: 1, LongUrl: "https://www.youtube.com", ShortUrl: "NEDF34qw", MaxClicks: 5}))
+
+	var served atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := client.RecordClick(ctx, "NEDF34qw")
+			if err == nil {
+				served.Add(1)
+			} else {
+				assert.ErrorIs(t, err, ErrClicksExhausted)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(5), served.Load())
+	url, err := client.RetrieveUrl(ctx, "NEDF34qw")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), url.MaxClicks)
+	assert.Equal(t, int64(5), url.Clicks)
+}
+
+func sqlRecordUnlimitedClick(enter enterSqlTest, t *testing.T) {
+	ctx, client := enter(t)
+	assert.NoError(t, client.AddUrl(ctx, models.Url{Id: 1, LongUrl: "https://www.youtube.com", ShortUrl: "NEDF34qw"}))
+
+	assert.ErrorIs(t, client.RecordClick(ctx, "NEDF34qw"), ErrClicksExhausted)
+}
+
+func sqlScanShortUrls(enter enterSqlTest, t *testing.T) {
+	ctx, client := enter(t)
+	assert.NoError(t, client.AddUrl(ctx, models.Url{Id: 1, LongUrl: "https://www.youtube.com", ShortUrl: "NEDF34qw"}))
+	assert.NoError(t, client.AddUrl(ctx, models.Url{Id: 2, LongUrl: "https://www.google.com", ShortUrl: "KWBG425d"}))
+
+	var shortUrls []string
+	err := client.ScanShortUrls(ctx, func(shortUrl string) error {
+		shortUrls = append(shortUrls, shortUrl)
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"NEDF34qw", "KWBG425d"}, shortUrls)
+}
+
+func sqlMigrate(enter enterSqlTest, t *testing.T) {
+	ctx, client := enter(t)
+
+	// Running the migrations again must be a no-op
+	assert.NoError(t, migrate(ctx, client.Db, client.dialect))
+
+	var version int
+	assert.NoError(t, client.Db.QueryRowContext(ctx, `SELECT MAX(version) FROM schema_migrations`).Scan(&version))
+	assert.Equal(t, sqlMigrations[len(sqlMigrations)-1].version, version)
+}
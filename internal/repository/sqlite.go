@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/kjj1998/url-shortener-go/constants"
+	"github.com/kjj1998/url-shortener-go/internal/config"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+var sqliteDialect = sqlDialect{
+	isUniqueViolation: func(err error) bool {
+		var sqliteErr *sqlite.Error
+		if !errors.As(err, &sqliteErr) {
+			return false
+		}
+		return sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE || sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY
+	},
+	contains: func(column string) string {
+		return "instr(" + column + ", ?) > 0"
+	},
+}
+
+// NewSqliteClient opens the embedded SQLite database at path, creating it when it does not exist,
+// and migrates its schema to the latest version
+func NewSqliteClient(path string) (SqlClient, error) {
+	db, err := sql.Open("sqlite", path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return SqlClient{}, err
+	}
+	// SQLite serialises writes, a single connection avoids busy errors and keeps
+	// in-memory databases from being split across connections
+	db.SetMaxOpenConns(1)
+
+	client, err := newSqlClient(context.TODO(), db, sqliteDialect)
+	if err != nil {
+		db.Close()
+		return SqlClient{}, err
+	}
+
+	return client, nil
+}
+
+func newSqliteClient(env string) (UrlRepository, error) {
+	return NewSqliteClient(config.String("SQLITE_PATH", constants.SqlitePath))
+}
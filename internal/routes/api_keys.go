@@ -0,0 +1,105 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kjj1998/url-shortener-go/internal/auth"
+	"github.com/kjj1998/url-shortener-go/internal/models"
+	"github.com/kjj1998/url-shortener-go/internal/repository"
+	"github.com/kjj1998/url-shortener-go/internal/utils"
+)
+
+// CreateApiKey godoc
+// @Summary create api keys
+// @Schemes
+// @Description create an api key for an owner with the given scopes. The key is only returned in this response,
+// @Description the service keeps a hash of its secret
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param key body models.NewApiKey true "Owner, scopes and name of the api key"
+// @Success 201 {object} models.CreatedApiKey
+// @Failure 400 {object} utils.HTTPError
+// @Failure 401 {object} utils.HTTPError
+// @Failure 403 {object} utils.HTTPError
+// @Failure 500 {object} utils.HTTPError
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /admin/api-keys [post]
+func CreateApiKey(g *gin.Context) {
+	var request models.NewApiKey
+	if err := g.ShouldBindJSON(&request); err != nil {
+		utils.NewError(g, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := request.Validation(); err != nil {
+		utils.NewError(g, http.StatusBadRequest, err)
+		return
+	}
+
+	created, err := auth.NewApiKey(request, timeNow())
+	if err != nil {
+		utils.NewError(g, http.StatusInternalServerError, err)
+		return
+	}
+	if err := repository.ApiKeys.AddApiKey(context.TODO(), created.ApiKey); err != nil {
+		utils.NewError(g, http.StatusInternalServerError, err)
+		return
+	}
+
+	g.IndentedJSON(http.StatusCreated, created)
+}
+
+// ListApiKeys godoc
+// @Summary list api keys
+// @Schemes
+// @Description list the api keys of an owner, or of every owner, revoked ones included
+// @Tags admin
+// @Produce json
+// @Param owner query string false "Owner of the api keys"
+// @Success 200 {array} models.ApiKey
+// @Failure 401 {object} utils.HTTPError
+// @Failure 403 {object} utils.HTTPError
+// @Failure 500 {object} utils.HTTPError
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /admin/api-keys [get]
+func ListApiKeys(g *gin.Context) {
+	keys, err := repository.ApiKeys.ListApiKeys(context.TODO(), g.Query("owner"))
+	if err != nil {
+		utils.NewError(g, http.StatusInternalServerError, err)
+		return
+	}
+
+	g.IndentedJSON(http.StatusOK, keys)
+}
+
+// RevokeApiKey godoc
+// @Summary revoke api keys
+// @Schemes
+// @Description revoke an api key, which stops authenticating requests right away
+// @Tags admin
+// @Param id path string true "Id of the api key"
+// @Success 204
+// @Failure 401 {object} utils.HTTPError
+// @Failure 403 {object} utils.HTTPError
+// @Failure 404 {object} utils.HTTPError
+// @Failure 500 {object} utils.HTTPError
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /admin/api-keys/{id} [delete]
+func RevokeApiKey(g *gin.Context) {
+	err := repository.ApiKeys.RevokeApiKey(context.TODO(), g.Param("id"), timeNow())
+	switch {
+	case errors.Is(err, repository.ErrApiKeyNotFound):
+		utils.NewError(g, http.StatusNotFound, err)
+	case err != nil:
+		utils.NewError(g, http.StatusInternalServerError, err)
+	default:
+		g.Status(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,132 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kjj1998/url-shortener-go/constants"
+	"github.com/kjj1998/url-shortener-go/internal/auth"
+	"github.com/kjj1998/url-shortener-go/internal/config"
+	"github.com/kjj1998/url-shortener-go/internal/models"
+	"github.com/kjj1998/url-shortener-go/internal/repository"
+	"github.com/kjj1998/url-shortener-go/internal/utils"
+)
+
+var (
+	ErrCredentialsMissing = errors.New("api key or bearer token is missing")
+	ErrNotOwner           = errors.New("shortened url belongs to another owner")
+)
+
+// authRequired refuses the requests sent without an API key or bearer token. When it is off they are handled as
+// before authentication existed, with every scope and no owner
+var authRequired = config.Bool("AUTH_REQUIRED", true)
+
+// Authenticate is the middleware checking the API key sent in the X-Api-Key header or as a bearer token, or the JWT
+// sent as a bearer token when JWT_JWKS is set, and keeping the owner and scopes of the request in the gin context for
+// the handlers. Requests with missing, invalid, expired or revoked credentials are refused with 401, unless
+// AUTH_REQUIRED is off and none were sent
+func Authenticate(g *gin.Context) {
+	// API keys have a single dot, JWTs two
+	if token := bearerToken(g); tokenVerifier != nil && strings.Count(token, ".") == 2 {
+		authenticateToken(g, token)
+		return
+	}
+
+	key := requestApiKey(g)
+	if key == "" {
+		if authRequired {
+			unauthorized(g, ErrCredentialsMissing)
+		}
+		return
+	}
+
+	apiKey, err := auth.AuthenticateApiKey(context.TODO(), repository.ApiKeys, key)
+	switch {
+	case errors.Is(err, auth.ErrApiKeyInvalid), errors.Is(err, auth.ErrApiKeyRevoked):
+		unauthorized(g, err)
+		return
+	case err != nil:
+		utils.NewError(g, http.StatusInternalServerError, err)
+		g.Abort()
+		return
+	}
+
+	g.Set(constants.OwnerContextKey, apiKey.Owner)
+	g.Set(constants.ScopesContextKey, apiKey.Scopes)
+}
+
+// requestApiKey returns the API key sent with a request, or an empty string when there is none
+func requestApiKey(g *gin.Context) string {
+	if key := g.GetHeader(constants.ApiKeyHeader); key != "" {
+		return key
+	}
+	return bearerToken(g)
+}
+
+// bearerToken returns the token of the Authorization header, or an empty string when there is none
+func bearerToken(g *gin.Context) string {
+	scheme, token, ok := strings.Cut(g.GetHeader("Authorization"), " ")
+	if ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+	return ""
+}
+
+func unauthorized(g *gin.Context, err error) {
+	g.Header("WWW-Authenticate", `Bearer realm="api"`)
+	utils.NewError(g, http.StatusUnauthorized, err)
+	g.Abort()
+}
+
+// RequireScope returns the middleware refusing with 403 the requests whose credentials do not grant scope. It runs
+// after Authenticate, which lets through the requests without credentials only when AUTH_REQUIRED is off
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(g *gin.Context) {
+		scopes, authenticated := g.Get(constants.ScopesContextKey)
+		if !authenticated {
+			if authRequired {
+				unauthorized(g, ErrCredentialsMissing)
+			}
+			return
+		}
+
+		if granted, _ := scopes.([]string); !models.GrantsScope(granted, scope) {
+			utils.NewError(g, http.StatusForbidden, fmt.Errorf("request is not granted the %v scope", scope))
+			g.Abort()
+		}
+	}
+}
+
+// restrictedOwner returns the owner whose shortened URLs alone the request may change, and false when it may change
+// every shortened URL, as admins and anonymous requests with AUTH_REQUIRED off may
+func restrictedOwner(g *gin.Context) (string, bool) {
+	scopes, authenticated := g.Get(constants.ScopesContextKey)
+	if granted, _ := scopes.([]string); !authenticated || models.GrantsScope(granted, models.ScopeAdmin) {
+		return "", false
+	}
+	return g.GetString(constants.OwnerContextKey), true
+}
+
+// authorizeOwner checks the request may change a shortened URL, answering 403 and returning false when it belongs
+// to another owner. Shortened URLs that do not exist are left for the handler to answer
+func authorizeOwner(g *gin.Context, shortUrl string) bool {
+	owner, restricted := restrictedOwner(g)
+	if !restricted {
+		return true
+	}
+
+	url, err := repository.Client.RetrieveUrl(context.TODO(), shortUrl)
+	if err != nil {
+		utils.NewError(g, http.StatusInternalServerError, err)
+		return false
+	}
+	if url.ShortUrl != "" && url.Owner != owner {
+		utils.NewError(g, http.StatusForbidden, ErrNotOwner)
+		return false
+	}
+	return true
+}
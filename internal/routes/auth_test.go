@@ -0,0 +1,246 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kjj1998/url-shortener-go/constants"
+	"github.com/kjj1998/url-shortener-go/internal/auth"
+	"github.com/kjj1998/url-shortener-go/internal/models"
+	"github.com/kjj1998/url-shortener-go/internal/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+// enterAuthTest stores the API keys used by the tests, returning them by name, and a router with the
+// authenticated routes of the service
+func enterAuthTest(t *testing.T, required bool) (map[string]string, *gin.Engine) {
+	authRequired = required
+	t.Cleanup(func() { authRequired = true })
+
+	client := repository.NewMemoryClient()
+	repository.Client = client
+	repository.ApiKeys = client
+
+	keys := make(map[string]string)
+	for name, request := range map[string]models.NewApiKey{
+		"creator": {Owner: "alice", Scopes: []string{models.ScopeCreate}},
+		"editor":  {Owner: "alice", Scopes: []string{models.ScopeUpdate, models.ScopeDelete}},
+		"reader":  {Owner: "bob", Scopes: []string{models.ScopeRead}},
+		"admin":   {Owner: "ops", Scopes: []string{models.ScopeAdmin}},
+		"revoked": {Owner: "alice", Scopes: []string{models.ScopeCreate}},
+	} {
+		created, err := auth.NewApiKey(request, time.Now())
+		assert.NoError(t, err)
+		assert.NoError(t, client.AddApiKey(context.Background(), created.ApiKey))
+		keys[name] = created.Key
+		if name == "revoked" {
+			assert.NoError(t, client.RevokeApiKey(context.Background(), created.Id, time.Now()))
+		}
+	}
+
+	router := gin.New()
+	data := router.Group("/api/v1/data", Authenticate)
+	data.POST("/shorten", RequireScope(models.ScopeCreate), Idempotent, GenerateShortenedUrl)
+	data.GET("/links", RequireScope(models.ScopeRead), ListUrls)
+	data.POST("/tags/merge", RequireScope(models.ScopeUpdate), MergeTags)
+	data.PATCH("/:shortUrl", RequireScope(models.ScopeUpdate), UpdateShortenedUrl)
+	data.DELETE("/:shortUrl", RequireScope(models.ScopeDelete), DeleteShortenedUrl)
+	admin := router.Group("/api/v1/admin", Authenticate, RequireScope(models.ScopeAdmin))
+	admin.POST("/api-keys", CreateApiKey)
+	admin.GET("/api-keys", ListApiKeys)
+	admin.DELETE("/api-keys/:id", RevokeApiKey)
+
+	return keys, router
+}
+
+func TestAuthenticate(t *testing.T) {
+	tests := []struct {
+		name           string
+		required       bool
+		header         string
+		key            string
+		method         string
+		path           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Missing key",
+			required:       true,
+			method:         http.MethodPost,
+			path:           "/api/v1/data/shorten",
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `{"code":401,"message":"api key or bearer token is missing"}`,
+		},
+		{
+			name:           "Invalid key",
+			required:       true,
+			header:         constants.ApiKeyHeader,
+			key:            "3f9c1a7e5b2d4c60.guessed",
+			method:         http.MethodPost,
+			path:           "/api/v1/data/shorten",
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `{"code":401,"message":"api key is invalid"}`,
+		},
+		{
+			name:           "Revoked key",
+			required:       true,
+			header:         constants.ApiKeyHeader,
+			key:            "revoked",
+			method:         http.MethodPost,
+			path:           "/api/v1/data/shorten",
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `{"code":401,"message":"api key has been revoked"}`,
+		},
+		{
+			name:           "Scope granted",
+			required:       true,
+			header:         constants.ApiKeyHeader,
+			key:            "creator",
+			method:         http.MethodPost,
+			path:           "/api/v1/data/shorten",
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:           "Bearer token",
+			required:       true,
+			header:         "Authorization",
+			key:            "reader",
+			method:         http.MethodGet,
+			path:           "/api/v1/data/links",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Scope missing",
+			required:       true,
+			header:         constants.ApiKeyHeader,
+			key:            "reader",
+			method:         http.MethodPost,
+			path:           "/api/v1/data/shorten",
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `{"code":403,"message":"request is not granted the create scope"}`,
+		},
+		{
+			name:           "Admin grants every scope",
+			required:       true,
+			header:         constants.ApiKeyHeader,
+			key:            "admin",
+			method:         http.MethodPost,
+			path:           "/api/v1/data/shorten",
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:           "Admin scope missing",
+			required:       true,
+			header:         constants.ApiKeyHeader,
+			key:            "creator",
+			method:         http.MethodGet,
+			path:           "/api/v1/admin/api-keys",
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "Anonymous when not required",
+			method:         http.MethodPost,
+			path:           "/api/v1/data/shorten",
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:           "Invalid key when not required",
+			header:         constants.ApiKeyHeader,
+			key:            "3f9c1a7e5b2d4c60.guessed",
+			method:         http.MethodPost,
+			path:           "/api/v1/data/shorten",
+			expectedStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		keys, router := enterAuthTest(t, tt.required)
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(tt.method, tt.path, bytes.NewBufferString(`{"longUrl":"https://www.youtube.com"}`))
+		req.Header.Set("Content-Type", "application/json")
+		key, named := keys[tt.key]
+		if !named {
+			key = tt.key
+		}
+		switch tt.header {
+		case "Authorization":
+			req.Header.Set("Authorization", "Bearer "+key)
+		case constants.ApiKeyHeader:
+			req.Header.Set(constants.ApiKeyHeader, key)
+		}
+
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, tt.expectedStatus, rec.Code, tt.name)
+		if tt.expectedBody != "" {
+			assert.JSONEq(t, tt.expectedBody, rec.Body.String(), tt.name)
+		}
+		if tt.expectedStatus == http.StatusUnauthorized {
+			assert.NotEmpty(t, rec.Header().Get("WWW-Authenticate"), tt.name)
+		}
+	}
+}
+
+func TestAuthenticateStampsOwner(t *testing.T) {
+	keys, router := enterAuthTest(t, true)
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/data/shorten", bytes.NewBufferString(`{"longUrl":"https://www.youtube.com"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(constants.ApiKeyHeader, keys["creator"])
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	var created models.Url
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+	url, err := repository.Client.RetrieveUrl(context.Background(), created.ShortUrl)
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", url.Owner)
+}
+
+func TestApiKeys(t *testing.T) {
+	keys, router := enterAuthTest(t, true)
+	send := func(method string, path string, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(constants.ApiKeyHeader, keys["admin"])
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := send(http.MethodPost, "/api/v1/admin/api-keys", `{"owner":"carol","scopes":["read","delete"],"name":"cli"}`)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "SecretHash")
+	var created models.CreatedApiKey
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+	assert.Equal(t, "carol", created.Owner)
+	assert.Equal(t, []string{models.ScopeRead, models.ScopeDelete}, created.Scopes)
+
+	rec = send(http.MethodPost, "/api/v1/admin/api-keys", `{"owner":"carol","scopes":["write"]}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), `unknown scope \"write\"`)
+
+	rec = send(http.MethodGet, "/api/v1/admin/api-keys?owner=carol", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var listed []models.ApiKey
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &listed))
+	assert.Len(t, listed, 1)
+	assert.Equal(t, created.Id, listed[0].Id)
+
+	rec = send(http.MethodDelete, "/api/v1/admin/api-keys/"+created.Id, "")
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	rec = send(http.MethodDelete, "/api/v1/admin/api-keys/"+created.Id, "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	_, err := auth.AuthenticateApiKey(context.Background(), repository.ApiKeys, created.Key)
+	assert.ErrorIs(t, err, auth.ErrApiKeyRevoked)
+}
@@ -0,0 +1,34 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kjj1998/url-shortener-go/internal/models"
+	"github.com/kjj1998/url-shortener-go/internal/repository"
+	"github.com/kjj1998/url-shortener-go/internal/utils"
+)
+
+// CacheStats godoc
+// @Summary redirect cache statistics
+// @Schemes
+// @Description hit and miss counters of the redirect cache, negative cache and Bloom filter
+// @Tags cache
+// @Produce json
+// @Success 200 {object} models.CacheStats
+// @Failure 401 {object} utils.HTTPError
+// @Failure 403 {object} utils.HTTPError
+// @Failure 404 {object} utils.HTTPError
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /data/cache/stats [get]
+func CacheStats(g *gin.Context) {
+	cachedClient, ok := repository.Client.(interface{ Stats() models.CacheStats })
+	if !ok {
+		utils.NewError(g, http.StatusNotFound, errors.New("redirect cache is disabled"))
+		return
+	}
+
+	g.IndentedJSON(http.StatusOK, cachedClient.Stats())
+}
@@ -0,0 +1,30 @@
+package routes
+
+import (
+	"context"
+	"log"
+
+	"github.com/kjj1998/url-shortener-go/constants"
+	"github.com/kjj1998/url-shortener-go/internal/config"
+	"github.com/kjj1998/url-shortener-go/internal/policy"
+)
+
+// domainPolicy decides which destination domains may be shortened and redirected to, all of them unless
+// DOMAIN_POLICY_FILE is set
+var domainPolicy = loadDomainPolicy()
+
+func loadDomainPolicy() *policy.DomainPolicy {
+	path := config.String("DOMAIN_POLICY_FILE", "")
+	if path == "" {
+		return nil
+	}
+
+	domainPolicy, err := policy.LoadDomainPolicy(path)
+	if err != nil {
+		log.Fatalf("Unable to load domain policy, %v", err)
+	}
+	if interval := config.Duration("DOMAIN_POLICY_RELOAD", constants.DomainPolicyReload); interval > 0 {
+		go domainPolicy.Watch(context.Background(), interval)
+	}
+	return domainPolicy
+}
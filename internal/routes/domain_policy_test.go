@@ -0,0 +1,86 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kjj1998/url-shortener-go/internal/models"
+	"github.com/kjj1998/url-shortener-go/internal/policy"
+	"github.com/kjj1998/url-shortener-go/internal/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+func enterDomainPolicyTest(t *testing.T, rules string) {
+	path := filepath.Join(t.TempDir(), "domains.txt")
+	assert.NoError(t, os.WriteFile(path, []byte(rules), 0o644))
+
+	var err error
+	domainPolicy, err = policy.LoadDomainPolicy(path)
+	assert.NoError(t, err)
+	t.Cleanup(func() { domainPolicy = nil })
+}
+
+func TestGenerateShortenedUrlDomainPolicy(t *testing.T) {
+	enterDomainPolicyTest(t, "block *.zip\nallow docs.zip\n")
+	repository.Client = repository.NewMemoryClient()
+
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Blocked domain",
+			body:           `{"longUrl":"https://Login.Archive.ZIP/account"}`,
+			expectedStatus: http.StatusForbidden,
+			expectedBody: `{"code":403,"errorCode":"domain_blocked",` +
+				`"message":"login.archive.zip is blocked by rule \"block *.zip\" on line 1 of the domain policy"}`,
+		},
+		{
+			name:           "Allowed domain",
+			body:           `{"longUrl":"https://docs.zip/guide"}`,
+			expectedStatus: http.StatusCreated,
+		},
+	}
+
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(rec)
+		ctx.Request = httptest.NewRequest(http.MethodPost, "/shorten", bytes.NewBufferString(tt.body))
+		ctx.Request.Header.Set("Content-Type", "application/json")
+
+		GenerateShortenedUrl(ctx)
+
+		assert.Equal(t, tt.expectedStatus, rec.Code, tt.name)
+		if tt.expectedBody != "" {
+			assert.JSONEq(t, tt.expectedBody, rec.Body.String(), tt.name)
+		}
+	}
+}
+
+func TestRedirectShortenedUrlDomainPolicy(t *testing.T) {
+	enterDomainPolicyTest(t, "block evil.com\n")
+	repository.Client = repository.NewMemoryClient()
+	// Created before the domain was blocked
+	url := models.Url{Id: 1, ShortUrl: "NEDF34qw", LongUrl: "https://www.evil.com/login", MaxClicks: 5}
+	assert.NoError(t, repository.Client.AddUrl(context.Background(), url))
+
+	rec := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(rec)
+	ctx.Request = httptest.NewRequest(http.MethodGet, "/api/v1/NEDF34qw", nil)
+	ctx.Params = gin.Params{{Key: "shortUrl", Value: "NEDF34qw"}}
+
+	RedirectShortenedUrl(ctx)
+
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"errorCode":"domain_blocked"`)
+	url, _ = repository.Client.RetrieveUrl(context.Background(), "NEDF34qw")
+	assert.Zero(t, url.Clicks, "blocked redirects are not counted")
+}
@@ -0,0 +1,180 @@
+package routes
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kjj1998/url-shortener-go/constants"
+	"github.com/kjj1998/url-shortener-go/internal/config"
+	"github.com/kjj1998/url-shortener-go/internal/repository"
+	"github.com/kjj1998/url-shortener-go/internal/utils"
+)
+
+var (
+	ErrIdempotencyKeyLength   = fmt.Errorf("idempotency key must be at most %d characters long", constants.IdempotencyKeyMaxLength)
+	ErrIdempotencyKeyReused   = errors.New("idempotency key was already used for a different request")
+	ErrIdempotencyKeyInFlight = errors.New("a request with the same idempotency key is still being processed")
+)
+
+// idempotentResponses holds the responses to requests with an Idempotency-Key in the memory of each instance of the
+// service, so a request repeated to another instance is handled again
+var idempotentResponses = newIdempotencyStore(config.Duration("IDEMPOTENCY_WINDOW", constants.IdempotencyWindow),
+	config.Int("IDEMPOTENCY_CACHE_SIZE", constants.IdempotencyCacheSize))
+
+// Idempotent is the middleware answering repeats of a request with the same Idempotency-Key header, made by the same
+// owner within the idempotency window, with the response to the first request instead of handling them again.
+// Responses with a server error are not kept, so the request can be retried. Reusing a key for a different request
+// is refused with 422, and repeating a request that is still being handled with 409
+func Idempotent(g *gin.Context) {
+	key := g.GetHeader(constants.IdempotencyKeyHeader)
+	if key == "" {
+		return
+	}
+	if len(key) > constants.IdempotencyKeyMaxLength {
+		utils.NewError(g, http.StatusBadRequest, ErrIdempotencyKeyLength)
+		g.Abort()
+		return
+	}
+
+	body, err := io.ReadAll(g.Request.Body)
+	if err != nil {
+		utils.NewError(g, http.StatusBadRequest, err)
+		g.Abort()
+		return
+	}
+	g.Request.Body = io.NopCloser(bytes.NewReader(body))
+	fingerprint := sha256.Sum256(append([]byte(g.Request.Method+" "+g.Request.URL.Path+" "+g.ContentType()+"\n"), body...))
+
+	key = g.GetString(constants.OwnerContextKey) + "\x00" + key
+	response, err := idempotentResponses.begin(key, fingerprint)
+	switch {
+	case errors.Is(err, ErrIdempotencyKeyReused):
+		utils.NewError(g, http.StatusUnprocessableEntity, err)
+		g.Abort()
+		return
+	case errors.Is(err, ErrIdempotencyKeyInFlight):
+		utils.NewError(g, http.StatusConflict, err)
+		g.Abort()
+		return
+	case response != nil:
+		g.Header(constants.IdempotentReplayedHeader, "true")
+		g.Data(response.status, response.contentType, response.body)
+		g.Abort()
+		return
+	}
+
+	recorder := &responseRecorder{ResponseWriter: g.Writer}
+	g.Writer = recorder
+	completed := false
+	// A handler that panics leaves no response to keep
+	defer func() {
+		if !completed {
+			idempotentResponses.abandon(key)
+		}
+	}()
+
+	g.Next()
+
+	if recorder.Status() >= http.StatusInternalServerError {
+		idempotentResponses.abandon(key)
+	} else {
+		idempotentResponses.complete(key, recorder.Status(), recorder.Header().Get("Content-Type"), recorder.body.Bytes())
+	}
+	completed = true
+}
+
+// responseRecorder keeps a copy of the body written to the response
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (recorder *responseRecorder) Write(data []byte) (int, error) {
+	recorder.body.Write(data)
+	return recorder.ResponseWriter.Write(data)
+}
+
+func (recorder *responseRecorder) WriteString(s string) (int, error) {
+	recorder.body.WriteString(s)
+	return recorder.ResponseWriter.WriteString(s)
+}
+
+// idempotencyStore keeps the response to each request with an idempotency key for a window starting at the request.
+// It holds at most a number of keys, forgetting the least recently used ones first, so clients sending new keys do
+// not grow it without bounds
+type idempotencyStore struct {
+	mu        sync.Mutex
+	window    time.Duration
+	responses *repository.LruCache[*idempotentResponse]
+	now       func() time.Time
+}
+
+// idempotentResponse is the response to a request with an idempotency key, which is not completed while the request
+// is being handled
+type idempotentResponse struct {
+	fingerprint [sha256.Size]byte
+	completed   bool
+	status      int
+	contentType string
+	body        []byte
+	expiresAt   time.Time
+}
+
+func newIdempotencyStore(window time.Duration, size int) *idempotencyStore {
+	return &idempotencyStore{
+		window:    window,
+		responses: repository.NewLruCache[*idempotentResponse](size, window),
+		now:       time.Now,
+	}
+}
+
+// begin returns the completed response to the request made with key, or nil when the request is new and is to be
+// handled, in which case the key is held until the response is completed or abandoned
+// Returns ErrIdempotencyKeyReused when key was used for a request with another fingerprint, and
+// ErrIdempotencyKeyInFlight when the request is still being handled
+func (store *idempotencyStore) begin(key string, fingerprint [sha256.Size]byte) (*idempotentResponse, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	now := store.now()
+	response, ok := store.responses.Get(key)
+	switch {
+	case ok && now.Before(response.expiresAt) && response.fingerprint != fingerprint:
+		return nil, ErrIdempotencyKeyReused
+	case ok && now.Before(response.expiresAt) && !response.completed:
+		return nil, ErrIdempotencyKeyInFlight
+	case ok && now.Before(response.expiresAt):
+		return response, nil
+	}
+
+	store.responses.Set(key, &idempotentResponse{fingerprint: fingerprint, expiresAt: now.Add(store.window)})
+	return nil, nil
+}
+
+// complete keeps the response to the request begun with key
+func (store *idempotencyStore) complete(key string, status int, contentType string, body []byte) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if response, ok := store.responses.Get(key); ok {
+		response.completed = true
+		response.status = status
+		response.contentType = contentType
+		response.body = bytes.Clone(body)
+	}
+}
+
+// abandon releases key without keeping a response, so the request can be made again
+func (store *idempotencyStore) abandon(key string) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	store.responses.Remove(key)
+}
@@ -0,0 +1,136 @@
+package routes
+
+import (
+	"crypto/sha256"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kjj1998/url-shortener-go/constants"
+	"github.com/stretchr/testify/assert"
+)
+
+// enterIdempotencyTest routes POST /shorten through Idempotent to a handler answering with the number of requests
+// it has handled, failing the ones with a body of "fail"
+func enterIdempotencyTest(t *testing.T) (*gin.Engine, *int) {
+	original := idempotentResponses
+	idempotentResponses = newIdempotencyStore(time.Hour, 100)
+	t.Cleanup(func() { idempotentResponses = original })
+
+	handled := 0
+	router := gin.New()
+	router.POST("/shorten", Idempotent, func(g *gin.Context) {
+		handled++
+		body, _ := io.ReadAll(g.Request.Body)
+		if string(body) == "fail" {
+			g.JSON(http.StatusInternalServerError, gin.H{"handled": handled})
+			return
+		}
+		g.JSON(http.StatusCreated, gin.H{"handled": handled})
+	})
+	return router, &handled
+}
+
+func idempotentRequest(router *gin.Engine, key string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/shorten", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set(constants.IdempotencyKeyHeader, key)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestIdempotent(t *testing.T) {
+	router, handled := enterIdempotencyTest(t)
+
+	first := idempotentRequest(router, "key-1", `{"longUrl":"http://example.com"}`)
+	assert.Equal(t, http.StatusCreated, first.Code)
+	assert.JSONEq(t, `{"handled":1}`, first.Body.String())
+	assert.Empty(t, first.Header().Get(constants.IdempotentReplayedHeader))
+
+	repeat := idempotentRequest(router, "key-1", `{"longUrl":"http://example.com"}`)
+	assert.Equal(t, http.StatusCreated, repeat.Code)
+	assert.JSONEq(t, `{"handled":1}`, repeat.Body.String())
+	assert.Equal(t, "true", repeat.Header().Get(constants.IdempotentReplayedHeader))
+	assert.Equal(t, first.Header().Get("Content-Type"), repeat.Header().Get("Content-Type"))
+
+	reused := idempotentRequest(router, "key-1", `{"longUrl":"http://example.org"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, reused.Code)
+	assert.JSONEq(t, `{"code":422, "message":"idempotency key was already used for a different request"}`, reused.Body.String())
+
+	other := idempotentRequest(router, "key-2", `{"longUrl":"http://example.com"}`)
+	assert.JSONEq(t, `{"handled":2}`, other.Body.String())
+
+	withoutKey := idempotentRequest(router, "", `{"longUrl":"http://example.com"}`)
+	assert.JSONEq(t, `{"handled":3}`, withoutKey.Body.String())
+
+	tooLong := idempotentRequest(router, strings.Repeat("k", constants.IdempotencyKeyMaxLength+1), `{}`)
+	assert.Equal(t, http.StatusBadRequest, tooLong.Code)
+
+	assert.Equal(t, 3, *handled)
+}
+
+func TestIdempotentServerError(t *testing.T) {
+	router, handled := enterIdempotencyTest(t)
+
+	failed := idempotentRequest(router, "key-1", "fail")
+	assert.Equal(t, http.StatusInternalServerError, failed.Code)
+
+	retried := idempotentRequest(router, "key-1", "fail")
+	assert.Equal(t, http.StatusInternalServerError, retried.Code)
+	assert.Empty(t, retried.Header().Get(constants.IdempotentReplayedHeader))
+	assert.Equal(t, 2, *handled, "server errors are not replayed")
+}
+
+func TestIdempotencyStore(t *testing.T) {
+	store := newIdempotencyStore(time.Hour, 100)
+	now := time.Now()
+	store.now = func() time.Time { return now }
+	fingerprint := sha256.Sum256([]byte("request"))
+
+	response, err := store.begin("key", fingerprint)
+	assert.NoError(t, err)
+	assert.Nil(t, response)
+
+	_, err = store.begin("key", fingerprint)
+	assert.ErrorIs(t, err, ErrIdempotencyKeyInFlight)
+
+	store.complete("key", http.StatusCreated, "application/json", []byte(`{}`))
+	response, err = store.begin("key", fingerprint)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, response.status)
+
+	now = now.Add(time.Hour)
+	response, err = store.begin("key", sha256.Sum256([]byte("another request")))
+	assert.NoError(t, err)
+	assert.Nil(t, response, "keys are forgotten after the window")
+
+	store.abandon("key")
+	response, err = store.begin("key", fingerprint)
+	assert.NoError(t, err)
+	assert.Nil(t, response)
+}
+
+func TestIdempotencyStore_Size(t *testing.T) {
+	store := newIdempotencyStore(time.Hour, 2)
+	fingerprint := sha256.Sum256([]byte("request"))
+
+	for _, key := range []string{"key-1", "key-2", "key-3"} {
+		_, err := store.begin(key, fingerprint)
+		assert.NoError(t, err)
+		store.complete(key, http.StatusCreated, "application/json", []byte(`{}`))
+	}
+
+	response, err := store.begin("key-1", fingerprint)
+	assert.NoError(t, err)
+	assert.Nil(t, response, "the least recently used key is forgotten once the store is full")
+	response, err = store.begin("key-3", fingerprint)
+	assert.NoError(t, err)
+	assert.NotNil(t, response)
+}
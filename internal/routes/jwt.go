@@ -0,0 +1,71 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kjj1998/url-shortener-go/constants"
+	"github.com/kjj1998/url-shortener-go/internal/auth"
+	"github.com/kjj1998/url-shortener-go/internal/config"
+	"github.com/kjj1998/url-shortener-go/internal/models"
+	"github.com/kjj1998/url-shortener-go/internal/utils"
+)
+
+var (
+	// tokenVerifier verifies the JWTs sent as bearer tokens, which are not accepted when it is nil
+	tokenVerifier = configuredTokenVerifier()
+	// adminRole grants the admin scope to the users holding it
+	adminRole = config.String("JWT_ADMIN_ROLE", constants.JwtAdminRole)
+	// userRole is the role users must hold to manage their shortened URLs, any user may when it is empty
+	userRole = config.String("JWT_USER_ROLE", "")
+)
+
+func configuredTokenVerifier() *auth.TokenVerifier {
+	location := config.String("JWT_JWKS", "")
+	if location == "" {
+		return nil
+	}
+
+	return &auth.TokenVerifier{
+		Keys:       auth.NewJWKS(location, config.Duration("JWKS_REFRESH", constants.JwksRefreshInterval)),
+		Issuer:     config.String("JWT_ISSUER", ""),
+		Audience:   config.String("JWT_AUDIENCE", ""),
+		UserClaim:  config.String("JWT_USER_CLAIM", constants.JwtUserClaim),
+		RolesClaim: config.String("JWT_ROLES_CLAIM", constants.JwtRolesClaim),
+		Leeway:     config.Duration("JWT_LEEWAY", constants.JwtLeeway),
+	}
+}
+
+// authenticateToken verifies the JWT sent with a request, keeping the user it was issued to as the owner of the
+// request and the scopes of their roles in the gin context
+func authenticateToken(g *gin.Context, token string) {
+	identity, err := tokenVerifier.Verify(context.TODO(), token)
+	switch {
+	case errors.Is(err, auth.ErrTokenInvalid), errors.Is(err, auth.ErrTokenExpired):
+		unauthorized(g, err)
+		return
+	case err != nil:
+		utils.NewError(g, http.StatusInternalServerError, err)
+		g.Abort()
+		return
+	}
+
+	g.Set(constants.OwnerContextKey, identity.Subject)
+	g.Set(constants.ScopesContextKey, roleScopes(identity.Roles))
+}
+
+// roleScopes maps the roles of a user to their scopes. Admins get the admin scope, other users the scopes to manage
+// their own shortened URLs when they hold the user role, if one is set
+func roleScopes(roles []string) []string {
+	switch {
+	case slices.Contains(roles, adminRole):
+		return []string{models.ScopeAdmin}
+	case userRole == "" || slices.Contains(roles, userRole):
+		return []string{models.ScopeCreate, models.ScopeRead, models.ScopeUpdate, models.ScopeDelete}
+	default:
+		return []string{}
+	}
+}
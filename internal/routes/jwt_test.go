@@ -0,0 +1,243 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/kjj1998/url-shortener-go/constants"
+	"github.com/kjj1998/url-shortener-go/internal/auth"
+	"github.com/kjj1998/url-shortener-go/internal/models"
+	"github.com/kjj1998/url-shortener-go/internal/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+// enterJwtTest verifies bearer tokens against the test JWKS of the auth package, with the user role required, and
+// returns a function signing tokens for a subject with roles, expiring after ttl
+func enterJwtTest(t *testing.T) func(subject string, roles []string, ttl time.Duration) string {
+	tokenVerifier = &auth.TokenVerifier{
+		Keys:       auth.NewJWKS("../auth/testdata/jwks.json", time.Minute),
+		Issuer:     "https://idp.example.com",
+		Audience:   "url-shortener",
+		UserClaim:  "sub",
+		RolesClaim: "roles",
+	}
+	userRole = "user"
+	t.Cleanup(func() {
+		tokenVerifier = configuredTokenVerifier()
+		userRole = ""
+	})
+
+	data, err := os.ReadFile("../auth/testdata/rsa.pem")
+	assert.NoError(t, err)
+	block, _ := pem.Decode(data)
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	assert.NoError(t, err)
+	key := parsed.(*rsa.PrivateKey)
+
+	return func(subject string, roles []string, ttl time.Duration) string {
+		header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","kid":"test-rsa","typ":"JWT"}`))
+		claims, _ := json.Marshal(map[string]any{
+			"iss":   "https://idp.example.com",
+			"aud":   "url-shortener",
+			"sub":   subject,
+			"roles": roles,
+			"exp":   time.Now().Add(ttl).Unix(),
+		})
+		signed := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+		digest := crypto.SHA256.New()
+		digest.Write([]byte(signed))
+		signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest.Sum(nil))
+		assert.NoError(t, err)
+		return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+	}
+}
+
+func TestAuthenticateBearerToken(t *testing.T) {
+	keys, router := enterAuthTest(t, true)
+	sign := enterJwtTest(t)
+
+	tests := []struct {
+		name           string
+		token          string
+		expectedStatus int
+		expectedOwner  string
+	}{
+		{
+			name:           "User",
+			token:          sign("alice", []string{"user"}, time.Hour),
+			expectedStatus: http.StatusCreated,
+			expectedOwner:  "alice",
+		},
+		{
+			name:           "Admin",
+			token:          sign("ops", []string{"admin"}, time.Hour),
+			expectedStatus: http.StatusCreated,
+			expectedOwner:  "ops",
+		},
+		{
+			name:           "Without the user role",
+			token:          sign("mallory", []string{"guest"}, time.Hour),
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "Expired",
+			token:          sign("alice", []string{"user"}, -time.Hour),
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Api key as a bearer token",
+			token:          keys["creator"],
+			expectedStatus: http.StatusCreated,
+			expectedOwner:  "alice",
+		},
+	}
+
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/data/shorten", bytes.NewBufferString(`{"longUrl":"https://www.youtube.com"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+tt.token)
+
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, tt.expectedStatus, rec.Code, tt.name)
+		if tt.expectedOwner != "" {
+			var created models.Url
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+			url, _ := repository.Client.RetrieveUrl(context.Background(), created.ShortUrl)
+			assert.Equal(t, tt.expectedOwner, url.Owner, tt.name)
+		}
+	}
+}
+
+func TestAuthorizeOwner(t *testing.T) {
+	keys, router := enterAuthTest(t, true)
+	sign := enterJwtTest(t)
+	for i, url := range []models.Url{
+		{ShortUrl: "alice01", LongUrl: "https://example.com/a", Owner: "alice", Tags: []string{"golang"}},
+		{ShortUrl: "alice02", LongUrl: "https://example.com/b", Owner: "alice"},
+		{ShortUrl: "bob0001", LongUrl: "https://example.com/c", Owner: "bob", Tags: []string{"golang"}},
+	} {
+		url.Id = uint64(i + 1)
+		assert.NoError(t, repository.Client.AddUrl(context.Background(), url))
+	}
+
+	tests := []struct {
+		name           string
+		token          string
+		apiKey         string
+		method         string
+		path           string
+		body           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Update own link",
+			token:          sign("alice", []string{"user"}, time.Hour),
+			method:         http.MethodPatch,
+			path:           "/api/v1/data/alice01",
+			body:           `{"title":"Mine"}`,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Update link of another owner",
+			token:          sign("alice", []string{"user"}, time.Hour),
+			method:         http.MethodPatch,
+			path:           "/api/v1/data/bob0001",
+			body:           `{"title":"Mine"}`,
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `{"code":403,"message":"shortened url belongs to another owner"}`,
+		},
+		{
+			name:           "Delete link of another owner with an api key",
+			apiKey:         keys["editor"],
+			method:         http.MethodDelete,
+			path:           "/api/v1/data/bob0001",
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "Delete missing link",
+			token:          sign("alice", []string{"user"}, time.Hour),
+			method:         http.MethodDelete,
+			path:           "/api/v1/data/missing",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "Merge tags of own links",
+			token:          sign("alice", []string{"user"}, time.Hour),
+			method:         http.MethodPost,
+			path:           "/api/v1/data/tags/merge",
+			body:           `{"from":["golang"],"to":"go"}`,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"links":1}`,
+		},
+		{
+			name:           "Admin deletes link of another owner",
+			token:          sign("ops", []string{"admin"}, time.Hour),
+			method:         http.MethodDelete,
+			path:           "/api/v1/data/alice02",
+			expectedStatus: http.StatusNoContent,
+		},
+	}
+
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(tt.method, tt.path, bytes.NewBufferString(tt.body))
+		req.Header.Set("Content-Type", "application/json")
+		if tt.apiKey != "" {
+			req.Header.Set(constants.ApiKeyHeader, tt.apiKey)
+		} else {
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, tt.expectedStatus, rec.Code, tt.name)
+		if tt.expectedBody != "" {
+			assert.JSONEq(t, tt.expectedBody, rec.Body.String(), tt.name)
+		}
+	}
+
+	for _, tt := range []struct {
+		name          string
+		token         string
+		query         string
+		expectedLinks []string
+	}{
+		{name: "User lists own links", token: sign("alice", []string{"user"}, time.Hour), expectedLinks: []string{"alice01"}},
+		{name: "User asks for links of another owner", token: sign("alice", []string{"user"}, time.Hour), query: "?owner=bob", expectedLinks: []string{"alice01"}},
+		{name: "Admin lists links of another owner", token: sign("ops", []string{"admin"}, time.Hour), query: "?owner=bob", expectedLinks: []string{"bob0001"}},
+	} {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/data/links"+tt.query, nil)
+		req.Header.Set("Authorization", "Bearer "+tt.token)
+
+		router.ServeHTTP(rec, req)
+
+		var page models.UrlPage
+		assert.Equal(t, http.StatusOK, rec.Code, tt.name)
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page), tt.name)
+		var links []string
+		for _, url := range page.Links {
+			links = append(links, url.ShortUrl)
+		}
+		assert.Equal(t, tt.expectedLinks, links, tt.name)
+	}
+
+	url, _ := repository.Client.RetrieveUrl(context.Background(), "bob0001")
+	assert.Equal(t, []string{"golang"}, url.Tags, "links of other owners keep their tags")
+	assert.Empty(t, url.Title)
+}
@@ -0,0 +1,74 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kjj1998/url-shortener-go/constants"
+	"github.com/kjj1998/url-shortener-go/internal/models"
+	"github.com/kjj1998/url-shortener-go/internal/repository"
+	"github.com/kjj1998/url-shortener-go/internal/utils"
+)
+
+var ErrLimitInvalid = fmt.Errorf("limit must be between 1 and %d", constants.ListUrlsMaxLimit)
+
+// listUrlsQuery is the query string of ListUrls
+type listUrlsQuery struct {
+	models.UrlFilter
+	Cursor string `form:"cursor"`
+	Limit  int    `form:"limit"`
+}
+
+// ListUrls godoc
+// @Summary list shortened urls
+// @Schemes
+// @Description list shortened urls a page at a time, pass the nextCursor of a page as cursor to get the next one
+// @Tags manage
+// @Produce json
+// @Param createdAfter query string false "Only urls created at or after this time, RFC 3339"
+// @Param createdBefore query string false "Only urls created before this time, RFC 3339"
+// @Param domain query string false "Only urls whose destination has this host"
+// @Param owner query string false "Only urls of this owner, always the caller unless they are an admin"
+// @Param q query string false "Only urls whose destination or short url contains this"
+// @Param tag query string false "Only urls with this tag"
+// @Param cursor query string false "Cursor of the page to get"
+// @Param limit query int false "Number of urls per page, at most 100" default(50)
+// @Success 200 {object} models.UrlPage
+// @Failure 400 {object} utils.HTTPError
+// @Failure 401 {object} utils.HTTPError
+// @Failure 403 {object} utils.HTTPError
+// @Failure 500 {object} utils.HTTPError
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /data/links [get]
+func ListUrls(g *gin.Context) {
+	query := listUrlsQuery{Limit: constants.ListUrlsLimit}
+	if err := g.ShouldBindQuery(&query); err != nil {
+		utils.NewError(g, http.StatusBadRequest, err)
+		return
+	}
+	if query.Limit < 1 || query.Limit > constants.ListUrlsMaxLimit {
+		utils.NewError(g, http.StatusBadRequest, ErrLimitInvalid)
+		return
+	}
+	// Only admins list the links of other owners, the owner filter of the others is always their own
+	if owner, restricted := restrictedOwner(g); restricted {
+		query.Owner = owner
+	}
+
+	page, err := repository.Client.ListUrls(context.TODO(), query.UrlFilter, query.Cursor, query.Limit)
+
+	if errors.Is(err, repository.ErrCursorInvalid) {
+		utils.NewError(g, http.StatusBadRequest, err)
+		return
+	}
+	if err != nil {
+		utils.NewError(g, http.StatusInternalServerError, err)
+		return
+	}
+
+	g.IndentedJSON(http.StatusOK, page)
+}
@@ -0,0 +1,101 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kjj1998/url-shortener-go/internal/models"
+	"github.com/kjj1998/url-shortener-go/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestListUrls(t *testing.T) {
+	createdAfter := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	page := models.UrlPage{
+		Links:      []models.Url{{Id: 2387497, ShortUrl: "NWER425d", LongUrl: "http://example.com"}},
+		NextCursor: "eyJTaG9ydFVybCI6Ik5XRVI0MjVkIn0",
+	}
+
+	tests := []struct {
+		name           string
+		query          string
+		mockFilter     *models.UrlFilter
+		mockCursor     string
+		mockLimit      int
+		mockRepoError  error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Valid request",
+			query:          "",
+			mockFilter:     &models.UrlFilter{},
+			mockLimit:      50,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"links":[{"id":2387497,"shortUrl":"NWER425d","longUrl":"http://example.com"}],"nextCursor":"eyJTaG9ydFVybCI6Ik5XRVI0MjVkIn0"}`,
+		},
+		{
+			name:           "Filters",
+			query:          "createdAfter=2025-01-01T00:00:00Z&domain=example.com&owner=alice&q=exam&tag=news&cursor=abc&limit=10",
+			mockFilter:     &models.UrlFilter{CreatedAfter: &createdAfter, Domain: "example.com", Owner: "alice", Search: "exam", Tag: "news"},
+			mockCursor:     "abc",
+			mockLimit:      10,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"links":[{"id":2387497,"shortUrl":"NWER425d","longUrl":"http://example.com"}],"nextCursor":"eyJTaG9ydFVybCI6Ik5XRVI0MjVkIn0"}`,
+		},
+		{
+			name:           "Invalid cursor",
+			query:          "cursor=abc",
+			mockFilter:     &models.UrlFilter{},
+			mockCursor:     "abc",
+			mockLimit:      50,
+			mockRepoError:  repository.ErrCursorInvalid,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"code":400, "message":"invalid cursor"}`,
+		},
+		{
+			name:           "Internal error",
+			query:          "",
+			mockFilter:     &models.UrlFilter{},
+			mockLimit:      50,
+			mockRepoError:  errors.New("simulated DynamoDB error"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"code":500, "message":"simulated DynamoDB error"}`,
+		},
+		{
+			name:           "Limit too large",
+			query:          "limit=101",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"code":400, "message":"limit must be between 1 and 100"}`,
+		},
+		{
+			name:           "Invalid time",
+			query:          "createdBefore=yesterday",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"code":400, "message":"parsing time \"yesterday\" as \"2006-01-02T15:04:05Z07:00\": cannot parse \"yesterday\" as \"2006\""}`,
+		},
+	}
+
+	for _, tt := range tests {
+		mockRepo := new(MockUrlRepository)
+		repository.Client = mockRepo
+		if tt.mockFilter != nil {
+			mockRepo.On("ListUrls", mock.Anything, *tt.mockFilter, tt.mockCursor, tt.mockLimit).Return(page, tt.mockRepoError).Once()
+		}
+
+		rec := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(rec)
+		ctx.Request, _ = http.NewRequest(http.MethodGet, "/api/v1/data/links?"+tt.query, nil)
+
+		ListUrls(ctx)
+
+		assert.Equal(t, tt.expectedStatus, rec.Code, tt.name)
+		assert.JSONEq(t, tt.expectedBody, rec.Body.String(), tt.name)
+		mockRepo.AssertExpectations(t)
+	}
+}
@@ -0,0 +1,128 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kjj1998/url-shortener-go/internal/models"
+	"github.com/kjj1998/url-shortener-go/internal/repository"
+	"github.com/kjj1998/url-shortener-go/internal/utils"
+)
+
+// UpdateShortenedUrl godoc
+// @Summary update shortened urls
+// @Schemes
+// @Description change the destination, expiry, click limit, password, title, description, notes or tags of a shortened url.
+// @Description Only admins may change the shortened urls of other owners
+// @Tags manage
+// @Accept json
+// @Produce json
+// @Param shortUrl path string true "Short URL"
+// @Param changes body models.UrlUpdate true "Changes to the shortened URL"
+// @Success 200 {object} models.Url
+// @Failure 400 {object} utils.HTTPError
+// @Failure 401 {object} utils.HTTPError
+// @Failure 403 {object} utils.HTTPError
+// @Failure 404 {object} utils.HTTPError
+// @Failure 500 {object} utils.HTTPError
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /data/{shortUrl} [patch]
+func UpdateShortenedUrl(g *gin.Context) {
+	var update models.UrlUpdate
+	if err := g.ShouldBindJSON(&update); err != nil {
+		utils.NewError(g, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := update.Validation(); err != nil {
+		utils.NewError(g, http.StatusBadRequest, err)
+		return
+	}
+
+	if !authorizeOwner(g, g.Param("shortUrl")) {
+		return
+	}
+
+	if update.LongUrl != nil {
+		canonical, err := canonicalLongUrl(context.TODO(), *update.LongUrl)
+		if err != nil {
+			utils.NewError(g, http.StatusBadRequest, err)
+			return
+		}
+		if canonical, err = resolveSelfReference(context.TODO(), g.Param("shortUrl"), canonical); err != nil {
+			utils.NewError(g, urlErrorStatus(err), err)
+			return
+		}
+		if err := domainPolicy.Check(models.DomainOf(canonical)); err != nil {
+			utils.NewError(g, http.StatusForbidden, err)
+			return
+		}
+		update.LongUrl = &canonical
+		domain := models.DomainOf(*update.LongUrl)
+		destination := models.DestinationOf(*update.LongUrl)
+		update.Domain = &domain
+		update.Destination = &destination
+	}
+	if update.Password != nil {
+		passwordHash := ""
+		if *update.Password != "" {
+			var err error
+			if passwordHash, err = hashPassword(*update.Password); err != nil {
+				utils.NewError(g, http.StatusInternalServerError, err)
+				return
+			}
+		}
+		update.PasswordHash = &passwordHash
+	}
+
+	url, err := repository.Client.UpdateUrl(context.TODO(), g.Param("shortUrl"), update)
+
+	if errors.Is(err, repository.ErrUrlNotFound) {
+		utils.NewError(g, http.StatusNotFound, errors.New("URL not found"))
+		return
+	}
+	if err != nil {
+		utils.NewError(g, http.StatusInternalServerError, err)
+		return
+	}
+
+	g.IndentedJSON(http.StatusOK, url)
+}
+
+// DeleteShortenedUrl godoc
+// @Summary delete shortened urls
+// @Schemes
+// @Description delete a shortened url, which then answers 410 Gone and is never reissued.
+// @Description Only admins may delete the shortened urls of other owners
+// @Tags manage
+// @Produce json
+// @Param shortUrl path string true "Short URL"
+// @Success 204
+// @Failure 401 {object} utils.HTTPError
+// @Failure 403 {object} utils.HTTPError
+// @Failure 404 {object} utils.HTTPError
+// @Failure 500 {object} utils.HTTPError
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /data/{shortUrl} [delete]
+func DeleteShortenedUrl(g *gin.Context) {
+	if !authorizeOwner(g, g.Param("shortUrl")) {
+		return
+	}
+
+	err := repository.Client.DeleteUrl(context.TODO(), g.Param("shortUrl"))
+
+	if errors.Is(err, repository.ErrUrlNotFound) {
+		utils.NewError(g, http.StatusNotFound, errors.New("URL not found"))
+		return
+	}
+	if err != nil {
+		utils.NewError(g, http.StatusInternalServerError, err)
+		return
+	}
+
+	g.Status(http.StatusNoContent)
+}
@@ -0,0 +1,167 @@
+package routes
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kjj1998/url-shortener-go/internal/models"
+	"github.com/kjj1998/url-shortener-go/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestUpdateShortenedUrl(t *testing.T) {
+	longUrl := "http://example.org"
+	updatedUrl := models.Url{Id: 2387497, ShortUrl: "NWER425d", LongUrl: longUrl}
+
+	tests := []struct {
+		name           string
+		payload        string
+		mockRepoResult *models.Url
+		mockRepoError  error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Valid request",
+			payload:        `{"longUrl":"http://example.org"}`,
+			mockRepoResult: &updatedUrl,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"id":2387497,"shortUrl":"NWER425d","longUrl":"http://example.org"}`,
+		},
+		{
+			name:           "Not found",
+			payload:        `{"longUrl":"http://example.org"}`,
+			mockRepoResult: &models.Url{},
+			mockRepoError:  repository.ErrUrlNotFound,
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"code":404, "message":"URL not found"}`,
+		},
+		{
+			name:           "Internal error",
+			payload:        `{"longUrl":"http://example.org"}`,
+			mockRepoResult: &models.Url{},
+			mockRepoError:  errors.New("simulated DynamoDB error"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"code":500, "message":"simulated DynamoDB error"}`,
+		},
+		{
+			name:           "No changes",
+			payload:        `{}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"code":400, "message":"no changes in json body"}`,
+		},
+		{
+			name:           "Empty destination",
+			payload:        `{"longUrl":""}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"code":400, "message":"invalid parameter names in json body"}`,
+		},
+		{
+			name:           "Negative max clicks",
+			payload:        `{"maxClicks":-1}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"code":400, "message":"maxClicks must be positive"}`,
+		},
+		{
+			name:           "Remove expiry",
+			payload:        `{"removeExpiry":true}`,
+			mockRepoResult: &updatedUrl,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"id":2387497,"shortUrl":"NWER425d","longUrl":"http://example.org"}`,
+		},
+		{
+			name:           "Expiry set and removed",
+			payload:        `{"expiresAt":"2030-01-01T00:00:00Z","removeExpiry":true}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"code":400, "message":"only one of expiresAt and removeExpiry may be set"}`,
+		},
+		{
+			name:           "Tags",
+			payload:        `{"tags":["News"]}`,
+			mockRepoResult: &models.Url{Id: 2387497, ShortUrl: "NWER425d", LongUrl: longUrl, Tags: []string{"news"}},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"id":2387497,"shortUrl":"NWER425d","longUrl":"http://example.org","tags":["news"]}`,
+		},
+		{
+			name:           "Too many tags",
+			payload:        `{"tags":["a","b","c","d","e","f","g","h","i","j","k","l","m","n","o","p","q","r","s","t","u"]}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"code":400, "message":"at most 20 tags may be set"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		mockRepo := new(MockUrlRepository)
+		repository.Client = mockRepo
+		if tt.mockRepoResult != nil {
+			mockRepo.On("UpdateUrl", mock.Anything, "NWER425d", mock.Anything).Return(*tt.mockRepoResult, tt.mockRepoError).Once()
+		}
+
+		rec := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(rec)
+		req, _ := http.NewRequest(http.MethodPatch, "/api/v1/data/NWER425d", bytes.NewBufferString(tt.payload))
+		req.Header.Set("Content-Type", "application/json")
+		ctx.Request = req
+		ctx.Params = gin.Params{{Key: "shortUrl", Value: "NWER425d"}}
+
+		UpdateShortenedUrl(ctx)
+
+		assert.Equal(t, tt.expectedStatus, rec.Code, tt.name)
+		assert.JSONEq(t, tt.expectedBody, rec.Body.String(), tt.name)
+		mockRepo.AssertExpectations(t)
+	}
+}
+
+func TestUpdateShortenedUrl_Password(t *testing.T) {
+	mockRepo := new(MockUrlRepository)
+	repository.Client = mockRepo
+	mockRepo.On("UpdateUrl", mock.Anything, "NWER425d", mock.MatchedBy(func(update models.UrlUpdate) bool {
+		return update.PasswordHash != nil && *update.PasswordHash == ""
+	})).Return(models.Url{Id: 2387497, ShortUrl: "NWER425d", LongUrl: "http://example.com"}, nil).Once()
+
+	rec := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(rec)
+	req, _ := http.NewRequest(http.MethodPatch, "/api/v1/data/NWER425d", bytes.NewBufferString(`{"password":""}`))
+	req.Header.Set("Content-Type", "application/json")
+	ctx.Request = req
+	ctx.Params = gin.Params{{Key: "shortUrl", Value: "NWER425d"}}
+
+	UpdateShortenedUrl(ctx)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestDeleteShortenedUrl(t *testing.T) {
+	tests := []struct {
+		name           string
+		mockRepoError  error
+		expectedStatus int
+	}{
+		{name: "Valid request", expectedStatus: http.StatusNoContent},
+		{name: "Not found", mockRepoError: repository.ErrUrlNotFound, expectedStatus: http.StatusNotFound},
+		{name: "Internal error", mockRepoError: errors.New("simulated DynamoDB error"), expectedStatus: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		mockRepo := new(MockUrlRepository)
+		repository.Client = mockRepo
+		mockRepo.On("DeleteUrl", mock.Anything, "NWER425d").Return(tt.mockRepoError).Once()
+
+		rec := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(rec)
+		ctx.Request, _ = http.NewRequest(http.MethodDelete, "/api/v1/data/NWER425d", nil)
+		ctx.Params = gin.Params{{Key: "shortUrl", Value: "NWER425d"}}
+
+		DeleteShortenedUrl(ctx)
+		ctx.Writer.WriteHeaderNow()
+
+		assert.Equal(t, tt.expectedStatus, rec.Code, tt.name)
+		mockRepo.AssertExpectations(t)
+	}
+}
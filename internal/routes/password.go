@@ -0,0 +1,165 @@
+package routes
+
+import (
+	"bytes"
+	"errors"
+	"html/template"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kjj1998/url-shortener-go/constants"
+	"github.com/kjj1998/url-shortener-go/internal/config"
+	"github.com/kjj1998/url-shortener-go/internal/models"
+	"github.com/kjj1998/url-shortener-go/internal/utils"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var ErrPasswordIncorrect = errors.New("incorrect password")
+
+// comparePassword checks a password against the hash of the password of a shortened URL
+var comparePassword = bcrypt.CompareHashAndPassword
+
+// passwordAttempts throttles guessing the password of a shortened URL, per shortened URL and per instance of the service
+var passwordAttempts = newAttemptThrottle(
+	config.Int("PASSWORD_ATTEMPTS", constants.PasswordAttempts),
+	config.Duration("PASSWORD_ATTEMPT_WINDOW", constants.PasswordAttemptWindow),
+)
+
+var passwordForm = template.Must(template.New("password").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Password required</title></head>
+<body>
+<form method="post">
+<p>{{if .Incorrect}}Incorrect password, try again.{{else}}This link is password protected.{{end}}</p>
+<input type="password" name="password" autofocus required>
+<button type="submit">Continue</button>
+</form>
+</body>
+</html>
+`))
+
+// hashPassword hashes the password of a shortened URL with a per-password salt
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	return string(hash), err
+}
+
+// authorizeProtectedUrl checks the password sent to follow a password-protected shortened URL, either in the
+// X-Link-Password header, as the password of basic auth or from the password form. When it is missing or
+// incorrect the response asking for it again is written and false is returned
+func authorizeProtectedUrl(g *gin.Context, url models.Url) bool {
+	password, fromForm := requestPassword(g)
+	if password == "" {
+		renderPasswordForm(g, false)
+		return false
+	}
+
+	// The attempt is reserved before the slow comparison, so concurrent guesses can not all slip under the limit
+	if retryAfter, ok := passwordAttempts.begin(url.ShortUrl); !ok {
+		g.Header("Retry-After", strconv.Itoa(int(retryAfter.Round(time.Second).Seconds())))
+		utils.NewError(g, http.StatusTooManyRequests, errors.New("too many incorrect passwords, try again later"))
+		return false
+	}
+
+	if comparePassword([]byte(url.PasswordHash), []byte(password)) != nil {
+		if fromForm {
+			renderPasswordForm(g, true)
+		} else {
+			utils.NewError(g, http.StatusUnauthorized, ErrPasswordIncorrect)
+		}
+		return false
+	}
+
+	passwordAttempts.release(url.ShortUrl)
+	return true
+}
+
+// requestPassword returns the password sent with the request, and whether it was posted from the password form
+func requestPassword(g *gin.Context) (string, bool) {
+	if password := g.GetHeader(constants.PasswordHeader); password != "" {
+		return password, false
+	}
+	if _, password, ok := g.Request.BasicAuth(); ok && password != "" {
+		return password, false
+	}
+	if g.Request.Method == http.MethodPost {
+		return g.PostForm("password"), true
+	}
+	return "", false
+}
+
+func renderPasswordForm(g *gin.Context, incorrect bool) {
+	var page bytes.Buffer
+	if err := passwordForm.Execute(&page, struct{ Incorrect bool }{incorrect}); err != nil {
+		utils.NewError(g, http.StatusInternalServerError, err)
+		return
+	}
+
+	g.Header("Cache-Control", "no-store")
+	g.Data(http.StatusUnauthorized, "text/html; charset=utf-8", page.Bytes())
+}
+
+// attemptThrottle allows at most limit failed attempts per key within a window starting at the first failure
+type attemptThrottle struct {
+	mu        sync.Mutex
+	limit     int
+	window    time.Duration
+	failures  map[string]*attemptWindow
+	nextSweep time.Time
+	now       func() time.Time
+}
+
+type attemptWindow struct {
+	count   int
+	resetAt time.Time
+}
+
+func newAttemptThrottle(limit int, window time.Duration) *attemptThrottle {
+	return &attemptThrottle{
+		limit:    limit,
+		window:   window,
+		failures: make(map[string]*attemptWindow),
+		now:      time.Now,
+	}
+}
+
+// begin reserves an attempt for key, counting it as failed until it is released, and reports whether it may be made.
+// When it may not, it also returns how long until it may
+func (throttle *attemptThrottle) begin(key string) (time.Duration, bool) {
+	throttle.mu.Lock()
+	defer throttle.mu.Unlock()
+
+	now := throttle.now()
+	if now.After(throttle.nextSweep) {
+		for k, failures := range throttle.failures {
+			if !now.Before(failures.resetAt) {
+				delete(throttle.failures, k)
+			}
+		}
+		throttle.nextSweep = now.Add(throttle.window)
+	}
+
+	failures, ok := throttle.failures[key]
+	if !ok || !now.Before(failures.resetAt) {
+		failures = &attemptWindow{resetAt: now.Add(throttle.window)}
+		throttle.failures[key] = failures
+	}
+	if failures.count >= throttle.limit {
+		return failures.resetAt.Sub(now), false
+	}
+	failures.count++
+	return 0, true
+}
+
+// release gives back the attempt reserved for key by begin, once it has succeeded
+func (throttle *attemptThrottle) release(key string) {
+	throttle.mu.Lock()
+	defer throttle.mu.Unlock()
+
+	if failures, ok := throttle.failures[key]; ok && failures.count > 0 {
+		failures.count--
+	}
+}
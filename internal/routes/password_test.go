@@ -0,0 +1,174 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kjj1998/url-shortener-go/constants"
+	"github.com/kjj1998/url-shortener-go/internal/models"
+	"github.com/kjj1998/url-shortener-go/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestRedirectProtectedShortenedUrl(t *testing.T) {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("hunter2"), bcrypt.MinCost)
+	protectedUrl := models.Url{Id: 2387497, ShortUrl: "NWER425d", LongUrl: "http://example.com", PasswordHash: string(hash)}
+
+	tests := []struct {
+		name             string
+		request          func() *http.Request
+		expectedStatus   int
+		expectedLocation string
+		expectedBody     string
+	}{
+		{
+			name:           "No password",
+			request:        func() *http.Request { return httptest.NewRequest(http.MethodGet, "/api/v1/NWER425d", nil) },
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   "This link is password protected.",
+		},
+		{
+			name: "Header password",
+			request: func() *http.Request {
+				req := httptest.NewRequest(http.MethodGet, "/api/v1/NWER425d", nil)
+				req.Header.Set("X-Link-Password", "hunter2")
+				return req
+			},
+			expectedStatus:   http.StatusTemporaryRedirect,
+			expectedLocation: "http://example.com",
+		},
+		{
+			name: "Basic auth password",
+			request: func() *http.Request {
+				req := httptest.NewRequest(http.MethodGet, "/api/v1/NWER425d", nil)
+				req.SetBasicAuth("", "hunter2")
+				return req
+			},
+			expectedStatus:   http.StatusTemporaryRedirect,
+			expectedLocation: "http://example.com",
+		},
+		{
+			name: "Incorrect header password",
+			request: func() *http.Request {
+				req := httptest.NewRequest(http.MethodGet, "/api/v1/NWER425d", nil)
+				req.Header.Set("X-Link-Password", "hunter3")
+				return req
+			},
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `"message":"incorrect password"`,
+		},
+		{
+			name: "Form password",
+			request: func() *http.Request {
+				form := url.Values{"password": {"hunter2"}}
+				req := httptest.NewRequest(http.MethodPost, "/api/v1/NWER425d", strings.NewReader(form.Encode()))
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+				return req
+			},
+			expectedStatus:   http.StatusSeeOther,
+			expectedLocation: "http://example.com",
+		},
+		{
+			name: "Incorrect form password",
+			request: func() *http.Request {
+				form := url.Values{"password": {"hunter3"}}
+				req := httptest.NewRequest(http.MethodPost, "/api/v1/NWER425d", strings.NewReader(form.Encode()))
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+				return req
+			},
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   "Incorrect password, try again.",
+		},
+	}
+
+	passwordAttempts = newAttemptThrottle(10, time.Minute)
+	for _, tt := range tests {
+		mockRepo := new(MockUrlRepository)
+		repository.Client = mockRepo
+		mockRepo.On("RetrieveUrl", mock.Anything, "NWER425d").Return(protectedUrl, nil).Once()
+
+		rec := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(rec)
+		ctx.Request = tt.request()
+		ctx.Params = gin.Params{{Key: "shortUrl", Value: "NWER425d"}}
+
+		RedirectShortenedUrl(ctx)
+		// Redirects of POST requests carry no body, flush their status as the router would after the handler
+		ctx.Writer.WriteHeaderNow()
+
+		assert.Equal(t, tt.expectedStatus, rec.Code, tt.name)
+		assert.Equal(t, tt.expectedLocation, rec.Header().Get("Location"), tt.name)
+		assert.Contains(t, rec.Body.String(), tt.expectedBody, tt.name)
+	}
+}
+
+func TestAttemptThrottle(t *testing.T) {
+	throttle := newAttemptThrottle(3, time.Minute)
+	now := time.Now()
+	throttle.now = func() time.Time { return now }
+
+	_, ok := throttle.begin("NWER425d")
+	assert.True(t, ok)
+	throttle.release("NWER425d")
+	for i := 0; i < 3; i++ {
+		_, ok := throttle.begin("NWER425d")
+		assert.True(t, ok, "successful attempts are given back")
+	}
+
+	retryAfter, ok := throttle.begin("NWER425d")
+	assert.False(t, ok)
+	assert.Equal(t, time.Minute, retryAfter)
+
+	_, ok = throttle.begin("KWBG425d")
+	assert.True(t, ok, "other shortened urls are not throttled")
+
+	now = now.Add(time.Minute)
+	_, ok = throttle.begin("NWER425d")
+	assert.True(t, ok)
+}
+
+func TestAuthorizeProtectedUrlConcurrentGuesses(t *testing.T) {
+	passwordAttempts = newAttemptThrottle(3, time.Minute)
+	var compared atomic.Int32
+	comparePassword = func(hash []byte, password []byte) error {
+		compared.Add(1)
+		// Slow enough for every guess to be in flight at once
+		time.Sleep(50 * time.Millisecond)
+		return bcrypt.ErrMismatchedHashAndPassword
+	}
+	t.Cleanup(func() {
+		passwordAttempts = newAttemptThrottle(10, time.Minute)
+		comparePassword = bcrypt.CompareHashAndPassword
+	})
+
+	url := models.Url{ShortUrl: "NWER425d", LongUrl: "http://example.com", PasswordHash: "hash"}
+	var wg sync.WaitGroup
+	var throttled atomic.Int32
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rec := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(rec)
+			ctx.Request = httptest.NewRequest(http.MethodGet, "/api/v1/NWER425d", nil)
+			ctx.Request.Header.Set(constants.PasswordHeader, "guess")
+			authorizeProtectedUrl(ctx, url)
+			if rec.Code == http.StatusTooManyRequests {
+				throttled.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(3), compared.Load(), "at most the limit of guesses reach bcrypt")
+	assert.Equal(t, int32(7), throttled.Load())
+}
@@ -0,0 +1,71 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kjj1998/url-shortener-go/internal/models"
+	"github.com/kjj1998/url-shortener-go/internal/repository"
+)
+
+// ReserveRoutes reserves the first path segment of every route registered on the router, both from the
+// root and from basePath, so that no shortened URL can shadow a route
+func ReserveRoutes(router *gin.Engine, basePath string) {
+	for _, route := range router.Routes() {
+		models.ReserveWords(firstSegment(route.Path))
+		if strings.HasPrefix(route.Path, basePath+"/") {
+			models.ReserveWords(firstSegment(strings.TrimPrefix(route.Path, basePath)))
+		}
+	}
+}
+
+// firstSegment returns the first segment of a route path, or an empty string when it is a wildcard
+func firstSegment(path string) string {
+	segment, _, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+		return ""
+	}
+	return segment
+}
+
+// CheckReservedShortUrls returns an error naming the stored shortened URLs that shadow a reserved word, looking up
+// each reserved word so it stays cheap on large tables. Shortened URLs shadow reserved words case-insensitively, the
+// ones differing from a reserved word in case are only found by ScanReservedShortUrls
+func CheckReservedShortUrls(ctx context.Context) error {
+	var shadowing []string
+	for _, word := range models.ReservedWords() {
+		url, err := repository.Client.RetrieveUrl(ctx, word)
+		if err != nil {
+			return err
+		}
+		if url.ShortUrl != "" {
+			shadowing = append(shadowing, url.ShortUrl)
+		}
+	}
+	return shadowingError(shadowing)
+}
+
+// ScanReservedShortUrls returns an error naming the stored shortened URLs that shadow a reserved word, scanning
+// every stored shortened URL
+func ScanReservedShortUrls(ctx context.Context) error {
+	var shadowing []string
+	err := repository.Client.ScanShortUrls(ctx, func(shortUrl string) error {
+		if models.IsReserved(shortUrl) {
+			shadowing = append(shadowing, shortUrl)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return shadowingError(shadowing)
+}
+
+func shadowingError(shadowing []string) error {
+	if len(shadowing) > 0 {
+		return fmt.Errorf("stored shortened urls shadow reserved words: %v", strings.Join(shadowing, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kjj1998/url-shortener-go/internal/models"
+	"github.com/kjj1998/url-shortener-go/internal/repository"
+	"github.com/kjj1998/url-shortener-go/internal/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReserveRoutes(t *testing.T) {
+	t.Cleanup(models.SaveReservedWords())
+	router := gin.New()
+	v1 := router.Group("/api/v1")
+	v1.GET("/:shortUrl", RedirectShortenedUrl)
+	v1.GET("/stats/daily", HealthCheck)
+	router.GET("/metrics/*any", HealthCheck)
+
+	ReserveRoutes(router, "/api/v1")
+
+	assert.True(t, models.IsReserved("stats"))
+	assert.True(t, models.IsReserved("metrics"))
+	assert.True(t, models.IsReserved("API"))
+	assert.False(t, models.IsReserved(":shortUrl"))
+	assert.False(t, models.IsReserved("daily"))
+}
+
+func TestCheckReservedShortUrls(t *testing.T) {
+	ctx := context.Background()
+	client := repository.NewMemoryClient()
+	repository.Client = client
+
+	assert.NoError(t, client.AddUrl(ctx, models.Url{Id: 1, ShortUrl: "NWER425d", LongUrl: "http://example.com"}))
+	assert.NoError(t, CheckReservedShortUrls(ctx))
+
+	assert.NoError(t, client.AddUrl(ctx, models.Url{Id: 2, ShortUrl: "Docs", LongUrl: "http://example.com"}))
+	assert.NoError(t, CheckReservedShortUrls(ctx), "reserved words are looked up as they are")
+
+	assert.NoError(t, client.AddUrl(ctx, models.Url{Id: 3, ShortUrl: "health", LongUrl: "http://example.com"}))
+	assert.EqualError(t, CheckReservedShortUrls(ctx), "stored shortened urls shadow reserved words: health")
+}
+
+func TestScanReservedShortUrls(t *testing.T) {
+	ctx := context.Background()
+	client := repository.NewMemoryClient()
+	repository.Client = client
+
+	assert.NoError(t, client.AddUrl(ctx, models.Url{Id: 1, ShortUrl: "NWER425d", LongUrl: "http://example.com"}))
+	assert.NoError(t, ScanReservedShortUrls(ctx))
+
+	assert.NoError(t, client.AddUrl(ctx, models.Url{Id: 2, ShortUrl: "Docs", LongUrl: "http://example.com"}))
+	assert.EqualError(t, ScanReservedShortUrls(ctx), "stored shortened urls shadow reserved words: Docs")
+}
+
+func TestRedirectReservedShortUrl(t *testing.T) {
+	mockRepo := new(MockUrlRepository)
+	repository.Client = mockRepo
+
+	rec := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(rec)
+	ctx.Request, _ = http.NewRequest(http.MethodGet, "/api/v1/data", nil)
+	ctx.Params = gin.Params{{Key: "shortUrl", Value: "data"}}
+
+	RedirectShortenedUrl(ctx)
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	mockRepo.AssertNotCalled(t, "RetrieveUrl")
+}
+
+func TestGenerateReservedShortUrl(t *testing.T) {
+	client := repository.NewMemoryClient()
+	repository.Client = client
+
+	originalShortenUrl := utils.ShortenUrl
+	defer func() { utils.ShortenUrl = originalShortenUrl }()
+	generated := []string{"health", "NWER425d"}
+	utils.ShortenUrl = func(id uint64) string {
+		shortUrl := generated[0]
+		generated = generated[1:]
+		return shortUrl
+	}
+
+	url, err := addUrl(context.Background(), models.Url{LongUrl: "http://example.com"}, "")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "NWER425d", url.ShortUrl)
+}
@@ -0,0 +1,132 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/kjj1998/url-shortener-go/constants"
+	"github.com/kjj1998/url-shortener-go/internal/config"
+	"github.com/kjj1998/url-shortener-go/internal/models"
+	"github.com/kjj1998/url-shortener-go/internal/repository"
+)
+
+var ErrRedirectLoop = errors.New("redirect loop detected")
+
+var (
+	// shortUrlHosts are the lowercased host names the service is reached at, whatever the port
+	shortUrlHosts = configuredShortUrlHosts()
+	// selfReferences is what happens to long URLs pointing at the service when they are shortened
+	selfReferences = configuredSelfReferences()
+)
+
+func configuredShortUrlHosts() []string {
+	hosts := config.List("SHORT_URL_HOSTS")
+	if hosts == nil {
+		hosts = strings.Split(constants.ShortUrlHosts, ",")
+	}
+	for i, host := range hosts {
+		if hostname, _, err := net.SplitHostPort(host); err == nil {
+			host = hostname
+		}
+		hosts[i] = strings.ToLower(strings.Trim(host, "[]"))
+	}
+	return hosts
+}
+
+func configuredSelfReferences() string {
+	mode := config.String("SELF_REFERENCES", constants.RejectSelfReferences)
+	if mode != constants.RejectSelfReferences && mode != constants.ResolveSelfReferences {
+		log.Fatalf("SELF_REFERENCES must be %v or %v, got %v",
+			constants.RejectSelfReferences, constants.ResolveSelfReferences, mode)
+	}
+	return mode
+}
+
+// selfShortUrl reports whether a long URL points at the service, with the shortened URL it redirects from when it
+// points at one
+func selfShortUrl(longUrl string) (string, bool) {
+	parsed, err := url.Parse(longUrl)
+	if err != nil || !slices.Contains(shortUrlHosts, strings.ToLower(parsed.Hostname())) {
+		return "", false
+	}
+
+	shortUrl, ok := strings.CutPrefix(parsed.Path, constants.BasePath+"/")
+	if !ok || shortUrl == "" || strings.Contains(shortUrl, "/") || models.IsReserved(shortUrl) {
+		return "", true
+	}
+	return shortUrl, true
+}
+
+// resolveSelfReference returns the long URL to store for a shortened URL, which is empty when it is not known yet.
+// Long URLs pointing at the service are refused, or with SELF_REFERENCES=resolve replaced with the destination of
+// the shortened URLs they go through. Only shortened URLs that anyone can follow for as long as they exist are
+// resolved, and a chain of them coming back to a shortened URL or longer than RedirectMaxHops is refused
+func resolveSelfReference(ctx context.Context, shortUrl string, longUrl string) (string, error) {
+	seen := map[string]bool{shortUrl: true}
+	for hops := 0; ; hops++ {
+		target, self := selfShortUrl(longUrl)
+		switch {
+		case !self:
+			return longUrl, nil
+		case selfReferences != constants.ResolveSelfReferences || target == "":
+			return "", models.ErrLongUrlSelf
+		case seen[target] || hops == constants.RedirectMaxHops:
+			return "", models.ErrLongUrlLoop
+		}
+		seen[target] = true
+
+		url, err := repository.Client.RetrieveUrl(ctx, target)
+		if err != nil {
+			return "", err
+		}
+		if url.ShortUrl == "" || !url.Reusable() {
+			return "", models.ErrLongUrlSelfTarget
+		}
+		longUrl = url.LongUrl
+	}
+}
+
+// followSelfReferences follows the long URL of a shortened URL through the shortened URLs of the service it points
+// at, returning the destination to redirect to and the hops made to reach it on top of the hops already made.
+// Shortened URLs that expire, are limited or protected are not followed but redirected to, as they check the
+// requests reaching them. Returns ErrRedirectLoop when a chain comes back to a shortened URL or redirecting to its
+// destination would take more than RedirectMaxHops hops
+func followSelfReferences(ctx context.Context, shortUrl string, longUrl string, hops int) (string, int, error) {
+	seen := map[string]bool{shortUrl: true}
+	for {
+		target, _ := selfShortUrl(longUrl)
+		if target == "" {
+			return longUrl, hops, nil
+		}
+		// Following the shortened URL is a hop, and redirecting to its destination another one
+		if seen[target] || hops+2 > constants.RedirectMaxHops {
+			return "", hops, ErrRedirectLoop
+		}
+		seen[target] = true
+
+		url, err := repository.Client.RetrieveUrl(ctx, target)
+		if err != nil {
+			return "", hops, err
+		}
+		if url.ShortUrl == "" || !url.Reusable() {
+			return longUrl, hops, nil
+		}
+		longUrl = url.LongUrl
+		hops++
+	}
+}
+
+// urlErrorStatus returns the status answering an error about a long URL, 400 for the refused ones
+func urlErrorStatus(err error) int {
+	var urlErr *models.UrlError
+	if errors.As(err, &urlErr) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,205 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kjj1998/url-shortener-go/constants"
+	"github.com/kjj1998/url-shortener-go/internal/models"
+	"github.com/kjj1998/url-shortener-go/internal/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+func enterSelfReferenceTest(t *testing.T, mode string) {
+	shortUrlHosts = []string{"sho.rt"}
+	selfReferences = mode
+	t.Cleanup(func() {
+		shortUrlHosts = configuredShortUrlHosts()
+		selfReferences = configuredSelfReferences()
+	})
+
+	repository.Client = repository.NewMemoryClient()
+	for i, url := range []models.Url{
+		{ShortUrl: "final01", LongUrl: "https://example.com/final"},
+		{ShortUrl: "chain01", LongUrl: "https://sho.rt/api/v1/final01"},
+		{ShortUrl: "loop001", LongUrl: "https://sho.rt/api/v1/loop002"},
+		{ShortUrl: "loop002", LongUrl: "https://sho.rt/api/v1/loop001"},
+		{ShortUrl: "secret1", LongUrl: "https://example.com/secret", PasswordHash: "hash"},
+	} {
+		url.Id = uint64(i + 1)
+		assert.NoError(t, repository.Client.AddUrl(context.Background(), url))
+	}
+}
+
+func TestGenerateShortenedUrlSelfReference(t *testing.T) {
+	tests := []struct {
+		name            string
+		mode            string
+		longUrl         string
+		expectedStatus  int
+		expectedLongUrl string
+		expectedCode    string
+	}{
+		{
+			name:           "Rejected short url",
+			mode:           constants.RejectSelfReferences,
+			longUrl:        "https://sho.rt/api/v1/final01",
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "url_self_reference",
+		},
+		{
+			name:           "Other path of the service",
+			mode:           constants.ResolveSelfReferences,
+			longUrl:        "https://SHO.RT:8443/swagger/index.html",
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "url_self_reference",
+		},
+		{
+			name:            "Resolved short url",
+			mode:            constants.ResolveSelfReferences,
+			longUrl:         "https://sho.rt/api/v1/final01",
+			expectedStatus:  http.StatusCreated,
+			expectedLongUrl: "https://example.com/final",
+		},
+		{
+			name:            "Resolved chain",
+			mode:            constants.ResolveSelfReferences,
+			longUrl:         "http://sho.rt:80/api/v1/./chain01",
+			expectedStatus:  http.StatusCreated,
+			expectedLongUrl: "https://example.com/final",
+		},
+		{
+			name:           "Loop",
+			mode:           constants.ResolveSelfReferences,
+			longUrl:        "https://sho.rt/api/v1/loop001",
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "url_redirect_loop",
+		},
+		{
+			name:           "Password-protected short url",
+			mode:           constants.ResolveSelfReferences,
+			longUrl:        "https://sho.rt/api/v1/secret1",
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "url_self_reference_unresolved",
+		},
+		{
+			name:           "Missing short url",
+			mode:           constants.ResolveSelfReferences,
+			longUrl:        "https://sho.rt/api/v1/missing",
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "url_self_reference_unresolved",
+		},
+		{
+			name:            "Other host",
+			mode:            constants.RejectSelfReferences,
+			longUrl:         "https://shorter.example/api/v1/final01",
+			expectedStatus:  http.StatusCreated,
+			expectedLongUrl: "https://shorter.example/api/v1/final01",
+		},
+	}
+
+	for _, tt := range tests {
+		enterSelfReferenceTest(t, tt.mode)
+
+		rec := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(rec)
+		requestBody, _ := json.Marshal(models.LongUrl{LongUrl: tt.longUrl})
+		ctx.Request = httptest.NewRequest(http.MethodPost, "/shorten", bytes.NewBuffer(requestBody))
+		ctx.Request.Header.Set("Content-Type", "application/json")
+
+		GenerateShortenedUrl(ctx)
+
+		assert.Equal(t, tt.expectedStatus, rec.Code, tt.name)
+		var body struct {
+			LongUrl   string `json:"longUrl"`
+			ErrorCode string `json:"errorCode"`
+		}
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		assert.Equal(t, tt.expectedLongUrl, body.LongUrl, tt.name)
+		assert.Equal(t, tt.expectedCode, body.ErrorCode, tt.name)
+	}
+}
+
+func TestUpdateShortenedUrlSelfReference(t *testing.T) {
+	enterSelfReferenceTest(t, constants.ResolveSelfReferences)
+
+	rec := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(rec)
+	ctx.Request = httptest.NewRequest(http.MethodPatch, "/api/v1/data/final01",
+		bytes.NewBufferString(`{"longUrl":"https://sho.rt/api/v1/chain01"}`))
+	ctx.Request.Header.Set("Content-Type", "application/json")
+	ctx.Params = gin.Params{{Key: "shortUrl", Value: "final01"}}
+
+	UpdateShortenedUrl(ctx)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.JSONEq(t, `{"code":400,"message":"longUrl leads to a redirect loop","errorCode":"url_redirect_loop"}`,
+		rec.Body.String())
+}
+
+func TestRedirectShortenedUrlSelfReference(t *testing.T) {
+	tests := []struct {
+		name             string
+		shortUrl         string
+		hops             string
+		expectedStatus   int
+		expectedLocation string
+		expectedHops     string
+	}{
+		{
+			name:             "Destination",
+			shortUrl:         "final01",
+			expectedStatus:   http.StatusTemporaryRedirect,
+			expectedLocation: "https://example.com/final",
+			expectedHops:     "1",
+		},
+		{
+			name:             "Chain",
+			shortUrl:         "chain01",
+			hops:             "2",
+			expectedStatus:   http.StatusTemporaryRedirect,
+			expectedLocation: "https://example.com/final",
+			expectedHops:     "4",
+		},
+		{
+			name:           "Loop",
+			shortUrl:       "loop001",
+			expectedStatus: http.StatusLoopDetected,
+		},
+		{
+			name:           "Too many hops",
+			shortUrl:       "final01",
+			hops:           "5",
+			expectedStatus: http.StatusLoopDetected,
+		},
+		{
+			name:           "Too many hops in a chain",
+			shortUrl:       "chain01",
+			hops:           "4",
+			expectedStatus: http.StatusLoopDetected,
+		},
+	}
+
+	for _, tt := range tests {
+		enterSelfReferenceTest(t, constants.RejectSelfReferences)
+
+		rec := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(rec)
+		ctx.Request = httptest.NewRequest(http.MethodGet, "/api/v1/"+tt.shortUrl, nil)
+		if tt.hops != "" {
+			ctx.Request.Header.Set(constants.RedirectHopsHeader, tt.hops)
+		}
+		ctx.Params = gin.Params{{Key: "shortUrl", Value: tt.shortUrl}}
+
+		RedirectShortenedUrl(ctx)
+
+		assert.Equal(t, tt.expectedStatus, rec.Code, tt.name)
+		assert.Equal(t, tt.expectedLocation, rec.Header().Get("Location"), tt.name)
+		assert.Equal(t, tt.expectedHops, rec.Header().Get(constants.RedirectHopsHeader), tt.name)
+	}
+}
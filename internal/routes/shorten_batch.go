@@ -0,0 +1,302 @@
+package routes
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kjj1998/url-shortener-go/constants"
+	"github.com/kjj1998/url-shortener-go/internal/config"
+	"github.com/kjj1998/url-shortener-go/internal/models"
+	"github.com/kjj1998/url-shortener-go/internal/repository"
+	"github.com/kjj1998/url-shortener-go/internal/utils"
+)
+
+var ErrCsvLongUrl = errors.New("csv header must have a longUrl column")
+
+// shortenBatchMaxUrls is the most URLs shortened in one request
+var shortenBatchMaxUrls = config.Int("SHORTEN_BATCH_MAX_URLS", constants.ShortenBatchMaxUrls)
+
+// batchItem is a URL of a batch to shorten, with the error of reading it from the batch if any
+type batchItem struct {
+	request models.LongUrl
+	err     error
+}
+
+// ShortenBatch godoc
+// @Summary generate shortened urls in bulk
+// @Schemes
+// @Description shorten many urls at once, sent as a JSON array or as CSV with a header row naming the fields of each
+// @Description url, either as the request body or as the file of a multipart form. CSV tags are separated by ';'.
+// @Description Each url is validated and shortened independently, the results are in the order of the batch
+// @Tags example
+// @Accept json,text/csv,multipart/form-data
+// @Produce json
+// @Param longUrls body []models.LongUrl true "URLs to shorten"
+// @Param Idempotency-Key header string false "Unique key of the request, to retry it safely"
+// @Success 200 {object} models.ShortenBatchResult
+// @Failure 400 {object} utils.HTTPError
+// @Failure 401 {object} utils.HTTPError
+// @Failure 403 {object} utils.HTTPError
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /data/shorten/batch [post]
+func ShortenBatch(g *gin.Context) {
+	items, err := bindBatch(g)
+	if err != nil {
+		utils.NewError(g, http.StatusBadRequest, err)
+		return
+	}
+	if len(items) == 0 || len(items) > shortenBatchMaxUrls {
+		utils.NewError(g, http.StatusBadRequest, fmt.Errorf("between 1 and %d urls may be shortened at once", shortenBatchMaxUrls))
+		return
+	}
+
+	g.IndentedJSON(http.StatusOK, models.ShortenBatchResult{Results: shortenBatch(g, items, timeNow())})
+}
+
+// bindBatch reads the URLs of a batch from a JSON array, a CSV body or the CSV file of a multipart form
+func bindBatch(g *gin.Context) ([]batchItem, error) {
+	switch g.ContentType() {
+	case "text/csv":
+		return readCsvBatch(g.Request.Body)
+	case "multipart/form-data":
+		header, err := g.FormFile("file")
+		if err != nil {
+			return nil, err
+		}
+		file, err := header.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		return readCsvBatch(file)
+	default:
+		var requests []models.LongUrl
+		if err := g.ShouldBindJSON(&requests); err != nil {
+			return nil, err
+		}
+		items := make([]batchItem, len(requests))
+		for i, request := range requests {
+			items[i] = batchItem{request: request}
+		}
+		return items, nil
+	}
+}
+
+// readCsvBatch reads a CSV batch whose header row names the LongUrl field held by each column.
+// A row with a value that cannot be read gets an error of its own, so the other rows are still shortened
+func readCsvBatch(r io.Reader) ([]batchItem, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, column := range header {
+		if _, ok := csvColumns[column]; !ok {
+			return nil, fmt.Errorf("csv column %q is not a field of a url to shorten", column)
+		}
+	}
+	if !slices.Contains(header, "longUrl") {
+		return nil, ErrCsvLongUrl
+	}
+
+	var items []batchItem
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return items, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var item batchItem
+		for i, value := range row {
+			if err := csvColumns[header[i]](&item.request, value); err != nil && item.err == nil {
+				item.err = fmt.Errorf("invalid %s: %w", header[i], err)
+			}
+		}
+		items = append(items, item)
+	}
+}
+
+// csvColumns sets the LongUrl field named by a CSV column from its value
+var csvColumns = map[string]func(request *models.LongUrl, value string) error{
+	"longUrl": func(request *models.LongUrl, value string) error { request.LongUrl = value; return nil },
+	"alias":   func(request *models.LongUrl, value string) error { request.Alias = value; return nil },
+	"expiresAt": func(request *models.LongUrl, value string) error {
+		if value == "" {
+			return nil
+		}
+		expiresAt, err := time.Parse(time.RFC3339, value)
+		request.ExpiresAt = &expiresAt
+		return err
+	},
+	"ttlSeconds": func(request *models.LongUrl, value string) (err error) {
+		request.TtlSeconds, err = parseCsvInt(value)
+		return err
+	},
+	"maxClicks": func(request *models.LongUrl, value string) (err error) {
+		request.MaxClicks, err = parseCsvInt(value)
+		return err
+	},
+	"password":    func(request *models.LongUrl, value string) error { request.Password = value; return nil },
+	"title":       func(request *models.LongUrl, value string) error { request.Title = value; return nil },
+	"description": func(request *models.LongUrl, value string) error { request.Description = value; return nil },
+	"notes":       func(request *models.LongUrl, value string) error { request.Notes = value; return nil },
+	"tags": func(request *models.LongUrl, value string) error {
+		if value != "" {
+			request.Tags = strings.Split(value, ";")
+		}
+		return nil
+	},
+	"reuse": func(request *models.LongUrl, value string) (err error) {
+		if value != "" {
+			request.Reuse, err = strconv.ParseBool(value)
+		}
+		return err
+	},
+}
+
+func parseCsvInt(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
+
+// shortenBatch validates and shortens each URL of a batch. URLs with an alias are added one at a time so a taken
+// alias is never overwritten, the others are given shortened URLs generated from fresh ids and added together.
+// URLs to reuse share the result of the first URL of the batch with the same destination
+func shortenBatch(g *gin.Context, items []batchItem, now time.Time) []models.ShortenResult {
+	ctx := context.TODO()
+	results := make([]models.ShortenResult, len(items))
+	aliases := make(map[string]bool)
+	reuses := make(map[string]int)
+	reusing := make(map[int]int)
+	var generated []models.Url
+	var generatedIndexes []int
+
+	for i, item := range items {
+		err := item.err
+		if err == nil {
+			err = item.request.Validation()
+		}
+		if err == nil {
+			item.request.LongUrl, err = canonicalLongUrl(ctx, item.request.LongUrl)
+		}
+		if err != nil {
+			results[i] = shortenFailure(http.StatusBadRequest, err)
+			continue
+		}
+		if item.request.LongUrl, err = resolveSelfReference(ctx, item.request.Alias, item.request.LongUrl); err != nil {
+			results[i] = shortenFailure(urlErrorStatus(err), err)
+			continue
+		}
+		if err := domainPolicy.Check(models.DomainOf(item.request.LongUrl)); err != nil {
+			results[i] = shortenFailure(http.StatusForbidden, err)
+			continue
+		}
+
+		url, err := newUrl(g, item.request, now)
+		if err != nil {
+			results[i] = shortenFailure(http.StatusInternalServerError, err)
+			continue
+		}
+
+		if item.request.Reuse {
+			if first, ok := reuses[url.Destination]; ok {
+				reusing[i] = first
+				continue
+			}
+			reuses[url.Destination] = i
+
+			reused, err := repository.Client.RetrieveReusableUrl(ctx, url.Owner, url.Destination)
+			if err != nil {
+				results[i] = shortenFailure(http.StatusInternalServerError, err)
+				continue
+			}
+			if reused.ShortUrl != "" {
+				results[i] = models.ShortenResult{Status: http.StatusOK, Url: &reused}
+				continue
+			}
+		}
+
+		if alias := item.request.Alias; alias != "" {
+			if aliases[alias] {
+				results[i] = shortenFailure(http.StatusConflict, ErrAliasTaken)
+				continue
+			}
+			aliases[alias] = true
+			results[i] = shortenResult(addUrl(ctx, url, alias))
+			continue
+		}
+
+		if url, err = generateShortUrl(url); err != nil {
+			results[i] = shortenFailure(http.StatusInternalServerError, err)
+			continue
+		}
+		generated = append(generated, url)
+		generatedIndexes = append(generatedIndexes, i)
+	}
+
+	if len(generated) > 0 {
+		errs := repository.Client.AddUrls(ctx, generated)
+		for j, i := range generatedIndexes {
+			url, err := generated[j], errs[j]
+			// A generated shortened URL may already be held by an alias or a legacy entry, so it is generated again
+			if errors.Is(err, repository.ErrShortUrlTaken) {
+				url, err = addUrl(ctx, url, "")
+			}
+			results[i] = shortenResult(url, err)
+		}
+	}
+	for i, first := range reusing {
+		results[i] = results[first]
+		if results[i].Url != nil {
+			results[i].Status = http.StatusOK
+		}
+	}
+	return results
+}
+
+// generateShortUrl gives url a fresh id and the shortened URL generated from it, retrying ids that spell a reserved word
+func generateShortUrl(url models.Url) (models.Url, error) {
+	for attempt := 1; attempt <= constants.ShortenUrlAttempts; attempt++ {
+		url.Id = utils.GenerateUniqueId()
+		url.ShortUrl = utils.ShortenUrl(url.Id)
+		if !models.IsReserved(url.ShortUrl) {
+			return url, nil
+		}
+	}
+	return url, ErrNoShortUrlAvailable
+}
+
+func shortenResult(url models.Url, err error) models.ShortenResult {
+	switch {
+	case errors.Is(err, ErrAliasTaken):
+		return shortenFailure(http.StatusConflict, err)
+	case err != nil:
+		return shortenFailure(http.StatusInternalServerError, err)
+	default:
+		return models.ShortenResult{Status: http.StatusCreated, Url: &url}
+	}
+}
+
+func shortenFailure(status int, err error) models.ShortenResult {
+	return models.ShortenResult{Status: status, Error: err.Error(), ErrorCode: utils.ErrorCode(err)}
+}
@@ -0,0 +1,174 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kjj1998/url-shortener-go/internal/models"
+	"github.com/kjj1998/url-shortener-go/internal/repository"
+	"github.com/kjj1998/url-shortener-go/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func enterBatchTest(t *testing.T) *MockUrlRepository {
+	id := uint64(0)
+	utils.GenerateUniqueId = func() uint64 { id++; return id }
+	utils.ShortenUrl = func(id uint64) string { return "code" + strconv.FormatUint(id, 10) }
+	timeNow = func() time.Time { return time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC) }
+	t.Cleanup(func() { timeNow = time.Now })
+
+	mockRepo := new(MockUrlRepository)
+	repository.Client = mockRepo
+	return mockRepo
+}
+
+func batchRequest(contentType string, body *bytes.Buffer) (*httptest.ResponseRecorder, *gin.Context) {
+	rec := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(rec)
+	ctx.Request, _ = http.NewRequest(http.MethodPost, "/api/v1/data/shorten/batch", body)
+	ctx.Request.Header.Set("Content-Type", contentType)
+	return rec, ctx
+}
+
+func TestShortenBatch(t *testing.T) {
+	mockRepo := enterBatchTest(t)
+	createdAt := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	mockRepo.On("AddUrl", mock.Anything, mock.MatchedBy(func(url models.Url) bool { return url.ShortUrl == "spring-sale" })).
+		Return(nil).Once()
+	mockRepo.On("AddUrls", mock.Anything, []models.Url{
+		{Id: 1, ShortUrl: "code1", LongUrl: "http://example.com", CreatedAt: &createdAt, Domain: "example.com", Destination: "http://example.com"},
+		{Id: 3, ShortUrl: "code3", LongUrl: "http://example.org", CreatedAt: &createdAt, Domain: "example.org", Destination: "http://example.org"},
+	}).Return([]error{nil, repository.ErrShortUrlTaken}).Once()
+	// The taken generated shortened URL is generated again from a fresh id
+	mockRepo.On("AddUrl", mock.Anything, mock.MatchedBy(func(url models.Url) bool { return url.ShortUrl == "code4" })).
+		Return(nil).Once()
+
+	rec, ctx := batchRequest("application/json", bytes.NewBufferString(`[
+		{"longUrl":"http://example.com"},
+		{"longUrl":"http://example.net","alias":"spring-sale"},
+		{"longUrl":""},
+		{"longUrl":"http://example.net","alias":"spring-sale"},
+		{"longUrl":"http://example.org"},
+		{"longUrl":"javascript:alert(1)"}
+	]`))
+	ShortenBatch(ctx)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"results":[
+		{"status":201,"url":{"id":1,"shortUrl":"code1","longUrl":"http://example.com","createdAt":"2025-01-01T00:00:00Z"}},
+		{"status":201,"url":{"id":2,"shortUrl":"spring-sale","longUrl":"http://example.net","createdAt":"2025-01-01T00:00:00Z"}},
+		{"status":400,"error":"invalid parameter names in json body"},
+		{"status":409,"error":"alias is already taken"},
+		{"status":201,"url":{"id":4,"shortUrl":"code4","longUrl":"http://example.org","createdAt":"2025-01-01T00:00:00Z"}},
+		{"status":400,"error":"longUrl scheme must be one of http, https","errorCode":"url_scheme_not_allowed"}
+	]}`, rec.Body.String())
+	mockRepo.AssertExpectations(t)
+}
+
+func TestShortenBatchReuse(t *testing.T) {
+	enterBatchTest(t)
+	repository.Client = repository.NewMemoryClient()
+	existing := models.Url{Id: 100, ShortUrl: "existing", LongUrl: "http://example.com", Destination: "http://example.com"}
+	assert.NoError(t, repository.Client.AddUrl(context.Background(), existing))
+
+	rec, ctx := batchRequest("text/csv", bytes.NewBufferString("longUrl,reuse\n"+
+		"HTTP://EXAMPLE.com,true\nhttp://example.org,true\nhttp://example.org:80,1\nhttp://example.org,\n"))
+	ShortenBatch(ctx)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"results":[
+		{"status":200,"url":{"id":100,"shortUrl":"existing","longUrl":"http://example.com"}},
+		{"status":201,"url":{"id":1,"shortUrl":"code1","longUrl":"http://example.org","createdAt":"2025-01-01T00:00:00Z"}},
+		{"status":200,"url":{"id":1,"shortUrl":"code1","longUrl":"http://example.org","createdAt":"2025-01-01T00:00:00Z"}},
+		{"status":201,"url":{"id":2,"shortUrl":"code2","longUrl":"http://example.org","createdAt":"2025-01-01T00:00:00Z"}}
+	]}`, rec.Body.String())
+}
+
+func TestShortenBatchCsv(t *testing.T) {
+	csv := "longUrl,maxClicks,tags\nhttp://example.com,1,News;go\nhttp://example.org,many,\n"
+
+	var form bytes.Buffer
+	writer := multipart.NewWriter(&form)
+	file, _ := writer.CreateFormFile("file", "urls.csv")
+	_, _ = file.Write([]byte(csv))
+	_ = writer.Close()
+
+	requests := map[string]func() (*httptest.ResponseRecorder, *gin.Context){
+		"Body": func() (*httptest.ResponseRecorder, *gin.Context) {
+			return batchRequest("text/csv", bytes.NewBufferString(csv))
+		},
+		"Multipart file": func() (*httptest.ResponseRecorder, *gin.Context) {
+			return batchRequest(writer.FormDataContentType(), bytes.NewBuffer(form.Bytes()))
+		},
+	}
+
+	for name, request := range requests {
+		mockRepo := enterBatchTest(t)
+		mockRepo.On("AddUrls", mock.Anything, mock.MatchedBy(func(urls []models.Url) bool {
+			return len(urls) == 1 && urls[0].MaxClicks == 1 && assert.ObjectsAreEqual([]string{"go", "news"}, urls[0].Tags)
+		})).Return([]error{nil}).Once()
+
+		rec, ctx := request()
+		ShortenBatch(ctx)
+
+		assert.Equal(t, http.StatusOK, rec.Code, name)
+		assert.JSONEq(t, `{"results":[
+			{"status":201,"url":{"id":1,"shortUrl":"code1","longUrl":"http://example.com","createdAt":"2025-01-01T00:00:00Z","maxClicks":1,"tags":["go","news"]}},
+			{"status":400,"error":"invalid maxClicks: strconv.ParseInt: parsing \"many\": invalid syntax"}
+		]}`, rec.Body.String(), name)
+		mockRepo.AssertExpectations(t)
+	}
+}
+
+func TestShortenBatchInvalid(t *testing.T) {
+	tests := []struct {
+		name         string
+		contentType  string
+		body         string
+		expectedBody string
+	}{
+		{
+			name:         "Empty batch",
+			contentType:  "application/json",
+			body:         `[]`,
+			expectedBody: `{"code":400, "message":"between 1 and 1000 urls may be shortened at once"}`,
+		},
+		{
+			name:         "Not an array",
+			contentType:  "application/json",
+			body:         `{"longUrl":"http://example.com"}`,
+			expectedBody: `{"code":400, "message":"json: cannot unmarshal object into Go value of type []models.LongUrl"}`,
+		},
+		{
+			name:         "Unknown csv column",
+			contentType:  "text/csv",
+			body:         "longUrl,shortUrl\nhttp://example.com,abc\n",
+			expectedBody: `{"code":400, "message":"csv column \"shortUrl\" is not a field of a url to shorten"}`,
+		},
+		{
+			name:         "Csv without longUrl",
+			contentType:  "text/csv",
+			body:         "alias\nabc\n",
+			expectedBody: `{"code":400, "message":"csv header must have a longUrl column"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		mockRepo := enterBatchTest(t)
+
+		rec, ctx := batchRequest(tt.contentType, bytes.NewBufferString(tt.body))
+		ShortenBatch(ctx)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code, tt.name)
+		assert.JSONEq(t, tt.expectedBody, rec.Body.String(), tt.name)
+		mockRepo.AssertExpectations(t)
+	}
+}
@@ -3,25 +3,82 @@ package routes
 import (
 	"context"
 	"errors"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/kjj1998/url-shortener-go/constants"
+	"github.com/kjj1998/url-shortener-go/internal/config"
 	"github.com/kjj1998/url-shortener-go/internal/models"
 	"github.com/kjj1998/url-shortener-go/internal/repository"
 	"github.com/kjj1998/url-shortener-go/internal/utils"
 )
 
+// timeNow is replaced in tests to fix the creation time of shortened URLs
+var timeNow = time.Now
+
+// trackingParams are the query parameters stripped from long URLs before they are stored, none unless
+// STRIP_TRACKING_PARAMS is set
+var trackingParams = configuredTrackingParams()
+
+// destinationPolicy is the policy long URLs have to follow before they are canonicalized and stored
+var destinationPolicy = models.DestinationPolicy{
+	Schemes:      configuredUrlSchemes(),
+	MaxLength:    config.Int("LONG_URL_MAX_LENGTH", constants.LongUrlMaxLength),
+	BlockPrivate: config.Bool("BLOCK_PRIVATE_DESTINATIONS", false),
+	LookupIPAddr: net.DefaultResolver.LookupIPAddr,
+}
+
+func configuredUrlSchemes() []string {
+	schemes := config.List("URL_SCHEMES")
+	if schemes == nil {
+		schemes = strings.Split(constants.UrlSchemes, ",")
+	}
+	for i, scheme := range schemes {
+		schemes[i] = strings.ToLower(scheme)
+	}
+	return schemes
+}
+
+func configuredTrackingParams() []string {
+	if !config.Bool("STRIP_TRACKING_PARAMS", false) {
+		return nil
+	}
+	if params := config.List("TRACKING_PARAMS"); params != nil {
+		return params
+	}
+	return models.DefaultTrackingParams
+}
+
+var (
+	ErrNoShortUrlAvailable = errors.New("couldn't generate a shortened url that is not taken")
+	ErrAliasTaken          = errors.New("alias is already taken")
+)
+
 // GenerateShortenedUrl godoc
 // @Summary generate shortened urls
 // @Schemes
 // @Description generate shortened urls
+// @Description with reuse set, the shortened url the owner already has for the same destination is returned instead.
+// @Description Repeats of a request with the same Idempotency-Key are answered with the original response
 // @Tags example
 // @Accept json
 // @Produce json
 // @Param longUrl body models.LongUrl true	"Add URL for shortening"
+// @Param Idempotency-Key header string false "Unique key of the request, to retry it safely"
+// @Success 200 {object} models.Url
 // @Success 201 {object} models.Url
 // @Failure 400 {object} utils.HTTPError
+// @Failure 401 {object} utils.HTTPError
+// @Failure 403 {object} utils.HTTPError
+// @Failure 409 {object} utils.HTTPError
+// @Failure 422 {object} utils.HTTPError
 // @Failure	500 {object} utils.HTTPError
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /data/shorten [post]
 func GenerateShortenedUrl(g *gin.Context) {
 	var longUrlForShortening models.LongUrl
@@ -34,19 +91,45 @@ func GenerateShortenedUrl(g *gin.Context) {
 		utils.NewError(g, http.StatusBadRequest, err)
 		return
 	}
+	canonical, err := canonicalLongUrl(context.TODO(), longUrlForShortening.LongUrl)
+	if err != nil {
+		utils.NewError(g, http.StatusBadRequest, err)
+		return
+	}
+	if canonical, err = resolveSelfReference(context.TODO(), longUrlForShortening.Alias, canonical); err != nil {
+		utils.NewError(g, urlErrorStatus(err), err)
+		return
+	}
+	if err := domainPolicy.Check(models.DomainOf(canonical)); err != nil {
+		utils.NewError(g, http.StatusForbidden, err)
+		return
+	}
+	longUrlForShortening.LongUrl = canonical
 
-	longUrl := longUrlForShortening.LongUrl
-	id := utils.GenerateUniqueId()
-	shortUrl := utils.ShortenUrl(id)
+	url, err := newUrl(g, longUrlForShortening, timeNow())
+	if err != nil {
+		utils.NewError(g, http.StatusInternalServerError, err)
+		return
+	}
 
-	shortenedUrl := models.Url{
-		Id:       id,
-		ShortUrl: shortUrl,
-		LongUrl:  longUrl,
+	if longUrlForShortening.Reuse {
+		reused, err := repository.Client.RetrieveReusableUrl(context.TODO(), url.Owner, url.Destination)
+		if err != nil {
+			utils.NewError(g, http.StatusInternalServerError, err)
+			return
+		}
+		if reused.ShortUrl != "" {
+			g.IndentedJSON(http.StatusOK, reused)
+			return
+		}
 	}
 
-	err := repository.Client.AddUrl(context.TODO(), shortenedUrl)
+	shortenedUrl, err := addUrl(context.TODO(), url, longUrlForShortening.Alias)
 
+	if errors.Is(err, ErrAliasTaken) {
+		utils.NewError(g, http.StatusConflict, err)
+		return
+	}
 	if err != nil {
 		utils.NewError(g, http.StatusInternalServerError, errors.New(err.Error()))
 		return
@@ -55,18 +138,91 @@ func GenerateShortenedUrl(g *gin.Context) {
 	g.IndentedJSON(http.StatusCreated, shortenedUrl)
 }
 
+// canonicalLongUrl validates a long URL against destinationPolicy and returns its canonical form
+func canonicalLongUrl(ctx context.Context, longUrl string) (string, error) {
+	if err := destinationPolicy.Validate(ctx, longUrl); err != nil {
+		return "", err
+	}
+	return models.CanonicalUrl(longUrl, trackingParams)
+}
+
+// newUrl returns the shortened URL to store for a validated request with a canonical long URL made at now, without
+// its Id and ShortUrl
+func newUrl(g *gin.Context, request models.LongUrl, now time.Time) (models.Url, error) {
+	createdAt := now.UTC().Truncate(time.Second)
+	url := models.Url{
+		LongUrl:     request.LongUrl,
+		ExpiresAt:   request.Expiry(now),
+		MaxClicks:   request.MaxClicks,
+		CreatedAt:   &createdAt,
+		Owner:       g.GetString(constants.OwnerContextKey),
+		Domain:      models.DomainOf(request.LongUrl),
+		Destination: models.DestinationOf(request.LongUrl),
+		Title:       request.Title,
+		Description: request.Description,
+		Notes:       request.Notes,
+		Tags:        models.NormalizeTags(request.Tags),
+	}
+	if request.Password != "" {
+		passwordHash, err := hashPassword(request.Password)
+		if err != nil {
+			return models.Url{}, err
+		}
+		url.PasswordHash = passwordHash
+	}
+	return url, nil
+}
+
+// addUrl stores url under alias, or under a shortened URL generated from a fresh id when there is no alias
+// A generated shortened URL is only taken if ids ever collide or it spells a reserved word, so it is retried with another id
+// Returns ErrAliasTaken when the alias is in use
+func addUrl(ctx context.Context, url models.Url, alias string) (models.Url, error) {
+	for attempt := 1; ; attempt++ {
+		url.Id = utils.GenerateUniqueId()
+		url.ShortUrl = alias
+		if alias == "" {
+			url.ShortUrl = utils.ShortenUrl(url.Id)
+		}
+
+		err := repository.ErrShortUrlTaken
+		if !models.IsReserved(url.ShortUrl) {
+			err = repository.Client.AddUrl(ctx, url)
+		}
+		switch {
+		case !errors.Is(err, repository.ErrShortUrlTaken):
+			return url, err
+		case alias != "":
+			return url, ErrAliasTaken
+		case attempt == constants.ShortenUrlAttempts:
+			return url, ErrNoShortUrlAvailable
+		}
+	}
+}
+
 // RedirectShortenedUrl godoc
 // @Summary redirect shortened urls to the actual urls
 // @Schemes
 // @Description redirect shortened urls to the actual urls
+// @Description password-protected urls take their password from the X-Link-Password header, basic auth,
+// @Description or a form posting it as password, and answer with that form when it is missing.
+// @Description Chains of short urls of this service are followed to their destination, loops answer 508
 // @Tags redirect
 // @Accept json
-// @Produce json
+// @Produce json,html
 // @Param shortUrl path string false "Short URL"
+// @Param X-Link-Password header string false "Password of a password-protected short URL"
+// @Param X-Redirect-Hops header int false "Redirects between short URLs made before this one"
+// @Success 303
 // @Success 307
+// @Failure 401 {object} utils.HTTPError
+// @Failure 403 {object} utils.HTTPError
 // @Failure 404 {object} utils.HTTPError
+// @Failure 410 {object} utils.HTTPError
+// @Failure 429 {object} utils.HTTPError
 // @Failure 500 {object} utils.HTTPError
+// @Failure 508 {object} utils.HTTPError
 // @Router /{shortUrl} [get]
+// @Router /{shortUrl} [post]
 func RedirectShortenedUrl(g *gin.Context) {
 	shortUrl := g.Param("shortUrl")
 
@@ -74,17 +230,78 @@ func RedirectShortenedUrl(g *gin.Context) {
 		g.IndentedJSON(http.StatusBadRequest, gin.H{"error": "shortUrl parameter is required"})
 		return
 	}
+	if models.IsReserved(shortUrl) {
+		utils.NewError(g, http.StatusNotFound, errors.New("URL not found"))
+		return
+	}
+	// Redirects forwarded between shortened URLs by other services carry the hops made so far
+	hops, _ := strconv.Atoi(g.GetHeader(constants.RedirectHopsHeader))
+	if hops >= constants.RedirectMaxHops {
+		utils.NewError(g, http.StatusLoopDetected, ErrRedirectLoop)
+		return
+	}
 
-	longUrl, err := repository.Client.RetrieveUrl(context.TODO(), shortUrl)
+	url, err := repository.Client.RetrieveUrl(context.TODO(), shortUrl)
 
 	if err != nil {
 		utils.NewError(g, http.StatusInternalServerError, err)
 		return
 	}
-	if longUrl == "" {
+	if url.ShortUrl == "" {
 		utils.NewError(g, http.StatusNotFound, errors.New("URL not found"))
 		return
 	}
+	if url.Deleted() {
+		utils.NewError(g, http.StatusGone, errors.New("URL has been deleted"))
+		return
+	}
+	// Expired entries linger until the store reaps them, so expiry is enforced here
+	if url.Expired(time.Now()) {
+		utils.NewError(g, http.StatusGone, errors.New("URL has expired"))
+		return
+	}
+	// The domain policy may have changed since the shortened URL was created
+	if err := domainPolicy.Check(models.DomainOf(url.LongUrl)); err != nil {
+		utils.NewError(g, http.StatusForbidden, err)
+		return
+	}
+	// Chains of shortened URLs created before self references were refused are followed here, collapsing them
+	destination, hops, err := followSelfReferences(context.TODO(), shortUrl, url.LongUrl, hops)
+	if errors.Is(err, ErrRedirectLoop) {
+		utils.NewError(g, http.StatusLoopDetected, err)
+		return
+	}
+	if err != nil {
+		utils.NewError(g, http.StatusInternalServerError, err)
+		return
+	}
+	if destination != url.LongUrl {
+		if err := domainPolicy.Check(models.DomainOf(destination)); err != nil {
+			utils.NewError(g, http.StatusForbidden, err)
+			return
+		}
+	}
+	if url.PasswordHash != "" && !authorizeProtectedUrl(g, url) {
+		return
+	}
+	// The click is counted by the store, the Clicks of a cached entry may be stale
+	if url.MaxClicks > 0 {
+		err = repository.Client.RecordClick(context.TODO(), shortUrl)
+		if errors.Is(err, repository.ErrClicksExhausted) {
+			utils.NewError(g, http.StatusGone, errors.New("URL has reached its click limit"))
+			return
+		}
+		if err != nil {
+			utils.NewError(g, http.StatusInternalServerError, err)
+			return
+		}
+	}
 
-	g.Redirect(http.StatusTemporaryRedirect, longUrl)
+	g.Header(constants.RedirectHopsHeader, strconv.Itoa(hops+1))
+	// A password posted from the form is followed up with a GET of the long URL
+	if g.Request.Method == http.MethodPost {
+		g.Redirect(http.StatusSeeOther, destination)
+		return
+	}
+	g.Redirect(http.StatusTemporaryRedirect, destination)
 }
@@ -6,12 +6,14 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
-	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
-	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/gin-gonic/gin"
 	"github.com/kjj1998/url-shortener-go/constants"
 	"github.com/kjj1998/url-shortener-go/internal/models"
@@ -21,69 +23,227 @@ import (
 	"github.com/stretchr/testify/mock"
 )
 
-type MockTableClient struct {
+type MockUrlRepository struct {
+	repository.UrlRepository
 	mock.Mock
 }
 
-func (m *MockTableClient) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
-	args := m.Called(ctx, params)
-	return args.Get(0).(*dynamodb.PutItemOutput), args.Error(1)
+func (m *MockUrlRepository) AddUrl(ctx context.Context, url models.Url) error {
+	args := m.Called(ctx, url)
+	return args.Error(0)
 }
 
-func (m *MockTableClient) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
-	args := m.Called(ctx, params)
-	return args.Get(0).(*dynamodb.QueryOutput), args.Error(1)
+func (m *MockUrlRepository) AddUrls(ctx context.Context, urls []models.Url) []error {
+	args := m.Called(ctx, urls)
+	return args.Get(0).([]error)
 }
 
-func TestGenerateShortenedUrl(t *testing.T) {
-	mockRepo := new(MockTableClient)
-	repository.Client = repository.TableClient{
-		DynamoDbClient: mockRepo,
-		TableName:      constants.TableName,
+func (m *MockUrlRepository) RetrieveUrl(ctx context.Context, shortUrl string) (models.Url, error) {
+	args := m.Called(ctx, shortUrl)
+	return args.Get(0).(models.Url), args.Error(1)
+}
+
+func (m *MockUrlRepository) UpdateUrl(ctx context.Context, shortUrl string, update models.UrlUpdate) (models.Url, error) {
+	args := m.Called(ctx, shortUrl, update)
+	return args.Get(0).(models.Url), args.Error(1)
+}
+
+func (m *MockUrlRepository) DeleteUrl(ctx context.Context, shortUrl string) error {
+	args := m.Called(ctx, shortUrl)
+	return args.Error(0)
+}
+
+func (m *MockUrlRepository) ListUrls(ctx context.Context, filter models.UrlFilter, cursor string, limit int) (models.UrlPage, error) {
+	args := m.Called(ctx, filter, cursor, limit)
+	return args.Get(0).(models.UrlPage), args.Error(1)
+}
+
+func (m *MockUrlRepository) MergeTags(ctx context.Context, merge models.TagMerge) (int, error) {
+	args := m.Called(ctx, merge)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockUrlRepository) RecordClick(ctx context.Context, shortUrl string) error {
+	args := m.Called(ctx, shortUrl)
+	return args.Error(0)
+}
+
+// ScanUrls calls fn with the urls returned by the mock before returning its error
+func (m *MockUrlRepository) ScanUrls(ctx context.Context, fn func(url models.Url) error) error {
+	args := m.Called(ctx)
+	for _, url := range args.Get(0).([]models.Url) {
+		if err := fn(url); err != nil {
+			return err
+		}
 	}
+	return args.Error(1)
+}
 
+func TestGenerateShortenedUrl(t *testing.T) {
 	utils.GenerateUniqueId = func() uint64 { return 2387497 }
 	utils.ShortenUrl = func(id uint64) string { return "NWER425d" }
+	timeNow = func() time.Time { return time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC) }
+	defer func() { timeNow = time.Now }()
+	expiresAt := time.Date(2099, 1, 1, 0, 0, 0, 0, time.UTC)
+	expiredAt := time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)
 
 	tests := []struct {
 		name           string
 		payload        interface{}
-		mockRepoError  error
+		mockRepoErrors []error
 		expectedStatus int
 		expectedBody   string
 	}{
 		{
 			name:           "Valid request",
 			payload:        models.LongUrl{LongUrl: "http://example.com"},
-			mockRepoError:  nil,
+			mockRepoErrors: []error{nil},
+			expectedStatus: http.StatusCreated,
+			expectedBody:   `{"id":2387497,"shortUrl":"NWER425d","longUrl":"http://example.com","createdAt":"2025-01-01T00:00:00Z"}`,
+		},
+		{
+			name:           "Shortened url taken once",
+			payload:        models.LongUrl{LongUrl: "http://example.com"},
+			mockRepoErrors: []error{repository.ErrShortUrlTaken, nil},
+			expectedStatus: http.StatusCreated,
+			expectedBody:   `{"id":2387497,"shortUrl":"NWER425d","longUrl":"http://example.com","createdAt":"2025-01-01T00:00:00Z"}`,
+		},
+		{
+			name:           "Shortened url always taken",
+			payload:        models.LongUrl{LongUrl: "http://example.com"},
+			mockRepoErrors: []error{repository.ErrShortUrlTaken, repository.ErrShortUrlTaken, repository.ErrShortUrlTaken},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"code":500, "message":"couldn't generate a shortened url that is not taken"}`,
+		},
+		{
+			name:           "Internal error",
+			payload:        models.LongUrl{LongUrl: "http://example.com"},
+			mockRepoErrors: []error{errors.New("simulated DynamoDB error")},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"code":500, "message":"simulated DynamoDB error"}`,
+		},
+		{
+			name:           "Valid alias",
+			payload:        models.LongUrl{LongUrl: "http://example.com", Alias: "spring-sale"},
+			mockRepoErrors: []error{nil},
+			expectedStatus: http.StatusCreated,
+			expectedBody:   `{"id":2387497,"shortUrl":"spring-sale","longUrl":"http://example.com","createdAt":"2025-01-01T00:00:00Z"}`,
+		},
+		{
+			name:           "Alias taken",
+			payload:        models.LongUrl{LongUrl: "http://example.com", Alias: "spring-sale"},
+			mockRepoErrors: []error{repository.ErrShortUrlTaken},
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `{"code":409, "message":"alias is already taken"}`,
+		},
+		{
+			name:           "Alias too short",
+			payload:        models.LongUrl{LongUrl: "http://example.com", Alias: "ab"},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"code":400, "message":"alias must be between 3 and 32 characters long"}`,
+		},
+		{
+			name:           "Alias with invalid characters",
+			payload:        models.LongUrl{LongUrl: "http://example.com", Alias: "spring/sale"},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"code":400, "message":"alias may only contain letters, digits, '-' and '_'"}`,
+		},
+		{
+			name:           "Reserved alias",
+			payload:        models.LongUrl{LongUrl: "http://example.com", Alias: "Health"},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"code":400, "message":"alias is a reserved word"}`,
+		},
+		{
+			name:           "Valid expiry",
+			payload:        models.LongUrl{LongUrl: "http://example.com", ExpiresAt: &expiresAt},
+			mockRepoErrors: []error{nil},
+			expectedStatus: http.StatusCreated,
+			expectedBody:   `{"id":2387497,"shortUrl":"NWER425d","longUrl":"http://example.com","createdAt":"2025-01-01T00:00:00Z","expiresAt":"2099-01-01T00:00:00Z"}`,
+		},
+		{
+			name:           "Expiry in the past",
+			payload:        models.LongUrl{LongUrl: "http://example.com", ExpiresAt: &expiredAt},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"code":400, "message":"expiresAt must be in the future"}`,
+		},
+		{
+			name:           "Expiry and ttl",
+			payload:        models.LongUrl{LongUrl: "http://example.com", ExpiresAt: &expiresAt, TtlSeconds: 60},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"code":400, "message":"only one of expiresAt and ttlSeconds may be set"}`,
+		},
+		{
+			name:           "Negative ttl",
+			payload:        models.LongUrl{LongUrl: "http://example.com", TtlSeconds: -1},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"code":400, "message":"ttlSeconds must be positive"}`,
+		},
+		{
+			name:           "Valid max clicks",
+			payload:        models.LongUrl{LongUrl: "http://example.com", MaxClicks: 1},
+			mockRepoErrors: []error{nil},
 			expectedStatus: http.StatusCreated,
-			expectedBody:   `{"id":2387497,"shortUrl":"NWER425d","longUrl":"http://example.com"}`,
+			expectedBody:   `{"id":2387497,"shortUrl":"NWER425d","longUrl":"http://example.com","createdAt":"2025-01-01T00:00:00Z","maxClicks":1}`,
+		},
+		{
+			name:           "Negative max clicks",
+			payload:        models.LongUrl{LongUrl: "http://example.com", MaxClicks: -1},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"code":400, "message":"maxClicks must be positive"}`,
+		},
+		{
+			name:           "Valid password",
+			payload:        models.LongUrl{LongUrl: "http://example.com", Password: "hunter2"},
+			mockRepoErrors: []error{nil},
+			expectedStatus: http.StatusCreated,
+			expectedBody:   `{"id":2387497,"shortUrl":"NWER425d","longUrl":"http://example.com","createdAt":"2025-01-01T00:00:00Z"}`,
+		},
+		{
+			name:           "Password too long",
+			payload:        models.LongUrl{LongUrl: "http://example.com", Password: strings.Repeat("a", 73)},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"code":400, "message":"password must be at most 72 bytes long"}`,
+		},
+		{
+			name:           "Valid metadata",
+			payload:        models.LongUrl{LongUrl: "http://example.com", Title: "Spring sale", Tags: []string{"Marketing", "spring", " marketing"}},
+			mockRepoErrors: []error{nil},
+			expectedStatus: http.StatusCreated,
+			expectedBody:   `{"id":2387497,"shortUrl":"NWER425d","longUrl":"http://example.com","createdAt":"2025-01-01T00:00:00Z","title":"Spring sale","tags":["marketing","spring"]}`,
+		},
+		{
+			name:           "Title too long",
+			payload:        models.LongUrl{LongUrl: "http://example.com", Title: strings.Repeat("a", 201)},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"code":400, "message":"title must be at most 200 characters long"}`,
+		},
+		{
+			name:           "Tag with comma",
+			payload:        models.LongUrl{LongUrl: "http://example.com", Tags: []string{"spring,sale"}},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"code":400, "message":"tags may not contain ','"}`,
 		},
 		{
 			name:           "Failed JSON binding",
 			payload:        "124",
-			mockRepoError:  nil,
 			expectedStatus: http.StatusBadRequest,
 			expectedBody:   `{"code":400, "message":"json: cannot unmarshal number into Go value of type models.LongUrl"}`,
 		},
 		{
 			name:           "Validation error",
 			payload:        map[string]string{"shortUrl": "fdsfsdfdsf"},
-			mockRepoError:  nil,
 			expectedStatus: http.StatusBadRequest,
 			expectedBody:   `{"code":400, "message":"invalid parameter names in json body"}`,
 		},
 	}
 
 	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			if tt.mockRepoError == nil {
-				mockRepo.On("AddUrl", mock.Anything, mock.Anything).Return(nil).Once()
-				mockRepo.On("PutItem", mock.Anything, mock.Anything).Return(&dynamodb.PutItemOutput{}, nil).Once()
-			} else {
-				mockRepo.On("AddUrl", mock.Anything, mock.Anything).Return(tt.mockRepoError).Once()
-			}
-		})
+		mockRepo := new(MockUrlRepository)
+		repository.Client = mockRepo
+		for _, err := range tt.mockRepoErrors {
+			mockRepo.On("AddUrl", mock.Anything, mock.Anything).Return(err).Once()
+		}
 
 		rec := httptest.NewRecorder()
 		ctx, _ := gin.CreateTestContext(rec)
@@ -100,20 +260,195 @@ func TestGenerateShortenedUrl(t *testing.T) {
 
 		GenerateShortenedUrl(ctx)
 
-		assert.Equal(t, tt.expectedStatus, rec.Code)
-		assert.JSONEq(t, tt.expectedBody, rec.Body.String())
+		assert.Equal(t, tt.expectedStatus, rec.Code, tt.name)
+		assert.JSONEq(t, tt.expectedBody, rec.Body.String(), tt.name)
+		mockRepo.AssertExpectations(t)
+	}
+}
+
+func TestGenerateReusedShortenedUrl(t *testing.T) {
+	var id atomic.Uint64
+	utils.GenerateUniqueId = func() uint64 { return id.Add(1) }
+	utils.ShortenUrl = func(id uint64) string { return fmt.Sprintf("code%d", id) }
+	repository.Client = repository.NewMemoryClient()
+
+	tests := []struct {
+		name             string
+		payload          models.LongUrl
+		expectedStatus   int
+		expectedShortUrl string
+		expectedBody     string
+	}{
+		{
+			name:             "First reuse",
+			payload:          models.LongUrl{LongUrl: "https://example.com/a", Reuse: true},
+			expectedStatus:   http.StatusCreated,
+			expectedShortUrl: "code1",
+		},
+		{
+			name:             "Reuse of the same destination",
+			payload:          models.LongUrl{LongUrl: "HTTPS://Example.COM:443/a", Reuse: true},
+			expectedStatus:   http.StatusOK,
+			expectedShortUrl: "code1",
+		},
+		{
+			name:             "Without reuse",
+			payload:          models.LongUrl{LongUrl: "https://example.com/a"},
+			expectedStatus:   http.StatusCreated,
+			expectedShortUrl: "code2",
+		},
+		{
+			name:             "Reuse of another destination",
+			payload:          models.LongUrl{LongUrl: "https://example.com/A", Reuse: true},
+			expectedStatus:   http.StatusCreated,
+			expectedShortUrl: "code3",
+		},
+		{
+			name:           "Reuse with alias",
+			payload:        models.LongUrl{LongUrl: "https://example.com/a", Alias: "spring-sale", Reuse: true},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"code":400, "message":"reuse can not be combined with alias, expiresAt, ttlSeconds, maxClicks or password"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(rec)
+		requestBody, _ := json.Marshal(tt.payload)
+		ctx.Request = httptest.NewRequest(http.MethodPost, "/shorten", bytes.NewBuffer(requestBody))
+		ctx.Request.Header.Set("Content-Type", "application/json")
+
+		GenerateShortenedUrl(ctx)
+
+		assert.Equal(t, tt.expectedStatus, rec.Code, tt.name)
+		if tt.expectedBody != "" {
+			assert.JSONEq(t, tt.expectedBody, rec.Body.String(), tt.name)
+			continue
+		}
+		var url models.Url
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &url))
+		assert.Equal(t, tt.expectedShortUrl, url.ShortUrl, tt.name)
+	}
+}
+
+func TestGenerateCanonicalShortenedUrl(t *testing.T) {
+	var id atomic.Uint64
+	utils.GenerateUniqueId = func() uint64 { return id.Add(1) }
+	utils.ShortenUrl = func(id uint64) string { return fmt.Sprintf("code%d", id) }
+	repository.Client = repository.NewMemoryClient()
+	trackingParams = []string{"utm_*", "fbclid"}
+	defer func() { trackingParams = configuredTrackingParams() }()
+
+	tests := []struct {
+		name            string
+		longUrl         string
+		expectedStatus  int
+		expectedLongUrl string
+	}{
+		{
+			name:            "Non canonical url",
+			longUrl:         "HTTP://Bücher.Example:80/a/./b/../c",
+			expectedStatus:  http.StatusCreated,
+			expectedLongUrl: "http://xn--bcher-kva.example/a/c",
+		},
+		{
+			name:            "Tracking params",
+			longUrl:         "https://example.com/?utm_source=news&q=1&fbclid=abc",
+			expectedStatus:  http.StatusCreated,
+			expectedLongUrl: "https://example.com/?q=1",
+		},
+		{
+			name:           "Invalid host",
+			longUrl:        "http://example.com:port/",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(rec)
+		requestBody, _ := json.Marshal(models.LongUrl{LongUrl: tt.longUrl})
+		ctx.Request = httptest.NewRequest(http.MethodPost, "/shorten", bytes.NewBuffer(requestBody))
+		ctx.Request.Header.Set("Content-Type", "application/json")
+
+		GenerateShortenedUrl(ctx)
+
+		assert.Equal(t, tt.expectedStatus, rec.Code, tt.name)
+		var url models.Url
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &url))
+		assert.Equal(t, tt.expectedLongUrl, url.LongUrl, tt.name)
+	}
+}
+
+func TestGenerateShortenedUrlDestinationPolicy(t *testing.T) {
+	repository.Client = repository.NewMemoryClient()
+	destinationPolicy.BlockPrivate = true
+	destinationPolicy.LookupIPAddr = func(ctx context.Context, host string) ([]net.IPAddr, error) {
+		return []net.IPAddr{{IP: net.ParseIP("10.0.0.7")}}, nil
+	}
+	defer func() {
+		destinationPolicy.BlockPrivate = false
+		destinationPolicy.LookupIPAddr = net.DefaultResolver.LookupIPAddr
+	}()
+
+	tests := []struct {
+		name         string
+		longUrl      string
+		expectedBody string
+	}{
+		{
+			name:         "File scheme",
+			longUrl:      "file:///etc/passwd",
+			expectedBody: `{"code":400,"message":"longUrl scheme must be one of http, https","errorCode":"url_scheme_not_allowed"}`,
+		},
+		{
+			name:         "Missing host",
+			longUrl:      "https:///a",
+			expectedBody: `{"code":400,"message":"longUrl must have a host","errorCode":"url_host_missing"}`,
+		},
+		{
+			name:         "Control character",
+			longUrl:      "https://example.com/\r\nSet-Cookie:a=b",
+			expectedBody: `{"code":400,"message":"longUrl may not contain whitespace or control characters","errorCode":"url_control_character"}`,
+		},
+		{
+			name:         "Too long",
+			longUrl:      "https://example.com/" + strings.Repeat("a", constants.LongUrlMaxLength),
+			expectedBody: `{"code":400,"message":"longUrl must be at most 2048 characters long","errorCode":"url_too_long"}`,
+		},
+		{
+			name:         "Private destination",
+			longUrl:      "https://intranet.example.com/",
+			expectedBody: `{"code":400,"message":"longUrl may not point to a private, loopback or link-local address","errorCode":"url_private_address"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(rec)
+		requestBody, _ := json.Marshal(models.LongUrl{LongUrl: tt.longUrl})
+		ctx.Request = httptest.NewRequest(http.MethodPost, "/shorten", bytes.NewBuffer(requestBody))
+		ctx.Request.Header.Set("Content-Type", "application/json")
+
+		GenerateShortenedUrl(ctx)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code, tt.name)
+		assert.JSONEq(t, tt.expectedBody, rec.Body.String(), tt.name)
 	}
 }
 
 func TestRedirectShortenedUrl(t *testing.T) {
+	expiresAt := time.Now().Add(time.Hour)
+	expiredAt := time.Now().Add(-time.Second)
+
 	tests := []struct {
 		name             string
 		param            string
 		mockRepoError    error
 		expectedStatus   int
 		expectedLocation string
-		mockRepoResult   string
-		mockRepoId       uint64
+		mockRepoResult   models.Url
+		mockClickError   error
 	}{
 		{
 			name:             "Valid request",
@@ -121,7 +456,53 @@ func TestRedirectShortenedUrl(t *testing.T) {
 			mockRepoError:    nil,
 			expectedStatus:   http.StatusTemporaryRedirect,
 			expectedLocation: "http://example.com",
-			mockRepoResult:   "http://example.com",
+			mockRepoResult:   models.Url{Id: 2387497, ShortUrl: "NWER425d", LongUrl: "http://example.com"},
+		},
+		{
+			name:             "Not yet expired",
+			param:            "NWER425d",
+			mockRepoError:    nil,
+			expectedStatus:   http.StatusTemporaryRedirect,
+			expectedLocation: "http://example.com",
+			mockRepoResult:   models.Url{Id: 2387497, ShortUrl: "NWER425d", LongUrl: "http://example.com", ExpiresAt: &expiresAt},
+		},
+		{
+			name:             "Expired",
+			param:            "NWER425d",
+			mockRepoError:    nil,
+			expectedStatus:   http.StatusGone,
+			expectedLocation: "",
+			mockRepoResult:   models.Url{Id: 2387497, ShortUrl: "NWER425d", LongUrl: "http://example.com", ExpiresAt: &expiredAt},
+		},
+		{
+			name:             "Deleted",
+			param:            "NWER425d",
+			expectedStatus:   http.StatusGone,
+			expectedLocation: "",
+			mockRepoResult:   models.Url{Id: 2387497, ShortUrl: "NWER425d", LongUrl: "http://example.com", DeletedAt: &expiredAt},
+		},
+		{
+			name:             "Clicks left",
+			param:            "NWER425d",
+			expectedStatus:   http.StatusTemporaryRedirect,
+			expectedLocation: "http://example.com",
+			mockRepoResult:   models.Url{Id: 2387497, ShortUrl: "NWER425d", LongUrl: "http://example.com", MaxClicks: 1},
+		},
+		{
+			name:             "Clicks exhausted",
+			param:            "NWER425d",
+			expectedStatus:   http.StatusGone,
+			expectedLocation: "",
+			mockRepoResult:   models.Url{Id: 2387497, ShortUrl: "NWER425d", LongUrl: "http://example.com", MaxClicks: 1},
+			mockClickError:   repository.ErrClicksExhausted,
+		},
+		{
+			name:             "Click error",
+			param:            "NWER425d",
+			expectedStatus:   http.StatusInternalServerError,
+			expectedLocation: "",
+			mockRepoResult:   models.Url{Id: 2387497, ShortUrl: "NWER425d", LongUrl: "http://example.com", MaxClicks: 1},
+			mockClickError:   errors.New("simulated DynamoDB error"),
 		},
 		{
 			name:             "No parameter",
@@ -129,7 +510,7 @@ func TestRedirectShortenedUrl(t *testing.T) {
 			mockRepoError:    nil,
 			expectedStatus:   http.StatusBadRequest,
 			expectedLocation: "",
-			mockRepoResult:   "nil",
+			mockRepoResult:   models.Url{},
 		},
 		{
 			name:             "Internal error",
@@ -137,7 +518,7 @@ func TestRedirectShortenedUrl(t *testing.T) {
 			mockRepoError:    errors.New("simulated DynamoDB error"),
 			expectedStatus:   http.StatusInternalServerError,
 			expectedLocation: "",
-			mockRepoResult:   "nil",
+			mockRepoResult:   models.Url{},
 		},
 		{
 			name:             "URL not found",
@@ -145,49 +526,17 @@ func TestRedirectShortenedUrl(t *testing.T) {
 			mockRepoError:    nil,
 			expectedStatus:   http.StatusNotFound,
 			expectedLocation: "",
-			mockRepoResult:   "nil",
+			mockRepoResult:   models.Url{},
 		},
 	}
 
 	for _, tt := range tests {
-		mockRepo := new(MockTableClient)
-		repository.Client = repository.TableClient{
-			DynamoDbClient: mockRepo,
-			TableName:      constants.TableName,
-		}
+		mockRepo := new(MockUrlRepository)
+		repository.Client = mockRepo
 
-		if tt.mockRepoError != nil {
-			fmt.Println("test else")
-			mockRepo.On("Query", mock.Anything, mock.Anything).Return(&dynamodb.QueryOutput{}, tt.mockRepoError).Once()
-			mockRepo.On("RetrieveUrl", mock.Anything, tt.param).Return("", tt.mockRepoError).Once()
-		} else {
-			if tt.name == "Valid request" {
-				mockDynamoResponse := &dynamodb.QueryOutput{
-					Items: []map[string]types.AttributeValue{
-						{
-							"ShortUrl": &types.AttributeValueMemberS{Value: tt.param},
-							"LongUrl":  &types.AttributeValueMemberS{Value: tt.mockRepoResult},
-						},
-					},
-				}
-
-				mockRepo.On("Query", mock.Anything, mock.Anything).Return(mockDynamoResponse, tt.mockRepoError).Once()
-			} else if tt.name == "URL not found" {
-				mockDynamoResponse := &dynamodb.QueryOutput{
-					Items: []map[string]types.AttributeValue{
-						{
-							"ShortUrl": &types.AttributeValueMemberS{Value: tt.param},
-							"LongUrl":  &types.AttributeValueMemberS{Value: ""},
-						},
-					},
-				}
-
-				mockRepo.On("Query", mock.Anything, mock.Anything).Return(mockDynamoResponse, tt.mockRepoError).Once()
-			} else {
-				mockRepo.On("Query", mock.Anything, mock.Anything).Return(&dynamodb.QueryOutput{}, tt.mockRepoError).Once()
-			}
-
-			mockRepo.On("RetrieveUrl", mock.Anything, tt.param).Return(tt.mockRepoResult, tt.mockRepoError).Once()
+		mockRepo.On("RetrieveUrl", mock.Anything, tt.param).Return(tt.mockRepoResult, tt.mockRepoError).Once()
+		if tt.mockRepoResult.MaxClicks > 0 {
+			mockRepo.On("RecordClick", mock.Anything, tt.param).Return(tt.mockClickError).Once()
 		}
 
 		rec := httptest.NewRecorder()
@@ -202,7 +551,7 @@ func TestRedirectShortenedUrl(t *testing.T) {
 
 		RedirectShortenedUrl(ctx)
 
-		assert.Equal(t, tt.expectedStatus, rec.Code)
-		assert.Equal(t, tt.expectedLocation, rec.Header().Get("Location"))
+		assert.Equal(t, tt.expectedStatus, rec.Code, tt.name)
+		assert.Equal(t, tt.expectedLocation, rec.Header().Get("Location"), tt.name)
 	}
 }
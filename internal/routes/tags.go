@@ -0,0 +1,53 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kjj1998/url-shortener-go/internal/models"
+	"github.com/kjj1998/url-shortener-go/internal/repository"
+	"github.com/kjj1998/url-shortener-go/internal/utils"
+)
+
+// MergeTags godoc
+// @Summary merge or rename tags
+// @Schemes
+// @Description replace the tags in from with the tag in to on every shortened url, renaming a tag when from holds only it.
+// @Description Only the shortened urls of the caller are changed, unless they are an admin
+// @Tags manage
+// @Accept json
+// @Produce json
+// @Param merge body models.TagMerge true "Tags to merge"
+// @Success 200 {object} models.TagMergeResult
+// @Failure 400 {object} utils.HTTPError
+// @Failure 401 {object} utils.HTTPError
+// @Failure 403 {object} utils.HTTPError
+// @Failure 500 {object} utils.HTTPError
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /data/tags/merge [post]
+func MergeTags(g *gin.Context) {
+	var merge models.TagMerge
+	if err := g.ShouldBindJSON(&merge); err != nil {
+		utils.NewError(g, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := merge.Validation(); err != nil {
+		utils.NewError(g, http.StatusBadRequest, err)
+		return
+	}
+
+	if owner, restricted := restrictedOwner(g); restricted {
+		merge.Owner = owner
+	}
+
+	merged, err := repository.Client.MergeTags(context.TODO(), merge)
+	if err != nil {
+		utils.NewError(g, http.StatusInternalServerError, err)
+		return
+	}
+
+	g.IndentedJSON(http.StatusOK, models.TagMergeResult{Links: merged})
+}
@@ -0,0 +1,75 @@
+package routes
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kjj1998/url-shortener-go/internal/models"
+	"github.com/kjj1998/url-shortener-go/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestMergeTags(t *testing.T) {
+	tests := []struct {
+		name           string
+		payload        string
+		mockMerge      *models.TagMerge
+		mockRepoResult int
+		mockRepoError  error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Valid request",
+			payload:        `{"from":["golang","go-lang"],"to":"go"}`,
+			mockMerge:      &models.TagMerge{From: []string{"golang", "go-lang"}, To: "go"},
+			mockRepoResult: 3,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"links":3}`,
+		},
+		{
+			name:           "Internal error",
+			payload:        `{"from":["golang"],"to":"go"}`,
+			mockMerge:      &models.TagMerge{From: []string{"golang"}, To: "go"},
+			mockRepoError:  errors.New("simulated DynamoDB error"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"code":500, "message":"simulated DynamoDB error"}`,
+		},
+		{
+			name:           "No target",
+			payload:        `{"from":["golang"]}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"code":400, "message":"tags must be between 1 and 50 characters long"}`,
+		},
+		{
+			name:           "Merging into itself",
+			payload:        `{"from":["Go"],"to":"go"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"code":400, "message":"from must hold at least one tag other than to"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		mockRepo := new(MockUrlRepository)
+		repository.Client = mockRepo
+		if tt.mockMerge != nil {
+			mockRepo.On("MergeTags", mock.Anything, *tt.mockMerge).Return(tt.mockRepoResult, tt.mockRepoError).Once()
+		}
+
+		rec := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(rec)
+		ctx.Request, _ = http.NewRequest(http.MethodPost, "/api/v1/data/tags/merge", bytes.NewBufferString(tt.payload))
+		ctx.Request.Header.Set("Content-Type", "application/json")
+
+		MergeTags(ctx)
+
+		assert.Equal(t, tt.expectedStatus, rec.Code, tt.name)
+		assert.JSONEq(t, tt.expectedBody, rec.Body.String(), tt.name)
+		mockRepo.AssertExpectations(t)
+	}
+}
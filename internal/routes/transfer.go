@@ -0,0 +1,87 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kjj1998/url-shortener-go/internal/repository"
+	"github.com/kjj1998/url-shortener-go/internal/transfer"
+	"github.com/kjj1998/url-shortener-go/internal/utils"
+)
+
+// ExportErrorTrailer is the trailer naming the error that cut an export short, once its response has started
+const ExportErrorTrailer = "X-Export-Error"
+
+// ExportUrls godoc
+// @Summary export shortened urls
+// @Schemes
+// @Description stream every shortened url, deleted ones included, with all of its fields as JSON Lines or CSV.
+// @Description An export cut short by an error ends with the X-Export-Error trailer
+// @Tags admin
+// @Produce json,text/csv
+// @Param format query string false "jsonl or csv" default(jsonl)
+// @Success 200
+// @Failure 400 {object} utils.HTTPError
+// @Failure 401 {object} utils.HTTPError
+// @Failure 403 {object} utils.HTTPError
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /admin/export [get]
+func ExportUrls(g *gin.Context) {
+	format := transfer.Format(g.DefaultQuery("format", string(transfer.JsonLines)))
+	if err := format.Validate(); err != nil {
+		utils.NewError(g, http.StatusBadRequest, err)
+		return
+	}
+
+	g.Header("Content-Type", format.ContentType())
+	g.Header("Content-Disposition", `attachment; filename="links.`+string(format)+`"`)
+	g.Header("Trailer", ExportErrorTrailer)
+	g.Status(http.StatusOK)
+
+	exported, err := transfer.Export(context.TODO(), repository.Client, g.Writer, format)
+	if err != nil {
+		log.Printf("Couldn't export shortened urls after %d of them. Here's why: %v\n", exported, err)
+		g.Writer.Header().Set(ExportErrorTrailer, err.Error())
+	}
+}
+
+// ImportUrls godoc
+// @Summary import shortened urls
+// @Schemes
+// @Description import shortened urls exported as JSON Lines or CSV, keeping their ids and short urls.
+// @Description Urls already taken are skipped, overwritten or fail the import, which can be rerun as it is idempotent
+// @Tags admin
+// @Accept json,text/csv
+// @Produce json
+// @Param format query string false "jsonl or csv" default(jsonl)
+// @Param conflict query string false "skip, overwrite or fail" default(skip)
+// @Success 200 {object} models.ImportResult
+// @Failure 400 {object} utils.HTTPError
+// @Failure 401 {object} utils.HTTPError
+// @Failure 403 {object} utils.HTTPError
+// @Failure 409 {object} utils.HTTPError
+// @Failure 500 {object} utils.HTTPError
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /admin/import [post]
+func ImportUrls(g *gin.Context) {
+	format := transfer.Format(g.DefaultQuery("format", string(transfer.JsonLines)))
+	policy := transfer.ConflictPolicy(g.DefaultQuery("conflict", string(transfer.Skip)))
+
+	result, err := transfer.Import(context.TODO(), repository.Client, g.Request.Body, format, policy)
+
+	switch {
+	case errors.Is(err, transfer.ErrFormat), errors.Is(err, transfer.ErrConflictPolicy), errors.Is(err, transfer.ErrRecordInvalid):
+		utils.NewError(g, http.StatusBadRequest, err)
+	case errors.Is(err, transfer.ErrConflict):
+		utils.NewError(g, http.StatusConflict, err)
+	case err != nil:
+		utils.NewError(g, http.StatusInternalServerError, err)
+	default:
+		g.IndentedJSON(http.StatusOK, result)
+	}
+}
@@ -0,0 +1,168 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kjj1998/url-shortener-go/internal/models"
+	"github.com/kjj1998/url-shortener-go/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestExportUrls(t *testing.T) {
+	urls := []models.Url{
+		{Id: 1, ShortUrl: "NEDF34qw", LongUrl: "https://www.youtube.com", PasswordHash: "$2a$10$hash"},
+		{Id: 2, ShortUrl: "NWER425d", LongUrl: "http://example.com"},
+	}
+
+	tests := []struct {
+		name                string
+		query               string
+		mockRepoError       error
+		expectedStatus      int
+		expectedContentType string
+		expectedBody        string
+		expectedTrailer     string
+	}{
+		{
+			name:                "JSON Lines",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/x-ndjson",
+			expectedBody: `{"id":1,"shortUrl":"NEDF34qw","longUrl":"https://www.youtube.com","passwordHash":"$2a$10$hash"}
+{"id":2,"shortUrl":"NWER425d","longUrl":"http://example.com"}
+`,
+		},
+		{
+			name:                "CSV",
+			query:               "?format=csv",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "text/csv",
+			expectedBody: `id,shortUrl,longUrl,expiresAt,maxClicks,clicks,passwordHash,deletedAt,createdAt,owner,domain,destination,title,description,notes,tags
+1,NEDF34qw,https://www.youtube.com,,,,$2a$10$hash,,,,,,,,,
+2,NWER425d,http://example.com,,,,,,,,,,,,,
+`,
+		},
+		{
+			name:                "Cut short",
+			mockRepoError:       errors.New("simulated DynamoDB error"),
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/x-ndjson",
+			expectedBody: `{"id":1,"shortUrl":"NEDF34qw","longUrl":"https://www.youtube.com","passwordHash":"$2a$10$hash"}
+{"id":2,"shortUrl":"NWER425d","longUrl":"http://example.com"}
+`,
+			expectedTrailer: "simulated DynamoDB error",
+		},
+		{
+			name:           "Unknown format",
+			query:          "?format=xml",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"code":400, "message":"format must be jsonl or csv"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		mockRepo := new(MockUrlRepository)
+		repository.Client = mockRepo
+		mockRepo.On("ScanUrls", mock.Anything).Return(urls, tt.mockRepoError).Maybe()
+
+		rec := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(rec)
+		ctx.Request = httptest.NewRequest(http.MethodGet, "/api/v1/admin/export"+tt.query, nil)
+
+		ExportUrls(ctx)
+
+		assert.Equal(t, tt.expectedStatus, rec.Code, tt.name)
+		if tt.expectedStatus == http.StatusOK {
+			assert.Equal(t, tt.expectedContentType, rec.Header().Get("Content-Type"), tt.name)
+			assert.Equal(t, tt.expectedBody, rec.Body.String(), tt.name)
+			assert.Equal(t, tt.expectedTrailer, rec.Result().Trailer.Get(ExportErrorTrailer), tt.name)
+		} else {
+			assert.JSONEq(t, tt.expectedBody, rec.Body.String(), tt.name)
+		}
+	}
+}
+
+func TestImportUrls(t *testing.T) {
+	file := `{"id":1,"shortUrl":"NEDF34qw","longUrl":"https://www.google.com"}
+{"id":2,"shortUrl":"NWER425d","longUrl":"http://example.com"}
+`
+
+	tests := []struct {
+		name           string
+		query          string
+		body           string
+		expectedStatus int
+		expectedBody   string
+		expectedLong   string
+	}{
+		{
+			name:           "Skip",
+			body:           file,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"imported":1,"skipped":1}`,
+			expectedLong:   "https://www.youtube.com",
+		},
+		{
+			name:           "Overwrite",
+			query:          "?conflict=overwrite",
+			body:           file,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"imported":2,"skipped":0}`,
+			expectedLong:   "https://www.google.com",
+		},
+		{
+			name:           "Fail",
+			query:          "?conflict=fail",
+			body:           file,
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `{"code":409, "message":"record 1, shortened url NEDF34qw: shortened url or id is already taken"}`,
+			expectedLong:   "https://www.youtube.com",
+		},
+		{
+			name:           "CSV",
+			query:          "?format=csv",
+			body:           "id,shortUrl,longUrl\n2,NWER425d,http://example.com\n",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"imported":1,"skipped":0}`,
+			expectedLong:   "https://www.youtube.com",
+		},
+		{
+			name:           "Invalid record",
+			body:           `{"id":2,"shortUrl":"NWER425d"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"code":400, "message":"record 1: invalid record: id, shortUrl and longUrl are required"}`,
+			expectedLong:   "https://www.youtube.com",
+		},
+		{
+			name:           "Unknown policy",
+			query:          "?conflict=merge",
+			body:           file,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"code":400, "message":"conflict policy must be skip, overwrite or fail"}`,
+			expectedLong:   "https://www.youtube.com",
+		},
+	}
+
+	for _, tt := range tests {
+		client := repository.NewMemoryClient()
+		repository.Client = client
+		_ = client.AddUrl(context.Background(), models.Url{Id: 1, ShortUrl: "NEDF34qw", LongUrl: "https://www.youtube.com"})
+
+		rec := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(rec)
+		ctx.Request = httptest.NewRequest(http.MethodPost, "/api/v1/admin/import"+tt.query, strings.NewReader(tt.body))
+
+		ImportUrls(ctx)
+
+		assert.Equal(t, tt.expectedStatus, rec.Code, tt.name)
+		assert.JSONEq(t, tt.expectedBody, rec.Body.String(), tt.name)
+		url, _ := client.RetrieveUrl(context.Background(), "NEDF34qw")
+		assert.Equal(t, tt.expectedLong, url.LongUrl, tt.name)
+	}
+}
@@ -0,0 +1,140 @@
+package transfer
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kjj1998/url-shortener-go/internal/models"
+)
+
+var (
+	ErrRecordInvalid = errors.New("invalid record")
+	errRecordFields  = errors.New("id, shortUrl and longUrl are required")
+)
+
+// Record is a shortened URL as it is exported, with every field of models.Url including the ones never sent to clients.
+// Its fields mirror models.Url so the two convert into each other
+type Record struct {
+	Id           uint64     `json:"id"`
+	ShortUrl     string     `json:"shortUrl"`
+	LongUrl      string     `json:"longUrl"`
+	ExpiresAt    *time.Time `json:"expiresAt,omitempty"`
+	MaxClicks    int64      `json:"maxClicks,omitempty"`
+	Clicks       int64      `json:"clicks,omitempty"`
+	PasswordHash string     `json:"passwordHash,omitempty"`
+	DeletedAt    *time.Time `json:"deletedAt,omitempty"`
+	CreatedAt    *time.Time `json:"createdAt,omitempty"`
+	Owner        string     `json:"owner,omitempty"`
+	Domain       string     `json:"domain,omitempty"`
+	Destination  string     `json:"destination,omitempty"`
+	Title        string     `json:"title,omitempty"`
+	Description  string     `json:"description,omitempty"`
+	Notes        string     `json:"notes,omitempty"`
+	Tags         []string   `json:"tags,omitempty"`
+}
+
+func (r Record) validate() error {
+	if r.Id == 0 || r.ShortUrl == "" || r.LongUrl == "" {
+		return errRecordFields
+	}
+	return nil
+}
+
+// equal reports whether r and other hold the same fields as they are exported, so times are compared to the second
+func (r Record) equal(other Record) bool {
+	for _, column := range csvColumns {
+		if column.format(&r) != column.format(&other) {
+			return false
+		}
+	}
+	return true
+}
+
+// csvColumn formats and parses a field of Record as a CSV column
+type csvColumn struct {
+	name   string
+	format func(r *Record) string
+	parse  func(r *Record, value string) error
+}
+
+// csvColumns are the columns of an exported CSV file, in order. Times are RFC 3339 and tags are separated by ';'
+var csvColumns = []csvColumn{
+	{"id", func(r *Record) string { return strconv.FormatUint(r.Id, 10) }, func(r *Record, value string) (err error) {
+		r.Id, err = strconv.ParseUint(value, 10, 64)
+		return err
+	}},
+	{"shortUrl", func(r *Record) string { return r.ShortUrl }, func(r *Record, value string) error { r.ShortUrl = value; return nil }},
+	{"longUrl", func(r *Record) string { return r.LongUrl }, func(r *Record, value string) error { r.LongUrl = value; return nil }},
+	{"expiresAt", func(r *Record) string { return formatTime(r.ExpiresAt) }, func(r *Record, value string) (err error) {
+		r.ExpiresAt, err = parseTime(value)
+		return err
+	}},
+	{"maxClicks", func(r *Record) string { return formatInt(r.MaxClicks) }, func(r *Record, value string) (err error) {
+		r.MaxClicks, err = parseInt(value)
+		return err
+	}},
+	{"clicks", func(r *Record) string { return formatInt(r.Clicks) }, func(r *Record, value string) (err error) {
+		r.Clicks, err = parseInt(value)
+		return err
+	}},
+	{"passwordHash", func(r *Record) string { return r.PasswordHash }, func(r *Record, value string) error { r.PasswordHash = value; return nil }},
+	{"deletedAt", func(r *Record) string { return formatTime(r.DeletedAt) }, func(r *Record, value string) (err error) {
+		r.DeletedAt, err = parseTime(value)
+		return err
+	}},
+	{"createdAt", func(r *Record) string { return formatTime(r.CreatedAt) }, func(r *Record, value string) (err error) {
+		r.CreatedAt, err = parseTime(value)
+		return err
+	}},
+	{"owner", func(r *Record) string { return r.Owner }, func(r *Record, value string) error { r.Owner = value; return nil }},
+	{"domain", func(r *Record) string { return r.Domain }, func(r *Record, value string) error { r.Domain = value; return nil }},
+	{"destination", func(r *Record) string { return r.Destination }, func(r *Record, value string) error {
+		r.Destination = value
+		return nil
+	}},
+	{"title", func(r *Record) string { return r.Title }, func(r *Record, value string) error { r.Title = value; return nil }},
+	{"description", func(r *Record) string { return r.Description }, func(r *Record, value string) error { r.Description = value; return nil }},
+	{"notes", func(r *Record) string { return r.Notes }, func(r *Record, value string) error { r.Notes = value; return nil }},
+	{"tags", func(r *Record) string { return strings.Join(r.Tags, ";") }, func(r *Record, value string) error {
+		r.Tags = nil
+		if value != "" {
+			r.Tags = models.NormalizeTags(strings.Split(value, ";"))
+		}
+		return nil
+	}},
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func parseTime(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	t = t.UTC()
+	return &t, nil
+}
+
+func formatInt(n int64) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+func parseInt(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
@@ -0,0 +1,230 @@
+// Package transfer exports the shortened URLs of a repository and imports them into another one,
+// to move them between environments or back them up
+package transfer
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/kjj1998/url-shortener-go/internal/models"
+	"github.com/kjj1998/url-shortener-go/internal/repository"
+)
+
+// Format is the file format of exported shortened URLs
+type Format string
+
+const (
+	// JsonLines holds a JSON Record per line
+	JsonLines Format = "jsonl"
+	// Csv holds a Record per row, under a header row naming the columns
+	Csv Format = "csv"
+)
+
+// ConflictPolicy decides what an import does with a shortened URL that is already in the repository
+type ConflictPolicy string
+
+const (
+	// Skip keeps the entry in the repository
+	Skip ConflictPolicy = "skip"
+	// Overwrite replaces the entry in the repository with the imported one
+	Overwrite ConflictPolicy = "overwrite"
+	// Fail stops the import
+	Fail ConflictPolicy = "fail"
+)
+
+var (
+	ErrFormat         = fmt.Errorf("format must be %v or %v", JsonLines, Csv)
+	ErrConflictPolicy = fmt.Errorf("conflict policy must be %v, %v or %v", Skip, Overwrite, Fail)
+	ErrConflict       = errors.New("shortened url or id is already taken")
+)
+
+// Validate returns ErrConflictPolicy for an unknown policy
+func (p ConflictPolicy) Validate() error {
+	if !slices.Contains([]ConflictPolicy{Skip, Overwrite, Fail}, p) {
+		return ErrConflictPolicy
+	}
+	return nil
+}
+
+// ContentType returns the media type of files in the format
+func (f Format) ContentType() string {
+	if f == Csv {
+		return "text/csv"
+	}
+	return "application/x-ndjson"
+}
+
+// Validate returns ErrFormat for an unknown format
+func (f Format) Validate() error {
+	if f != JsonLines && f != Csv {
+		return ErrFormat
+	}
+	return nil
+}
+
+// Export writes every shortened URL of client to w as it is scanned, tombstones included. Every record is written
+// to w on its own, so w should be buffered when it is a file
+// Returns the number of shortened URLs written
+func Export(ctx context.Context, client repository.UrlRepository, w io.Writer, format Format) (int, error) {
+	if err := format.Validate(); err != nil {
+		return 0, err
+	}
+
+	write := newJsonLinesWriter(w)
+	if format == Csv {
+		var err error
+		if write, err = newCsvWriter(w); err != nil {
+			return 0, err
+		}
+	}
+
+	exported := 0
+	err := client.ScanUrls(ctx, func(url models.Url) error {
+		if err := write(Record(url)); err != nil {
+			return err
+		}
+		exported++
+		return nil
+	})
+	return exported, err
+}
+
+func newJsonLinesWriter(w io.Writer) func(record Record) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	return func(record Record) error { return encoder.Encode(record) }
+}
+
+// newCsvWriter writes the header row to w, returning the writer of the rows below it
+func newCsvWriter(w io.Writer) (func(record Record) error, error) {
+	writer := csv.NewWriter(w)
+	row := make([]string, len(csvColumns))
+	writeRow := func() error {
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+		// The csv writer buffers rows, flushing each of them keeps the export streaming
+		writer.Flush()
+		return writer.Error()
+	}
+
+	for i, column := range csvColumns {
+		row[i] = column.name
+	}
+	if err := writeRow(); err != nil {
+		return nil, err
+	}
+
+	return func(record Record) error {
+		for i, column := range csvColumns {
+			row[i] = column.format(&record)
+		}
+		return writeRow()
+	}, nil
+}
+
+// Import adds every shortened URL read from r to client with its original Id and shortened URL, resolving the ones
+// already taken by policy. Importing the same file again leaves the repository as it is, so a failed import can be rerun:
+// records whose entry is already stored as it is are skipped, even by the Fail policy
+// Returns the outcome of the records imported before an error, which names the record it stopped at
+func Import(ctx context.Context, client repository.UrlRepository, r io.Reader, format Format, policy ConflictPolicy) (models.ImportResult, error) {
+	var result models.ImportResult
+	if err := format.Validate(); err != nil {
+		return result, err
+	}
+	if err := policy.Validate(); err != nil {
+		return result, err
+	}
+
+	read := newJsonLinesReader(r)
+	if format == Csv {
+		read = newCsvReader(r)
+	}
+
+	for n := 1; ; n++ {
+		record, err := read()
+		if errors.Is(err, io.EOF) {
+			return result, nil
+		}
+		if err == nil {
+			err = record.validate()
+		}
+		if err != nil {
+			return result, fmt.Errorf("record %d: %w: %w", n, ErrRecordInvalid, err)
+		}
+
+		if policy == Overwrite {
+			err = client.PutUrl(ctx, models.Url(record))
+		} else {
+			err = client.AddUrl(ctx, models.Url(record))
+		}
+		switch {
+		case errors.Is(err, repository.ErrShortUrlTaken) && policy == Skip:
+			result.Skipped++
+		case errors.Is(err, repository.ErrShortUrlTaken):
+			stored, err := client.RetrieveUrl(ctx, record.ShortUrl)
+			if err != nil {
+				return result, fmt.Errorf("record %d: %w", n, err)
+			}
+			if !Record(stored).equal(record) {
+				return result, fmt.Errorf("record %d, shortened url %v: %w", n, record.ShortUrl, ErrConflict)
+			}
+			result.Skipped++
+		case err != nil:
+			return result, fmt.Errorf("record %d: %w", n, err)
+		default:
+			result.Imported++
+		}
+	}
+}
+
+// newJsonLinesReader reads a Record from each line, skipping blank lines and refusing unknown fields
+func newJsonLinesReader(r io.Reader) func() (Record, error) {
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+
+	return func() (Record, error) {
+		var record Record
+		err := decoder.Decode(&record)
+		return record, err
+	}
+}
+
+// newCsvReader reads a Record from each row under the header row, which may hold any of the exported columns
+func newCsvReader(r io.Reader) func() (Record, error) {
+	reader := csv.NewReader(r)
+	var header []csvColumn
+
+	return func() (Record, error) {
+		if header == nil {
+			names, err := reader.Read()
+			if err != nil {
+				return Record{}, err
+			}
+			for _, name := range names {
+				i := slices.IndexFunc(csvColumns, func(column csvColumn) bool { return column.name == name })
+				if i < 0 {
+					return Record{}, fmt.Errorf("csv column %q is not an exported column", name)
+				}
+				header = append(header, csvColumns[i])
+			}
+		}
+
+		row, err := reader.Read()
+		if err != nil {
+			return Record{}, err
+		}
+		var record Record
+		for i, value := range row {
+			if err := header[i].parse(&record, value); err != nil {
+				return Record{}, fmt.Errorf("invalid %v: %w", header[i].name, err)
+			}
+		}
+		return record, nil
+	}
+}
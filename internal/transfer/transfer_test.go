@@ -0,0 +1,185 @@
+package transfer
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kjj1998/url-shortener-go/internal/models"
+	"github.com/kjj1998/url-shortener-go/internal/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+// exportedUrls are the entries exported by the tests, with every field of models.Url set on one of them
+func exportedUrls() []models.Url {
+	createdAt := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	expiresAt := time.Date(2026, 1, 1, 12, 30, 0, 0, time.UTC)
+	deletedAt := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	return []models.Url{
+		{
+			Id: 1, ShortUrl: "aaa", LongUrl: "https://www.youtube.com/watch?v=1&t=2", ExpiresAt: &expiresAt,
+			MaxClicks: 10, Clicks: 3, PasswordHash: "$2a$10$hash", CreatedAt: &createdAt, Owner: "alice",
+			Domain: "www.youtube.com", Destination: "https://www.youtube.com/watch?v=1&t=2", Title: "A \"quoted\", title", Description: "Line one\nline two", Notes: "notes",
+			Tags: []string{"go", "news"},
+		},
+		{Id: 2, ShortUrl: "bbb", LongUrl: "https://www.google.com", DeletedAt: &deletedAt},
+		{Id: 3, ShortUrl: "legacy", LongUrl: "https://example.org"},
+	}
+}
+
+func enterTransferTest(t *testing.T) (context.Context, *repository.MemoryClient) {
+	ctx := context.Background()
+	client := repository.NewMemoryClient()
+	for _, url := range exportedUrls() {
+		if err := client.AddUrl(ctx, url); err != nil {
+			t.Fatalf("Couldn't add url: %v", err)
+		}
+	}
+	return ctx, client
+}
+
+func scanUrls(t *testing.T, ctx context.Context, client repository.UrlRepository) []models.Url {
+	var urls []models.Url
+	assert.NoError(t, client.ScanUrls(ctx, func(url models.Url) error {
+		urls = append(urls, url)
+		return nil
+	}))
+	return urls
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, format := range []Format{JsonLines, Csv} {
+		t.Run(string(format), func(t *testing.T) {
+			ctx, client := enterTransferTest(t)
+
+			var file bytes.Buffer
+			exported, err := Export(ctx, client, &file, format)
+			assert.NoError(t, err)
+			assert.Equal(t, 3, exported)
+
+			imported := repository.NewMemoryClient()
+			result, err := Import(ctx, imported, bytes.NewReader(file.Bytes()), format, Fail)
+			assert.NoError(t, err)
+			assert.Equal(t, models.ImportResult{Imported: 3}, result)
+			assert.Equal(t, exportedUrls(), scanUrls(t, ctx, imported))
+
+			// Importing the file again changes nothing, and does not fail on the entries it already imported
+			for _, policy := range []ConflictPolicy{Skip, Fail} {
+				result, err = Import(ctx, imported, bytes.NewReader(file.Bytes()), format, policy)
+				assert.NoError(t, err, policy)
+				assert.Equal(t, models.ImportResult{Skipped: 3}, result, policy)
+				assert.Equal(t, exportedUrls(), scanUrls(t, ctx, imported), policy)
+			}
+		})
+	}
+}
+
+func TestExportCsvHeader(t *testing.T) {
+	var file bytes.Buffer
+	exported, err := Export(context.Background(), repository.NewMemoryClient(), &file, Csv)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 0, exported)
+	assert.Equal(t, "id,shortUrl,longUrl,expiresAt,maxClicks,clicks,passwordHash,deletedAt,createdAt,owner,domain,destination,"+
+		"title,description,notes,tags\n", file.String())
+}
+
+func TestImportConflicts(t *testing.T) {
+	file := `{"id":1,"shortUrl":"aaa","longUrl":"https://example.com"}
+{"id":4,"shortUrl":"ddd","longUrl":"https://example.net"}
+`
+	tests := []struct {
+		name           string
+		policy         ConflictPolicy
+		expectedResult models.ImportResult
+		expectedErr    error
+		expectedLong   string
+	}{
+		{name: "Skip", policy: Skip, expectedResult: models.ImportResult{Imported: 1, Skipped: 1}, expectedLong: "https://www.youtube.com/watch?v=1&t=2"},
+		{name: "Overwrite", policy: Overwrite, expectedResult: models.ImportResult{Imported: 2}, expectedLong: "https://example.com"},
+		{name: "Fail", policy: Fail, expectedErr: ErrConflict, expectedLong: "https://www.youtube.com/watch?v=1&t=2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, client := enterTransferTest(t)
+
+			result, err := Import(ctx, client, strings.NewReader(file), JsonLines, tt.policy)
+
+			assert.ErrorIs(t, err, tt.expectedErr)
+			assert.Equal(t, tt.expectedResult, result)
+			url, _ := client.RetrieveUrl(ctx, "aaa")
+			assert.Equal(t, tt.expectedLong, url.LongUrl)
+		})
+	}
+}
+
+func TestImportInvalid(t *testing.T) {
+	tests := []struct {
+		name        string
+		format      Format
+		policy      ConflictPolicy
+		file        string
+		expectedErr error
+		imported    int
+	}{
+		{name: "Unknown format", format: "xml", policy: Skip, expectedErr: ErrFormat},
+		{name: "Unknown policy", format: JsonLines, policy: "merge", expectedErr: ErrConflictPolicy},
+		{
+			name:        "Unknown field",
+			format:      JsonLines,
+			policy:      Skip,
+			file:        `{"id":4,"shortUrl":"ddd","longUrl":"https://example.net","clickz":1}`,
+			expectedErr: ErrRecordInvalid,
+		},
+		{
+			name:        "Missing field",
+			format:      JsonLines,
+			policy:      Skip,
+			file:        "{\"id\":4,\"shortUrl\":\"ddd\",\"longUrl\":\"https://example.net\"}\n{\"id\":5,\"shortUrl\":\"eee\"}\n",
+			expectedErr: ErrRecordInvalid,
+			imported:    1,
+		},
+		{
+			name:        "Unknown column",
+			format:      Csv,
+			policy:      Skip,
+			file:        "id,shortUrl,longUrl,clickz\n4,ddd,https://example.net,1\n",
+			expectedErr: ErrRecordInvalid,
+		},
+		{
+			name:        "Invalid time",
+			format:      Csv,
+			policy:      Skip,
+			file:        "id,shortUrl,longUrl,createdAt\n4,ddd,https://example.net,yesterday\n",
+			expectedErr: ErrRecordInvalid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, client := enterTransferTest(t)
+
+			result, err := Import(ctx, client, strings.NewReader(tt.file), tt.format, tt.policy)
+
+			assert.ErrorIs(t, err, tt.expectedErr)
+			assert.Equal(t, tt.imported, result.Imported)
+		})
+	}
+}
+
+func TestImportCsvColumns(t *testing.T) {
+	ctx := context.Background()
+	client := repository.NewMemoryClient()
+	file := "shortUrl,longUrl,id,tags\nddd,https://example.net,4,go;news\n"
+
+	result, err := Import(ctx, client, strings.NewReader(file), Csv, Skip)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.Imported)
+	url, _ := client.RetrieveUrl(ctx, "ddd")
+	assert.Equal(t, models.Url{Id: 4, ShortUrl: "ddd", LongUrl: "https://example.net", Tags: []string{"go", "news"}}, url)
+}
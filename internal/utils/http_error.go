@@ -1,11 +1,16 @@
 package utils
 
-import "github.com/gin-gonic/gin"
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+)
 
 func NewError(ctx *gin.Context, status int, err error) {
 	er := HTTPError{
-		Code:    status,
-		Message: err.Error(),
+		Code:      status,
+		Message:   err.Error(),
+		ErrorCode: ErrorCode(err),
 	}
 	ctx.JSON(status, er)
 }
@@ -13,4 +18,15 @@ func NewError(ctx *gin.Context, status int, err error) {
 type HTTPError struct {
 	Code    int    `json:"code" example:"400"`
 	Message string `json:"message" example:"status bad request"`
+	// ErrorCode tells apart the errors answered with the same status, for the errors that have one
+	ErrorCode string `json:"errorCode,omitempty" example:"url_scheme_not_allowed"`
+}
+
+// ErrorCode returns the code of err, or an empty string when it has none
+func ErrorCode(err error) string {
+	var coded interface{ ErrorCode() string }
+	if errors.As(err, &coded) {
+		return coded.ErrorCode()
+	}
+	return ""
 }
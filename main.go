@@ -1,6 +1,9 @@
 package main
 
 import (
+	"context"
+	"log"
+	"os"
 	"time"
 
 	"github.com/gin-contrib/cors"
@@ -8,7 +11,11 @@ import (
 	swaggerfiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 
+	"github.com/kjj1998/url-shortener-go/constants"
 	docs "github.com/kjj1998/url-shortener-go/docs"
+	"github.com/kjj1998/url-shortener-go/internal/commands"
+	"github.com/kjj1998/url-shortener-go/internal/config"
+	"github.com/kjj1998/url-shortener-go/internal/models"
 	"github.com/kjj1998/url-shortener-go/internal/routes"
 )
 
@@ -27,7 +34,24 @@ import (
 //	@host		localhost:8080
 //	@BasePath	/api/v1
 
+//	@securityDefinitions.apikey	ApiKeyAuth
+//	@in							header
+//	@name						X-Api-Key
+//	@description				API key created with the create-api-key command or POST /admin/api-keys
+
+//	@securityDefinitions.apikey	BearerAuth
+//	@in							header
+//	@name						Authorization
+//	@description				"Bearer " followed by an API key, or by a JWT of the OIDC provider when JWT_JWKS is set
+
 func main() {
+	if len(os.Args) > 1 {
+		if err := commands.Run(os.Args[1:]); err != nil {
+			log.Fatalf("%v", err)
+		}
+		return
+	}
+
 	router := gin.Default()
 	router.Use(cors.New(cors.Config{
 		AllowOrigins:  []string{"http://localhost:80"},
@@ -37,14 +61,29 @@ func main() {
 		MaxAge:        12 * time.Hour,
 	}))
 
-	docs.SwaggerInfo.BasePath = "/api/v1"
-	v1 := router.Group("/api/v1")
+	docs.SwaggerInfo.BasePath = constants.BasePath
+	v1 := router.Group(constants.BasePath)
 	{
-		shortenUrl := v1.Group("/data")
+		shortenUrl := v1.Group("/data", routes.Authenticate)
 		{
-			shortenUrl.POST("/shorten", routes.GenerateShortenedUrl)
+			shortenUrl.POST("/shorten", routes.RequireScope(models.ScopeCreate), routes.Idempotent, routes.GenerateShortenedUrl)
+			shortenUrl.POST("/shorten/batch", routes.RequireScope(models.ScopeCreate), routes.Idempotent, routes.ShortenBatch)
+			shortenUrl.GET("/cache/stats", routes.RequireScope(models.ScopeRead), routes.CacheStats)
+			shortenUrl.GET("/links", routes.RequireScope(models.ScopeRead), routes.ListUrls)
+			shortenUrl.POST("/tags/merge", routes.RequireScope(models.ScopeUpdate), routes.MergeTags)
+			shortenUrl.PATCH("/:shortUrl", routes.RequireScope(models.ScopeUpdate), routes.UpdateShortenedUrl)
+			shortenUrl.DELETE("/:shortUrl", routes.RequireScope(models.ScopeDelete), routes.DeleteShortenedUrl)
+		}
+		admin := v1.Group("/admin", routes.Authenticate, routes.RequireScope(models.ScopeAdmin))
+		{
+			admin.GET("/export", routes.ExportUrls)
+			admin.POST("/import", routes.ImportUrls)
+			admin.POST("/api-keys", routes.CreateApiKey)
+			admin.GET("/api-keys", routes.ListApiKeys)
+			admin.DELETE("/api-keys/:id", routes.RevokeApiKey)
 		}
 		v1.GET("/:shortUrl", routes.RedirectShortenedUrl)
+		v1.POST("/:shortUrl", routes.RedirectShortenedUrl)
 		v1.GET("/health", routes.HealthCheck)
 	}
 
@@ -52,5 +91,17 @@ func main() {
 		ginSwagger.URL("http://localhost:80/swagger/doc.json"),
 		ginSwagger.DefaultModelsExpandDepth(-1)))
 
+	routes.ReserveRoutes(router, docs.SwaggerInfo.BasePath)
+	models.ReserveWords(config.List("RESERVED_WORDS")...)
+	if config.Bool("RESERVED_WORDS_SCAN", false) {
+		if err := routes.ScanReservedShortUrls(context.Background()); err != nil {
+			log.Fatalf("%v", err)
+		}
+	} else if config.Bool("RESERVED_WORDS_CHECK", true) {
+		if err := routes.CheckReservedShortUrls(context.Background()); err != nil {
+			log.Fatalf("%v", err)
+		}
+	}
+
 	router.Run(":80")
 }